@@ -21,6 +21,7 @@ import (
 	"github.com/sinspired/subs-check-pro/assets"
 	"github.com/sinspired/subs-check-pro/check"
 	"github.com/sinspired/subs-check-pro/config"
+	proxyutils "github.com/sinspired/subs-check-pro/proxy"
 	"github.com/sinspired/subs-check-pro/save"
 	"github.com/sinspired/subs-check-pro/utils"
 )
@@ -145,7 +146,7 @@ func (app *App) Initialize() error {
 	}
 
 	// 设置信号处理器
-	app.stopCh = utils.SetupSignalHandler(&check.ForceClose, &app.checking)
+	app.stopCh = utils.SetupSignalHandler(check.ForceClose, &app.checking)
 
 	// 每周日 0 点自动更新 GeoLite2 数据库
 	weeklyCron := cron.New()
@@ -159,10 +160,24 @@ func (app *App) Initialize() error {
 	})
 	if err != nil {
 		slog.Error(fmt.Sprintf("注册 GeoLite2 数据库更新任务失败: %v", err))
-	} else {
-		weeklyCron.Start()
 	}
 
+	// 按 ip-risk-update-interval 定时更新离线 IP 风险数据集
+	interval := config.GlobalConfig.IPRiskUpdateInterval
+	if interval <= 0 {
+		interval = 24
+	}
+	if _, err := weeklyCron.AddFunc(fmt.Sprintf("@every %dh", interval), func() {
+		if !app.checking.Load() {
+			if err := check.UpdateIPRiskData(); err != nil {
+				slog.Warn(fmt.Sprintf("更新 IP 风险数据集失败: %v", err))
+			}
+		}
+	}); err != nil {
+		slog.Error(fmt.Sprintf("注册 IP 风险数据集更新任务失败: %v", err))
+	}
+	weeklyCron.Start()
+
 	// 检查版本更新
 	app.SetupUpdateTasks()
 
@@ -183,9 +198,31 @@ func (app *App) Run() {
 		}
 	}()
 
+	// worker 模式：由协调节点分配检测任务，不执行定时检测
+	if config.GlobalConfig.Distributed.Mode == check.ModeWorker {
+		go check.RunWorker(app.ctx, &app.checking)
+		<-app.stopCh
+		if err := app.Shutdown(); err != nil {
+			slog.Error("关闭应用失败", "err", err)
+		}
+		return
+	}
+
 	app.setTimer()
 
-	if config.GlobalConfig.CronExpression != "" {
+	// 上次检测中断（内存超限重启、自动更新、容器重启等）
+	resume := false
+	if check.HasCheckpoint() {
+		if config.GlobalConfig.AutoResume {
+			slog.Info("发现未完成的检测，将从断点恢复")
+			check.RequestResume()
+			resume = true
+		} else {
+			slog.Info("发现未完成的检测，可通过 POST /api/trigger-check?resume=true 从断点恢复")
+		}
+	}
+
+	if config.GlobalConfig.CronExpression != "" && !resume {
 		slog.Warn("使用cron表达式，首次启动不立即执行检测")
 	} else {
 		app.triggerCheck()
@@ -275,6 +312,12 @@ func (app *App) TriggerCheck() {
 
 // triggerCheck 内部检测方法
 func (app *App) triggerCheck() {
+	// worker 模式由协调节点分配任务
+	if config.GlobalConfig.Distributed.Mode == check.ModeWorker {
+		slog.Warn("worker 模式不执行本地检测")
+		return
+	}
+
 	// 如果已经在检测中，直接返回
 	if !app.checking.CompareAndSwap(false, true) {
 		slog.Warn("已有检测正在进行，跳过本次检测")
@@ -323,6 +366,11 @@ func (app *App) checkProxies() error {
 	slog.Info("检测完成")
 	save.SaveConfig(results)
 	utils.SendNotifyCheckResult(len(results))
+	if config.GlobalConfig.SubUsage.Notify {
+		if lines := proxyutils.SubUsageWarnings(startTime); len(lines) > 0 {
+			utils.SendNotifySubUsage(lines)
+		}
+	}
 	utils.UpdateSubs()
 
 	// 执行回调脚本
@@ -0,0 +1,82 @@
+package app
+
+import (
+	"crypto/subtle"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/goccy/go-yaml"
+	"github.com/sinspired/subs-check-pro/check"
+	"github.com/sinspired/subs-check-pro/config"
+)
+
+// clusterBodyLimit worker 单次上报的大小上限
+const clusterBodyLimit = 256 << 20
+
+// registerClusterRoutes 注册分布式检测协调接口，使用 distributed.token 认证
+func (app *App) registerClusterRoutes(router *gin.Engine) {
+	if config.GlobalConfig.Distributed.Token == "" {
+		slog.Error("协调模式需要设置 distributed.token，已跳过注册分布式接口")
+		return
+	}
+	cluster := router.Group("/")
+	cluster.Use(app.clusterAuthMiddleware())
+	cluster.POST(check.ClusterLeasePath, app.clusterLease)
+	cluster.POST(check.ClusterReportPath, app.clusterReport)
+	slog.Info("分布式检测协调接口已启用", "path", check.ClusterLeasePath)
+}
+
+// clusterAuthMiddleware 校验 Authorization: Bearer <token>
+func (app *App) clusterAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(config.GlobalConfig.Distributed.Token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "无效的令牌"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// clusterLease 为 worker 分配分片，无待检测分片时返回 204
+func (app *App) clusterLease(c *gin.Context) {
+	lease := check.ClusterLease(c.Query("worker"))
+	if lease == nil {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	data, err := yaml.Marshal(lease)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Data(http.StatusOK, "application/yaml", data)
+}
+
+// clusterReport 接收 worker 上报的进度和结果，租约失效时返回 409
+func (app *App) clusterReport(c *gin.Context) {
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, clusterBodyLimit))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var report check.ShardReport
+	if err := yaml.Unmarshal(data, &report); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = check.ClusterReport(&report)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"message": "ok"})
+	case errors.Is(err, check.ErrStaleLease), errors.Is(err, check.ErrNoClusterRun):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"crypto/subtle"
 	"fmt"
 	"html/template"
+	"io"
 	"io/fs"
 	"log/slog"
 	"net"
@@ -20,7 +21,9 @@ import (
 	"github.com/goccy/go-yaml"
 	"github.com/sinspired/subs-check-pro/assets"
 	"github.com/sinspired/subs-check-pro/check"
+	"github.com/sinspired/subs-check-pro/check/health"
 	"github.com/sinspired/subs-check-pro/config"
+	proxyutils "github.com/sinspired/subs-check-pro/proxy"
 	"github.com/sinspired/subs-check-pro/save/method"
 	"github.com/sinspired/subs-check-pro/utils"
 )
@@ -112,6 +115,11 @@ func (app *App) initHTTPServer() error {
 		app.registerAPIRoutes(router)
 	}
 
+	// 分布式检测协调接口（不依赖 WebUI）
+	if config.GlobalConfig.Distributed.Mode == check.ModeCoordinator {
+		app.registerClusterRoutes(router)
+	}
+
 	listenAddr := normalizeListenAddr(config.GlobalConfig.ListenPort)
 	srv := &http.Server{
 		Addr:    listenAddr,
@@ -253,6 +261,10 @@ func (app *App) registerAPIRoutes(router *gin.Engine) {
 		api.GET("/singbox-versions", app.getSingboxVersions)
 		api.GET("/logs", app.getLogs)
 		api.GET("/analysis-report", app.getAnalysisReport)
+		api.GET("/node-health", app.getNodeHealth)
+		api.GET("/sub-health", app.getSubHealth)
+		api.POST("/sub-health/reset", app.resetSubQuarantine)
+		api.POST("/check-node", app.checkNodeHandler)
 	}
 }
 
@@ -350,10 +362,28 @@ func (app *App) getStatus(c *gin.Context) {
 		"lastCheck":         lastCheck,
 		"isSubStoreRunning": assets.IsSubStoreRunning.Load(),
 		"eta":               check.ETASeconds.Load(), // -1=计算中, 0=完成, >0=剩余秒
+		"resumable":         !app.checking.Load() && check.HasCheckpoint(),
+		"cluster":           check.ClusterStatus(),      // 分布式检测状态，未进行时为 null
+		"concurrency":       check.CurrentConcurrency(), // 各阶段当前并发数
 	})
 }
 
 func (app *App) triggerCheckHandler(c *gin.Context) {
+	// resume=true 时从中断的检测断点继续
+	if resume, _ := strconv.ParseBool(c.Query("resume")); resume {
+		if app.checking.Load() {
+			c.JSON(http.StatusConflict, gin.H{"error": "已有检测正在进行"})
+			return
+		}
+		if !check.HasCheckpoint() {
+			c.JSON(http.StatusNotFound, gin.H{"error": "没有可恢复的检测"})
+			return
+		}
+		check.RequestResume()
+		app.TriggerCheck()
+		c.JSON(http.StatusOK, gin.H{"message": "已触发检测，从断点恢复"})
+		return
+	}
 	app.TriggerCheck()
 	c.JSON(http.StatusOK, gin.H{"message": "已触发检测"})
 }
@@ -411,6 +441,144 @@ func (app *App) getAnalysisReport(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"report": string(data)})
 }
 
+// getNodeHealth 查询节点健康数据库
+// 参数: days 统计天数(默认 7，最多 health.RecentDays), min-checks 最少检测次数(默认 3), limit 返回数量(默认 100)
+func (app *App) getNodeHealth(c *gin.Context) {
+	days, _ := strconv.Atoi(c.DefaultQuery("days", "7"))
+	minChecks, _ := strconv.Atoi(c.DefaultQuery("min-checks", "3"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if days <= 0 {
+		days = 7
+	}
+	// 更早的检测结果不再保留
+	days = min(days, health.RecentDays)
+
+	dbPath, err := health.DefaultPath()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取健康数据库路径失败: %v", err)})
+		return
+	}
+	db, err := health.Open(dbPath)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	since := time.Now().AddDate(0, 0, -days)
+	records := db.Reliable(since, minChecks, limit)
+	nodes := make([]gin.H, 0, len(records))
+	for _, r := range records {
+		checks, passes := r.WindowStats(since)
+		nodes = append(nodes, gin.H{
+			"key":                  r.Key,
+			"name":                 r.Name,
+			"type":                 r.Type,
+			"server":               r.Server,
+			"sub_url":              r.SubURL,
+			"first_seen":           r.FirstSeen,
+			"last_seen":            r.LastSeen,
+			"last_pass":            r.LastPass,
+			"checks":               r.Checks,
+			"passes":               r.Passes,
+			"window_checks":        checks,
+			"window_passes":        passes,
+			"consecutive_failures": r.ConsecFails,
+			"last_speed":           r.LastSpeed,
+			"last_latency":         r.LastLatency,
+			"last_ip":              r.LastIP,
+			"last_country":         r.LastCountry,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"tracked": db.Len(),
+		"days":    days,
+		"nodes":   nodes,
+	})
+}
+
+// getSubHealth 返回订阅健康统计和隔离状态，quarantined=true 时只返回隔离中的订阅
+func (app *App) getSubHealth(c *gin.Context) {
+	records, err := proxyutils.SubHealthRecords()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取订阅健康数据库失败: %v", err)})
+		return
+	}
+	onlyQuarantined := c.Query("quarantined") == "true"
+
+	quarantined := 0
+	subs := records[:0]
+	for _, r := range records {
+		if r.Quarantined() {
+			quarantined++
+		} else if onlyQuarantined {
+			continue
+		}
+		subs = append(subs, r)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"tracked":     len(records),
+		"quarantined": quarantined,
+		"enabled":     config.GlobalConfig.SubQuarantine.Enabled,
+		"subs":        subs,
+	})
+}
+
+// resetSubQuarantine 解除订阅隔离，请求体 urls 为空时解除全部
+func (app *App) resetSubQuarantine(c *gin.Context) {
+	var req struct {
+		URLs []string `json:"urls"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求格式"})
+			return
+		}
+	}
+	n, err := proxyutils.ResetSubQuarantine(req.URLs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("解除订阅隔离失败: %v", err)})
+		return
+	}
+	slog.Info(fmt.Sprintf("已解除订阅隔离: %d", n))
+	c.JSON(http.StatusOK, gin.H{"reset": n})
+}
+
+// checkNodeLimit 同时进行的单节点检测数量上限
+const checkNodeLimit = 4
+
+// checkNodeSem 限制单节点检测并发
+var checkNodeSem = make(chan struct{}, checkNodeLimit)
+
+// checkNodeHandler 同步检测单个节点，请求体为分享链接、Clash YAML 片段或 JSON
+func (app *App) checkNodeHandler(c *gin.Context) {
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<20))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	nodes, err := proxyutils.ParseNodes(data)
+	if err != nil || len(nodes) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("解析节点失败: %v", err)})
+		return
+	}
+	if len(nodes) > 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("只支持检测单个节点，解析到 %d 个", len(nodes))})
+		return
+	}
+
+	select {
+	case checkNodeSem <- struct{}{}:
+		defer func() { <-checkNodeSem }()
+	default:
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "单节点检测请求过多，请稍后再试"})
+		return
+	}
+
+	c.JSON(http.StatusOK, check.CheckNode(c.Request.Context(), nodes[0]))
+}
+
 // handleAnalysis 渲染检测分析报告页面
 // 数据通过客户端 JS 从 /api/analysis-report 拉取（已有鉴权）
 func (app *App) handleAnalysis(c *gin.Context) {
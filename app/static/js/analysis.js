@@ -1243,6 +1243,7 @@ function renderSubs(subs, subsBad, cfg) {
         <div class="sub-bar-wrap"><div class="sub-bar" style="width:${Math.min(rateNum, 100)}%;background:${barColor}"></div></div>
         <div class="sub-meta">
           <span>${stats.success || 0} / ${stats.total || 0} 节点</span>
+          ${s.cache === 'fallback' ? '<span class="tag-pill" title="订阅获取失败，使用缓存内容">缓存</span>' : ''}
           ${locs.map(l => `<span class="tag-pill">${l}</span>`).join('')}
           ${protos.map(([k, v]) => `<span class="tag-pill">${k}:${v}</span>`).join('')}
         </div>
@@ -1275,6 +1276,7 @@ function renderSubs(subs, subsBad, cfg) {
             const st = s.stats || {};
             return `<div class="bad-item">
              <span class="bad-url" title="${esc(s.url)}">${esc(s.url)}</span>
+             ${s.cache === 'fallback' ? '<span class="tag-pill" title="订阅获取失败，使用缓存内容">缓存</span>' : ''}
              <span class="bad-count">${st.success || 0}/${st.total || 0}</span>
            </div>`;
         }).join('')}
@@ -1543,7 +1545,9 @@ function renderConfig(ci, ga, sr, sb, cfg) {
     const updateOnStart = cfg['update-on-startup'] !== false;
 
     const subUrls = Array.isArray(cfg['sub-urls']) ? cfg['sub-urls'] : [];
-    const hasLocalhostAll = subUrls.some(u => typeof u === 'string' && /127\.0\.0\.1.*all\.yaml/i.test(u));
+    const hasLocalhostAll = subUrls
+        .map(u => (u && typeof u === 'object' ? u.url : u))
+        .some(u => typeof u === 'string' && /127\.0\.0\.1.*all\.yaml/i.test(u));
 
     let hasNotify = false;
     if (Array.isArray(recipientUrl)) hasNotify = recipientUrl.filter(v => typeof v === 'string' && v.trim()).length > 0;
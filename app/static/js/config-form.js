@@ -115,7 +115,7 @@ const SCHEMA = [
         fields: [
           {
             key: 'sub-urls', label: '订阅地址', type: 'url-list',
-            hint: '支持 Clash / V2Ray / Base64；末尾 #备注 可添加标签；支持 {Ymd} 日期占位符',
+            hint: '支持 Clash / V2Ray / Base64；末尾 #备注 可添加标签；支持 {Ymd} 日期占位符；对象写法以 JSON 编辑',
           },
         ],
       },
@@ -144,6 +144,8 @@ const SCHEMA = [
         title: '延迟参数',
         fields: [
           { key: 'timeout', label: '超时时间 (ms)', type: 'number', min: 1000, max: 15000, placeholder: '6000', hint: '节点延迟上限，建议 3000–10000' },
+          { key: 'latency-samples', label: '延迟采样次数', type: 'number', min: 0, max: 10, placeholder: '3', hint: '测活后多次测量延迟，记录中位数和抖动；0 = 关闭' },
+          { key: 'max-latency', label: '最大延迟 (ms)', type: 'number', min: 0, max: 10000, placeholder: '0', hint: '延迟中位数超过此值的节点将被丢弃，0 = 不过滤' },
         ],
       },
       {
@@ -1139,7 +1141,8 @@ function mkUrlList(field, values) {
   }
 
   addBtn.addEventListener('click', () => addRow());
-  list.forEach(v => addRow(v));
+  // 对象写法的订阅源以单行 JSON 显示，保存时还原
+  list.forEach(v => addRow(v && typeof v === 'object' ? JSON.stringify(v) : v));
   return wrap;
 }
 
@@ -2050,6 +2053,10 @@ function collectPanel(tabId) {
               .replace(/\t/g, '\\t')
             )
             .filter(Boolean)
+            .map(s => {
+              if (!s.startsWith('{')) return s
+              try { return JSON.parse(s) } catch { return s }
+            })
           break
         }
         default: {
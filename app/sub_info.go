@@ -21,7 +21,7 @@ type reportFallback struct {
 }
 
 const (
-	// totalBytes = 1024 GiB，以字节计
+	// totalBytes = 1024 GiB，以字节计，未设置月流量预算时使用
 	// 1 GiB = 1024^3 = 1 073 741 824 bytes
 	// 1024 GiB = 1 099 511 627 776 bytes
 	totalBytes int64 = 1024 * 1_073_741_824
@@ -51,8 +51,8 @@ func (app *App) handleSubscriptionInfo(c *gin.Context) {
 // buildSubscriptionInfo 组装符合代理客户端规范的订阅信息字符串。
 //
 // 字段说明：
-//   - upload / download  来自 check.UP / check.DOWN（原子计数器，单位 bytes）
-//   - total              固定 1024 TiB，1 PB
+//   - upload / download  本月累计检测流量（check.MonthlyUsage，单位 bytes），无记录时来自 check.UP / check.DOWN
+//   - total              traffic-budget-per-month，未设置时固定 1024 GiB
 //   - expire             固定 2077-06-01 UTC Unix 时间戳
 //   - reset_hour         距下次重置不足 1 天时显示，值为重置时刻的小时数
 //   - reset_day          距下次重置超过 1 天时显示，值为剩余整天数
@@ -63,6 +63,16 @@ func buildSubscriptionInfo() string {
 	upload := check.UP.Load()
 	download := check.DOWN.Load()
 
+	// 本月累计用量跨重启保存，优先使用
+	if usage := check.MonthlyUsage(); usage.Total() > 0 {
+		upload, download = usage.Up, usage.Down
+	}
+
+	total := totalBytes
+	if budget := config.GlobalConfig.TrafficBudgetPerMonth; budget > 0 {
+		total = int64(budget) * 1_073_741_824
+	}
+
 	// 兜底：程序重启后原子计数器归零，从历史报告中补充
 	var fb reportFallback
 	if upload == 0 && download == 0 || check.CheckEndTime.IsZero() {
@@ -101,7 +111,7 @@ func buildSubscriptionInfo() string {
 		"upload=%d; download=%d; total=%d; expire=%d;"+
 			" %s; next_update=%s; last_update=%s;"+
 			" plan_name='%s'; app_url=%s",
-		upload, download, totalBytes, expireUnix,
+		upload, download, total, expireUnix,
 		resetField, nextUpdate, lastUpdate,
 		planName, appURL,
 	)
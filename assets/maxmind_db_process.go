@@ -29,6 +29,12 @@ type githubRelease struct {
 	} `json:"assets"`
 }
 
+// 数据库文件名，默认保存在 output/MaxMindData 目录
+const (
+	CountryDBFileName = "GeoLite2-Country.mmdb"
+	ASNDBFileName     = "GeoLite2-ASN.mmdb"
+)
+
 // OpenMaxMindDB 使用指定路径或默认路径打开 MaxMind 数据库
 func OpenMaxMindDB(dbPath string) (*maxminddb.Reader, error) {
 	if dbPath != "" {
@@ -87,8 +93,38 @@ func decompressEmbeddedMMDB(targetPath string) error {
 	return nil
 }
 
+// OpenASNDB 使用指定路径或默认路径打开 ASN 数据库
+//
+// 未内置 ASN 数据库，默认路径下不存在时从 GeoLite2 release 下载。
+func OpenASNDB(dbPath string) (*maxminddb.Reader, error) {
+	if dbPath != "" {
+		return openDBWithArch(dbPath)
+	}
+	dir, err := resolveDBDir()
+	if err != nil {
+		return nil, err
+	}
+	asnPath := filepath.Join(dir, ASNDBFileName)
+	if _, err := os.Stat(asnPath); os.IsNotExist(err) {
+		slog.Info("下载 GeoLite2-ASN 数据库...")
+		if _, err := updateMMDB(ASNDBFileName, asnPath); err != nil {
+			return nil, fmt.Errorf("下载 ASN 数据库失败: %w", err)
+		}
+	}
+	return openDBWithArch(asnPath)
+}
+
 // 解析数据库存放路径
 func resolveDBPath() (string, error) {
+	dir, err := resolveDBDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, CountryDBFileName), nil
+}
+
+// 解析数据库存放目录
+func resolveDBDir() (string, error) {
 	saver, err := method.NewLocalSaver()
 	if err != nil {
 		return "", err
@@ -114,7 +150,7 @@ func resolveDBPath() (string, error) {
 	if err := os.MkdirAll(maxminddbDir, 0o755); err != nil {
 		return "", fmt.Errorf("无法创建 MaxMind 输出目录: %w", err)
 	}
-	return filepath.Join(maxminddbDir, "GeoLite2-Country.mmdb"), nil
+	return maxminddbDir, nil
 }
 
 // 32 位程序使用从内存读取的方式
@@ -134,33 +170,52 @@ func openFromBytes(path string) (*maxminddb.Reader, error) {
 }
 
 // UpdateGeoLite2DB 检查并更新 GeoLite2 数据库
+//
+// ASN 数据库仅在已下载过(启用过)时一并更新。
 func UpdateGeoLite2DB() error {
-	dbPath, err := resolveDBPath()
+	dir, err := resolveDBDir()
 	if err != nil {
 		return fmt.Errorf("解析数据库路径失败: %w", err)
 	}
 
+	version, err := updateMMDB(CountryDBFileName, filepath.Join(dir, CountryDBFileName))
+	if err != nil {
+		return err
+	}
+	utils.SendNotifyGeoDBUpdate(version)
+
+	asnPath := filepath.Join(dir, ASNDBFileName)
+	if _, err := os.Stat(asnPath); err == nil {
+		if _, err := updateMMDB(ASNDBFileName, asnPath); err != nil {
+			slog.Warn(fmt.Sprintf("更新 %s 失败: %v", ASNDBFileName, err))
+		}
+	}
+	return nil
+}
+
+// updateMMDB 从 GeoLite2 release 下载指定数据库到 dbPath，失败时保留原文件，返回 release 版本
+func updateMMDB(name, dbPath string) (string, error) {
 	apiURL := "https://api.github.com/repos/mojolabs-id/GeoLite2-Database/releases/latest"
 
 	resp, err := http.Get(apiURL)
 	if err != nil {
-		return fmt.Errorf("获取 release 信息失败: %w", err)
+		return "", fmt.Errorf("获取 release 信息失败: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("GitHub API 状态码: %d", resp.StatusCode)
+		return "", fmt.Errorf("GitHub API 状态码: %d", resp.StatusCode)
 	}
 
 	var rel githubRelease
 	if err := json.NewDecoder(resp.Body).Decode(&rel); err != nil {
-		return fmt.Errorf("解析 release JSON 失败: %w", err)
+		return "", fmt.Errorf("解析 release JSON 失败: %w", err)
 	}
 
 	var downloadURL string
 	isGhProxy := utils.GetGhProxy()
 	for _, asset := range rel.Assets {
-		if asset.Name == "GeoLite2-Country.mmdb" {
+		if asset.Name == name {
 			downloadURL = asset.BrowserDownloadURL
 			if isGhProxy {
 				downloadURL = config.GlobalConfig.GithubProxy + asset.BrowserDownloadURL
@@ -169,14 +224,14 @@ func UpdateGeoLite2DB() error {
 		}
 	}
 	if downloadURL == "" {
-		return errors.New("未找到 GeoLite2-Country.mmdb 下载地址")
+		return "", fmt.Errorf("未找到 %s 下载地址", name)
 	}
 
 	// 备份原文件
 	bakPath := dbPath + ".bak"
 	if _, err := os.Stat(dbPath); err == nil {
 		if err := os.Rename(dbPath, bakPath); err != nil {
-			return fmt.Errorf("备份原文件失败: %w", err)
+			return "", fmt.Errorf("备份原文件失败: %w", err)
 		}
 	}
 
@@ -193,19 +248,19 @@ func UpdateGeoLite2DB() error {
 	}
 
 	if !success {
-		// 回退
+		// 回退，没有原文件时删除不完整的下载
 		if _, err := os.Stat(bakPath); err == nil {
 			_ = os.Rename(bakPath, dbPath)
+		} else {
+			_ = os.Remove(dbPath)
 		}
-		return errors.New("下载失败，已回退原文件")
+		return "", errors.New("下载失败，已回退原文件")
 	}
 
 	// 成功则删除备份
 	_ = os.Remove(bakPath)
-	slog.Info("GeoLite2-Country.mmdb 更新完成")
-	version := rel.TagName
-	utils.SendNotifyGeoDBUpdate(version)
-	return nil
+	slog.Info(name + " 更新完成")
+	return rel.TagName, nil
 }
 
 func downloadFile(url, path string) error {
@@ -0,0 +1,217 @@
+package check
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"log/slog"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 动态并发调整参数
+const (
+	adaptInterval    = 2 * time.Second
+	adaptMinSamples  = 20    // 窗口内测活样本少于此值时不调整
+	adaptCongested   = 0.15  // 超时率高出历史最低值的幅度，超过视为拥塞
+	adaptHealthy     = 0.05  // 超时率高出历史最低值不超过此幅度时允许增加
+	adaptDecrease    = 0.7   // 乘性减少系数
+	adaptMinGain     = 1.05  // 测速并发增加后吞吐量至少提升 5%，否则回退
+	goroutineLimit   = 20000 // 协程数量上限，超过时所有阶段减少并发
+	adaptStepDivisor = 10    // 加性增加步长为初始并发的 1/10
+)
+
+// gate 可在运行中调整容量的并发闸门
+type gate struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	limit  int
+	active int
+	max    int           // 工作协程数量，即并发上限
+	gauge  *atomic.Int32 // 对外展示的当前并发
+}
+
+func newGate(limit, max int, gauge *atomic.Int32) *gate {
+	g := &gate{limit: limit, max: max, gauge: gauge}
+	g.cond = sync.NewCond(&g.mu)
+	gauge.Store(int32(limit))
+	return g
+}
+
+func (g *gate) acquire() {
+	g.mu.Lock()
+	for g.active >= g.limit {
+		g.cond.Wait()
+	}
+	g.active++
+	g.mu.Unlock()
+}
+
+func (g *gate) release() {
+	g.mu.Lock()
+	g.active--
+	g.mu.Unlock()
+	g.cond.Signal()
+}
+
+// setLimit 调整并发上限，正在处理的任务不受影响
+func (g *gate) setLimit(n int) {
+	g.mu.Lock()
+	g.limit = n
+	g.mu.Unlock()
+	g.gauge.Store(int32(n))
+	g.cond.Broadcast()
+}
+
+func (g *gate) Limit() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.limit
+}
+
+// each 依次从 ch 取出任务，每个任务处理期间占用一个并发名额
+func (g *gate) each(ch <-chan *ProxyJob) iter.Seq[*ProxyJob] {
+	return func(yield func(*ProxyJob) bool) {
+		for {
+			g.acquire()
+			job, ok := <-ch
+			if !ok {
+				g.release()
+				return
+			}
+			cont := yield(job)
+			g.release()
+			if !cont {
+				return
+			}
+		}
+	}
+}
+
+// stageCtl 单个阶段的并发控制
+type stageCtl struct {
+	name     string
+	gate     *gate
+	min, max int
+	step     int
+	backlog  func() int // 等待处理的任务数
+
+	// 测速阶段：上次调整后的吞吐量
+	lastTput  float64
+	increased bool
+}
+
+// newStageCtl 根据初始并发和配置的上下限创建阶段控制器
+func (s *Session) newStageCtl(name string, initial int, gauge *atomic.Int32, backlog func() int) *stageCtl {
+	initial = max(initial, 1)
+	lo, hi := initial, initial
+	if s.opts.AdaptiveConcurrency {
+		lo = min(max(s.opts.AdaptiveMinConcurrent, 1), initial)
+		hi = initial * 2
+		if s.opts.AdaptiveMaxConcurrent > 0 {
+			hi = max(s.opts.AdaptiveMaxConcurrent, initial)
+		}
+		// 限速时测速并发由带宽决定，不再增加
+		if name == StageSpeed && s.opts.TotalSpeedLimit > 0 {
+			hi = initial
+		}
+	}
+	return &stageCtl{
+		name:    name,
+		gate:    newGate(initial, hi, gauge),
+		min:     lo,
+		max:     hi,
+		step:    max(initial/adaptStepDivisor, 1),
+		backlog: backlog,
+	}
+}
+
+// aimd 加性增加、乘性减少
+func aimd(cur, lo, hi, step int, decrease, increase bool) int {
+	switch {
+	case decrease:
+		cur = int(float64(cur) * adaptDecrease)
+	case increase:
+		cur += step
+	}
+	return min(max(cur, lo), hi)
+}
+
+// adaptWindow 测活阶段一个调整周期内的统计
+type adaptWindow struct {
+	done     atomic.Int64
+	timeouts atomic.Int64
+}
+
+// observeAlive 记录测活结果，reason 为空表示成功
+func (pc *ProxyChecker) observeAlive(reason string) {
+	if pc.adapt == nil {
+		return
+	}
+	pc.adapt.done.Add(1)
+	if reason == ReasonDialTimeout || reason == ReasonTimeout {
+		pc.adapt.timeouts.Add(1)
+	}
+}
+
+// adjustConcurrency 周期性根据测活超时率、协程数量和测速吞吐量调整各阶段并发
+func (pc *ProxyChecker) adjustConcurrency(ctx context.Context) {
+	ticker := time.NewTicker(adaptInterval)
+	defer ticker.Stop()
+
+	floor := 1.0 // 历史最低超时率，代表失效节点本身的超时比例
+	lastBytes := pc.stats.TotalBytes.Load()
+	lastTime := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		done := pc.adapt.done.Swap(0)
+		timeouts := pc.adapt.timeouts.Swap(0)
+		overload := runtime.NumGoroutine() > goroutineLimit
+
+		congested, healthy := false, false
+		if done >= adaptMinSamples {
+			rate := float64(timeouts) / float64(done)
+			floor = min(floor, rate)
+			congested = rate > floor+adaptCongested
+			healthy = rate <= floor+adaptHealthy
+		}
+
+		now := time.Now()
+		bytes := pc.stats.TotalBytes.Load()
+		tput := float64(bytes-lastBytes) / now.Sub(lastTime).Seconds()
+		lastBytes, lastTime = bytes, now
+
+		for _, c := range pc.stages {
+			cur := c.gate.Limit()
+			pending := c.backlog() > 0
+			var dec, inc bool
+			switch c.name {
+			case StageSpeed:
+				// 测速阶段以吞吐量为准：增加并发后吞吐量没有提升则回退
+				dec = overload || (c.increased && tput < c.lastTput*adaptMinGain)
+				inc = !dec && pending
+				c.lastTput = tput
+			case StageAlive:
+				dec = overload || congested
+				inc = !dec && pending && healthy
+			default:
+				dec = overload || congested
+				inc = !dec && pending
+			}
+			next := aimd(cur, c.min, c.max, c.step, dec, inc)
+			c.increased = next > cur
+			if next != cur {
+				c.gate.setLimit(next)
+				slog.Debug(fmt.Sprintf("调整%s并发: %d -> %d", c.name, cur, next),
+					"timeouts", timeouts, "samples", done, "goroutines", runtime.NumGoroutine())
+			}
+		}
+	}
+}
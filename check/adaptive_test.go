@@ -0,0 +1,88 @@
+package check
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAIMD(t *testing.T) {
+	tests := []struct {
+		cur, lo, hi, step int
+		dec, inc          bool
+		want              int
+	}{
+		{100, 4, 200, 10, false, true, 110},
+		{195, 4, 200, 10, false, true, 200}, // 不超过上限
+		{100, 4, 200, 10, true, false, 70},
+		{5, 4, 200, 10, true, false, 4}, // 不低于下限
+		{100, 4, 200, 10, true, true, 70},
+		{100, 4, 200, 10, false, false, 100},
+	}
+	for _, tt := range tests {
+		if got := aimd(tt.cur, tt.lo, tt.hi, tt.step, tt.dec, tt.inc); got != tt.want {
+			t.Errorf("aimd(%d, dec=%v, inc=%v) = %d, want %d", tt.cur, tt.dec, tt.inc, got, tt.want)
+		}
+	}
+}
+
+func TestGateLimit(t *testing.T) {
+	var gauge atomic.Int32
+	g := newGate(2, 8, &gauge)
+	ch := make(chan *ProxyJob, 64)
+	for range 64 {
+		ch <- &ProxyJob{}
+	}
+	close(ch)
+
+	var active, peak atomic.Int32
+	var wg sync.WaitGroup
+	for range g.max {
+		wg.Go(func() {
+			for range g.each(ch) {
+				n := active.Add(1)
+				for {
+					p := peak.Load()
+					if n <= p || peak.CompareAndSwap(p, n) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond)
+				active.Add(-1)
+			}
+		})
+	}
+	wg.Wait()
+	if p := peak.Load(); p > 2 {
+		t.Errorf("peak concurrency = %d, want <= 2", p)
+	}
+
+	g.setLimit(5)
+	if gauge.Load() != 5 || g.Limit() != 5 {
+		t.Errorf("limit = %d, gauge = %d, want 5", g.Limit(), gauge.Load())
+	}
+}
+
+func TestStageCtlBounds(t *testing.T) {
+	s := &Session{stats: &Stats{}, opts: Options{
+		AdaptiveConcurrency:   true,
+		AdaptiveMinConcurrent: 4,
+		TotalSpeedLimit:       10,
+	}}
+	alive := s.newStageCtl(StageAlive, 100, &s.stats.AliveConcurrent, func() int { return 0 })
+	if alive.min != 4 || alive.max != 200 || alive.step != 10 || alive.gate.max != 200 {
+		t.Errorf("alive bounds = %d..%d step %d", alive.min, alive.max, alive.step)
+	}
+	// 限速时测速并发不增加
+	speed := s.newStageCtl(StageSpeed, 8, &s.stats.SpeedConcurrent, func() int { return 0 })
+	if speed.max != 8 {
+		t.Errorf("speed max = %d, want 8", speed.max)
+	}
+
+	s.opts.AdaptiveConcurrency = false
+	fixed := s.newStageCtl(StageMedia, 50, &s.stats.MediaConcurrent, func() int { return 0 })
+	if fixed.min != 50 || fixed.max != 50 || s.stats.MediaConcurrent.Load() != 50 {
+		t.Errorf("fixed bounds = %d..%d", fixed.min, fixed.max)
+	}
+}
@@ -0,0 +1,79 @@
+package check
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCheckAliveQuorum(t *testing.T) {
+	var flaky atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/down", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	// 第一次请求失败，之后成功
+	mux.HandleFunc("/flaky", func(w http.ResponseWriter, r *http.Request) {
+		if flaky.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		targets []string
+		quorum  int
+		retries int
+		wantErr bool
+		passed  int
+	}{
+		{"单目标通过", []string{srv.URL + "/ok"}, 1, 0, false, 1},
+		{"多数通过", []string{srv.URL + "/down", srv.URL + "/ok", srv.URL + "/ok?b"}, 2, 0, false, 2},
+		{"未达到要求", []string{srv.URL + "/ok", srv.URL + "/down"}, 2, 0, true, 1},
+		{"提前结束", []string{srv.URL + "/down", srv.URL + "/ok"}, 2, 0, true, 0},
+		{"重试后通过", []string{srv.URL + "/flaky"}, 1, 1, false, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flaky.Store(0)
+			s := NewSession(Options{
+				AliveTargets: tt.targets,
+				AliveQuorum:  tt.quorum,
+				AliveRetries: tt.retries,
+			})
+			job := &ProxyJob{Client: &ProxyClient{Client: srv.Client()}}
+			err := s.checkAlive(context.Background(), job)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkAlive() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := len(job.Result.AliveTargets); got != tt.passed {
+				t.Errorf("passed targets = %v, want %d", job.Result.AliveTargets, tt.passed)
+			}
+			if err != nil && classifyError(err) != ReasonHTTPStatus {
+				t.Errorf("classifyError() = %s, want %s", classifyError(err), ReasonHTTPStatus)
+			}
+		})
+	}
+}
+
+func TestParseAliveTargets(t *testing.T) {
+	targets, quorum := parseAliveTargets([]string{"gstatic", "cf-trace", "ftp://x", "GSTATIC"}, 5)
+	names := make([]string, 0, len(targets))
+	for _, t := range targets {
+		names = append(names, t.Name)
+	}
+	if !slices.Equal(names, []string{"gstatic", "cf-trace"}) || quorum != 2 {
+		t.Errorf("parseAliveTargets() = %v, %d", names, quorum)
+	}
+
+	targets, quorum = parseAliveTargets(nil, 0)
+	if len(targets) != 1 || targets[0].Name != "gstatic" || quorum != 1 {
+		t.Errorf("default targets = %v, %d", targets, quorum)
+	}
+}
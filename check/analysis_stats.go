@@ -3,15 +3,18 @@ package check
 import (
 	"fmt"
 	"log/slog"
+	"maps"
 	"regexp"
 	"slices"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/sinspired/subs-check-pro/config"
+	"github.com/sinspired/subs-check-pro/check/health"
+	"github.com/sinspired/subs-check-pro/check/platform"
 	proxyutils "github.com/sinspired/subs-check-pro/proxy"
 	"github.com/sinspired/subs-check-pro/save/method"
+	"github.com/sinspired/subs-check-pro/utils"
 )
 
 var (
@@ -29,14 +32,6 @@ var (
 
 	// 通用国旗匹配: 匹配 🇺🇸, 🇯🇵 等
 	reFlag = regexp.MustCompile(`[\x{1F1E6}-\x{1F1FF}]{2}`)
-
-	// 流媒体解锁特征
-	reMediaGPT = regexp.MustCompile(`(?i)GPT`)
-	reMediaGM  = regexp.MustCompile(`(?i)GM|Global`)
-	reMediaTK  = regexp.MustCompile(`(?i)TK|TikTok`)
-	reMediaYT  = regexp.MustCompile(`(?i)YT|YouTube`)
-	reMediaNF  = regexp.MustCompile(`(?i)NF|Netflix`)
-	reMediaDis = regexp.MustCompile(`(?i)D\+|Disney`)
 )
 
 // AnalysisStats 统计结构
@@ -48,7 +43,9 @@ type AnalysisStats struct {
 	CFCon     map[string]int // ¹⁺ (一致)
 	CFBlock   map[string]int // ⁻¹ (proxyIP 异常)
 	NonCF     map[string]int // ² (独立VPS)
-	Media     map[string]int
+	Media     map[string]int // 流媒体及其他平台解锁
+	AI        map[string]int // AI 解锁
+	Hijacked  map[string]int // 流量劫持：mitm/injected/redirected
 }
 
 func newAnalysisStats() *AnalysisStats {
@@ -60,108 +57,113 @@ func newAnalysisStats() *AnalysisStats {
 		CFBlock:   make(map[string]int),
 		NonCF:     make(map[string]int),
 		Media:     make(map[string]int),
+		AI:        make(map[string]int),
+		Hijacked:  make(map[string]int),
 	}
 }
 
-// GenerateAnalysisReport 生成节点质量分析报告
-func (pc *ProxyChecker) GenerateAnalysisReport() {
-	// 统计可用节点数量
-	for _, result := range pc.results {
-		if result.Proxy != nil {
-			if subURL, ok := result.Proxy["sub_url"].(string); ok {
-				stats := proxyutils.SubStats[subURL]
-				stats.Success++
-				proxyutils.SubStats[subURL] = stats
+// add 将一个可用结果计入统计，lookup 用于按名称查找平台检测器
+func (s *AnalysisStats) add(result *Result, lookup func(string) (platform.Checker, bool)) {
+	pType, _ := result.Proxy["type"].(string)
+	name, _ := result.Proxy["name"].(string)
+
+	s.Total++
+	s.Types[pType]++
+
+	// 节点属性识别
+	hasTag := false
+	// 由于增加了前缀匹配，submatch 的 index 1 才是真正的国家代码
+	if m := reCFInconsistent.FindStringSubmatch(name); len(m) > 1 {
+		s.CFIncon[m[1]]++
+		s.Countries[m[1]]++
+		hasTag = true
+	}
+	if m := reCFConsistent.FindStringSubmatch(name); len(m) > 1 {
+		s.CFCon[m[1]]++
+		s.Countries[m[1]]++
+		hasTag = true
+	}
+	if m := reCFBlock.FindStringSubmatch(name); len(m) > 1 {
+		s.CFBlock[m[1]]++
+		s.Countries[m[1]]++
+		hasTag = true
+	}
+	if m := reNonCF.FindStringSubmatch(name); len(m) > 1 {
+		s.NonCF[m[1]]++
+		s.Countries[m[1]]++
+		hasTag = true
+	}
+
+	// 如果没有上角标，从国旗 Emoji 提取
+	if !hasTag {
+		if flags := reFlag.FindAllString(name, -1); len(flags) > 0 {
+			for _, f := range flags {
+				code := flagToCode(f)
+				if code != "" {
+					s.Countries[code]++
+				}
 			}
 		}
 	}
 
-	globalAnalysis := newAnalysisStats()
-	subAnalysis := make(map[string]*AnalysisStats)
+	if result.Integrity != "" {
+		s.Hijacked[result.Integrity]++
+	}
 
-	for _, result := range pc.results {
-		if result.Proxy == nil {
+	// 平台解锁，按注册表分类统计
+	for plat, pr := range result.Platforms {
+		checker, ok := lookup(plat)
+		if !ok || !pr.OK {
 			continue
 		}
+		label := platform.LabelOf(checker, pr)
+		if checker.Category() == platform.CategoryAI {
+			s.AI[label]++
+		} else {
+			s.Media[label]++
+		}
+	}
+}
 
-		subURL, _ := result.Proxy["sub_url"].(string)
-		pType, _ := result.Proxy["type"].(string)
-		name, _ := result.Proxy["name"].(string)
-
-		update := func(s *AnalysisStats) {
-			s.Total++
-			s.Types[pType]++
-
-			// 节点属性识别
-			hasTag := false
-			// 由于增加了前缀匹配，submatch 的 index 1 才是真正的国家代码
-			if m := reCFInconsistent.FindStringSubmatch(name); len(m) > 1 {
-				s.CFIncon[m[1]]++
-				s.Countries[m[1]]++
-				hasTag = true
-			}
-			if m := reCFConsistent.FindStringSubmatch(name); len(m) > 1 {
-				s.CFCon[m[1]]++
-				s.Countries[m[1]]++
-				hasTag = true
-			}
-			if m := reCFBlock.FindStringSubmatch(name); len(m) > 1 {
-				s.CFBlock[m[1]]++
-				s.Countries[m[1]]++
-				hasTag = true
-			}
-			if m := reNonCF.FindStringSubmatch(name); len(m) > 1 {
-				s.NonCF[m[1]]++
-				s.Countries[m[1]]++
-				hasTag = true
-			}
-
-			// 如果没有上角标，从国旗 Emoji 提取
-			if !hasTag {
-				if flags := reFlag.FindAllString(name, -1); len(flags) > 0 {
-					for _, f := range flags {
-						code := flagToCode(f)
-						if code != "" {
-							s.Countries[code]++
-						}
-					}
-				}
-			}
+// collectSubStats 统计各订阅的可用节点数量和节点分布
+//
+// 须在出口去重前调用，被去重的节点仍计入其订阅，避免共用出口的订阅被判定为无可用节点。
+func (pc *ProxyChecker) collectSubStats() {
+	pc.subAnalysis = make(map[string]*AnalysisStats)
+	for i := range pc.results {
+		result := &pc.results[i]
+		if result.Proxy == nil {
+			continue
+		}
+		subURL, ok := result.Proxy["sub_url"].(string)
+		if !ok {
+			continue
+		}
+		stats := proxyutils.SubStats[subURL]
+		stats.Success++
+		proxyutils.SubStats[subURL] = stats
 
-			// AI解锁
-			if reMediaGPT.MatchString(name) {
-				if strings.Contains(name, "GPT⁺") {
-					s.Media["GPT+"]++
-				} else {
-					s.Media["GPT"]++
-				}
-			}
-			if reMediaGM.MatchString(name) {
-				s.Media["Gemini"]++
-			}
-			// 流媒体解锁
-			if reMediaNF.MatchString(name) {
-				s.Media["Netflix"]++
-			}
-			if reMediaYT.MatchString(name) {
-				s.Media["YouTube"]++
-			}
-			if reMediaTK.MatchString(name) {
-				s.Media["TikTok"]++
-			}
-			if reMediaDis.MatchString(name) {
-				s.Media["Disney+"]++
-			}
+		if subURL == "" {
+			continue
+		}
+		if _, ok := pc.subAnalysis[subURL]; !ok {
+			pc.subAnalysis[subURL] = newAnalysisStats()
 		}
+		pc.subAnalysis[subURL].add(result, pc.lookup)
+	}
+}
 
-		update(globalAnalysis)
-		if subURL != "" {
-			if _, ok := subAnalysis[subURL]; !ok {
-				subAnalysis[subURL] = newAnalysisStats()
-			}
-			update(subAnalysis[subURL])
+// GenerateAnalysisReport 生成节点质量分析报告
+//
+// 全局统计基于去重后的结果，订阅统计使用 collectSubStats 在去重前的结果。
+func (pc *ProxyChecker) GenerateAnalysisReport() {
+	globalAnalysis := newAnalysisStats()
+	for i := range pc.results {
+		if pc.results[i].Proxy != nil {
+			globalAnalysis.add(&pc.results[i], pc.lookup)
 		}
 	}
+	subAnalysis := pc.subAnalysis
 
 	// 排序
 	// sortedURLs := make([]string, 0, len(subAnalysis))
@@ -182,39 +184,40 @@ func (pc *ProxyChecker) GenerateAnalysisReport() {
 	})
 
 	// 并保存订阅成功率统计并打印成功率过低日志
-	checkSubsSuccessRate(subAnalysis, sortedURLs)
+	pc.checkSubsSuccessRate(subAnalysis, sortedURLs)
 	// 保存深度分析报告
-	saveDetailedAnalysis(globalAnalysis, subAnalysis, sortedURLs)
+	pc.saveDetailedAnalysis(globalAnalysis, subAnalysis, sortedURLs, pc.healthDB, pc.failures)
 
 	// 终端输出总结
-	logSummary(globalAnalysis)
+	pc.logSummary(globalAnalysis)
 }
 
 // saveDetailedAnalysis 输出包含总结和可视化数据的报告
-func saveDetailedAnalysis(global *AnalysisStats, subs map[string]*AnalysisStats, sortedURLs []string) {
+func (pc *ProxyChecker) saveDetailedAnalysis(global *AnalysisStats, subs map[string]*AnalysisStats, sortedURLs []string, db *health.Store, failures *FailureStats) {
 	var sb strings.Builder
 	sb.WriteString("# 检测结果分析报告\n")
 	sb.WriteString(fmt.Sprintf("# 生成时间: %s\n\n", time.Now().Format(time.DateTime)))
 
 	// 1. 总结性文案 (用于快速预览)
 	sb.WriteString("summary: |\n")
-	summary := generateSummary(global)
+	summary := pc.generateSummary(global)
 	sb.WriteString("  " + summary + "\n\n")
 
 	sb.WriteString("check_info:\n")
-	sb.WriteString("  check_time: " + prettyTime(CheckStartTime) + "\n")
-	sb.WriteString("  check_time_raw: " + CheckStartTime.Format(time.RFC3339) + "\n")
-	sb.WriteString("  check_end_time_raw: " + CheckEndTime.Format(time.RFC3339) + "\n")
-	sb.WriteString("  check_duration: " + prettyDuration(CheckDuration) + "\n")
-	sb.WriteString("  check_duration_raw: " + strconv.FormatInt(int64(CheckDuration.Seconds()), 10) + "\n")
-	sb.WriteString("  check_count: " + prettyTotal(int(Progress.Load())) + "\n")
-	sb.WriteString("  check_count_raw: " + strconv.Itoa(int(Progress.Load())) + "\n")
-	sb.WriteString("  check_traffic: " + CheckTraffic + "\n")
-	sb.WriteString("  check_traffic_raw: " + strconv.FormatUint(TotalBytes.Load(), 10) + "\n")
+	st := pc.stats
+	sb.WriteString("  check_time: " + prettyTime(st.StartTime) + "\n")
+	sb.WriteString("  check_time_raw: " + st.StartTime.Format(time.RFC3339) + "\n")
+	sb.WriteString("  check_end_time_raw: " + st.EndTime.Format(time.RFC3339) + "\n")
+	sb.WriteString("  check_duration: " + prettyDuration(st.Duration) + "\n")
+	sb.WriteString("  check_duration_raw: " + strconv.FormatInt(int64(st.Duration.Seconds()), 10) + "\n")
+	sb.WriteString("  check_count: " + prettyTotal(int(st.Progress.Load())) + "\n")
+	sb.WriteString("  check_count_raw: " + strconv.Itoa(int(st.Progress.Load())) + "\n")
+	sb.WriteString("  check_traffic: " + st.Traffic + "\n")
+	sb.WriteString("  check_traffic_raw: " + strconv.FormatUint(st.TotalBytes.Load(), 10) + "\n")
 	
 	var speedText string
-	if speedON {
-		speedText = fmt.Sprintf("%d", config.GlobalConfig.MinSpeed)
+	if pc.speedON {
+		speedText = fmt.Sprintf("%d", pc.opts.MinSpeed)
 	} else {
 		speedText = "0"
 	}
@@ -234,6 +237,22 @@ func saveDetailedAnalysis(global *AnalysisStats, subs map[string]*AnalysisStats,
 	sb.WriteString("      inconsistent_⁰:" + formatMap(global.CFIncon, "        ") + "\n")
 	sb.WriteString("      blocked_⁻¹:" + formatMap(global.CFBlock, "        ") + "\n")
 	sb.WriteString("    vps_details_²:" + formatMap(global.NonCF, "      ") + "\n")
+	sb.WriteString("    hijacked:" + formatMap(global.Hijacked, "      ") + "\n")
+
+	// 失败原因统计（阶段/原因）
+	if failures != nil {
+		sb.WriteString(formatFailureSummary(failures))
+	}
+
+	// 节点健康统计（近 7 天）
+	if db != nil {
+		sb.WriteString(formatHealthSummary(db))
+	}
+
+	// 出口分组统计，未开启去重且没有已知出口时不输出
+	if pc.exits != nil && (pc.exits.Mode != ExitDedupOff || pc.exits.Groups > 0) {
+		sb.WriteString(formatExitSummary(pc.exits))
+	}
 
 	// 3. 订阅排行与明细
 	sb.WriteString("\nsubs_ranking:\n")
@@ -248,19 +267,173 @@ func saveDetailedAnalysis(global *AnalysisStats, subs map[string]*AnalysisStats,
 		if st != nil {
 			sb.WriteString(fmt.Sprintf("  - url: %s\n", u))
 			sb.WriteString(fmt.Sprintf("    stats: { rate: %.4f%%, success: %d, total: %d }\n", rate*100, pStat.Success, pStat.Total))
+			if pStat.Cache != "" {
+				sb.WriteString(fmt.Sprintf("    cache: %s\n", pStat.Cache))
+			}
+			// 出口去重移除的节点仍计入 success
+			if pc.exits != nil && pc.exits.RemovedBySub[u] > 0 {
+				sb.WriteString(fmt.Sprintf("    exit_dedup_removed: %d\n", pc.exits.RemovedBySub[u]))
+			}
 			sb.WriteString(fmt.Sprintf("    protocols: { %s }\n", formatMapToInline(st.Types)))
 			sb.WriteString(fmt.Sprintf("    top_locations: [%s]\n", getTopKeys(st.Countries, 3)))
+			if failures != nil {
+				sb.WriteString(fmt.Sprintf("    failures: { %s }\n", formatMapToInline(failures.Sub(u))))
+			}
 		} else {
 			sbBad.WriteString(fmt.Sprintf("  - url: %s\n", u))
 			sbBad.WriteString(fmt.Sprintf("    stats: { rate: %.4f%%, success: %d, total: %d }\n", rate*100, pStat.Success, pStat.Total))
+			if pStat.Cache != "" {
+				sbBad.WriteString(fmt.Sprintf("    cache: %s\n", pStat.Cache))
+			}
+			if failures != nil {
+				sbBad.WriteString(fmt.Sprintf("    failures: { %s }\n", formatMapToInline(failures.Sub(u))))
+			}
 		}
 	}
 
-	_ = method.SaveToStats([]byte(sb.String()+sbBad.String()), "subs-analysis.yaml", "分析结果")
+	_ = method.SaveToStats([]byte(sb.String()+sbBad.String()+formatSubQuarantine()+formatSubUsage(pc.stats.StartTime)), "subs-analysis.yaml", "分析结果")
+}
+
+// formatSubUsage 输出本次获取到的订阅流量和到期信息
+func formatSubUsage(since time.Time) string {
+	usages := proxyutils.SubUsages(since)
+	if len(usages) == 0 {
+		return ""
+	}
+
+	now := time.Now()
+	var sb strings.Builder
+	sb.WriteString("\nsubs_usage:\n")
+	for _, u := range usages {
+		sb.WriteString(fmt.Sprintf("  - url: %s\n", u.URL))
+		sb.WriteString(fmt.Sprintf("    name: %q\n", u.Label))
+		sb.WriteString(fmt.Sprintf("    status: %s\n", u.Status))
+		sb.WriteString(fmt.Sprintf("    summary: %q\n", u.Summary(now)))
+		if u.Total > 0 {
+			sb.WriteString(fmt.Sprintf("    traffic: { used: %s, total: %s, percent: %.1f%% }\n",
+				utils.FormatTraffic(uint64(u.Used())), utils.FormatTraffic(uint64(u.Total)), u.UsedPercent()))
+		}
+		if u.Expire > 0 {
+			sb.WriteString("    expire: " + time.Unix(u.Expire, 0).Format(time.DateTime) + "\n")
+		}
+	}
+	return sb.String()
+}
+
+// formatSubQuarantine 输出隔离中的订阅
+func formatSubQuarantine() string {
+	records, err := proxyutils.SubHealthRecords()
+	if err != nil {
+		return ""
+	}
+
+	var sb strings.Builder
+	for _, r := range records {
+		if !r.Quarantined() {
+			break
+		}
+		sb.WriteString(fmt.Sprintf("  - url: %s\n", r.URL))
+		sb.WriteString(fmt.Sprintf("    reason: %s\n", r.Reason))
+		sb.WriteString(fmt.Sprintf("    skip_runs: %d\n", r.SkipRuns))
+		sb.WriteString(fmt.Sprintf("    quarantines: %d\n", r.Quarantines))
+		sb.WriteString(fmt.Sprintf("    avg_rate: %.4f%%\n", r.AvgSuccessRate*100))
+	}
+	if sb.Len() == 0 {
+		return ""
+	}
+	return "\nsubs_quarantine:\n" + sb.String()
+}
+
+// 健康统计参数：近 7 天至少检测 3 次，通过率不低于 80% 视为稳定
+const (
+	healthWindowDays   = 7
+	healthMinChecks    = 3
+	healthReliableRate = 0.8
+	healthTopN         = 10
+)
+
+// formatHealthSummary 输出节点健康数据库的近期稳定性统计
+func formatHealthSummary(db *health.Store) string {
+	since := time.Now().AddDate(0, 0, -healthWindowDays)
+	candidates := db.Reliable(since, healthMinChecks, 0)
+
+	reliable := 0
+	for i := range candidates {
+		checks, passes := candidates[i].WindowStats(since)
+		if float64(passes)/float64(checks) >= healthReliableRate {
+			reliable++
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\n  node_health:\n")
+	sb.WriteString(fmt.Sprintf("    tracked: %d\n", db.Len()))
+	sb.WriteString(fmt.Sprintf("    window_days: %d\n", healthWindowDays))
+	sb.WriteString(fmt.Sprintf("    reliable: %d\n", reliable))
+	sb.WriteString("    top_reliable:")
+	if len(candidates) == 0 {
+		sb.WriteString(" []\n")
+		return sb.String()
+	}
+	sb.WriteString("\n")
+	for _, r := range candidates[:min(healthTopN, len(candidates))] {
+		checks, passes := r.WindowStats(since)
+		sb.WriteString(fmt.Sprintf("      - { name: %q, type: %s, pass: %d/%d, speed: %d, latency: %d, country: %q }\n",
+			r.Name, r.Type, passes, checks, r.LastSpeed, r.LastLatency, r.LastCountry))
+	}
+	return sb.String()
+}
+
+// formatExitSummary 输出共用出口的节点分组
+func formatExitSummary(e *exitStats) string {
+	dup := 0
+	for _, c := range e.Clusters {
+		dup += c.Size
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\n  exit_clusters:\n")
+	sb.WriteString(fmt.Sprintf("    mode: %s\n", e.Mode))
+	sb.WriteString(fmt.Sprintf("    group_by: %s\n", e.By))
+	sb.WriteString(fmt.Sprintf("    exits: %d\n", e.Groups))
+	sb.WriteString(fmt.Sprintf("    shared_exits: %d\n", len(e.Clusters)))
+	sb.WriteString(fmt.Sprintf("    shared_nodes: %d\n", dup))
+	sb.WriteString(fmt.Sprintf("    unknown_exit: %d\n", e.Unknown))
+	sb.WriteString(fmt.Sprintf("    removed: %d\n", e.Removed))
+	sb.WriteString("    top_clusters:")
+	if len(e.Clusters) == 0 {
+		sb.WriteString(" []\n")
+		return sb.String()
+	}
+	sb.WriteString("\n")
+	for _, c := range e.Clusters[:min(exitClusterTopN, len(e.Clusters))] {
+		sb.WriteString(fmt.Sprintf("      - { exit: %q, size: %d, kept: %d }\n", c.Key, c.Size, c.Kept))
+	}
+	return sb.String()
+}
+
+// formatFailureSummary 输出失败原因汇总及按协议的分布
+func formatFailureSummary(f *FailureStats) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var sb strings.Builder
+	sb.WriteString("\n  failure_reasons:" + formatMap(f.Total, "    ") + "\n")
+	sb.WriteString("  failure_by_protocol:")
+	if len(f.ByType) == 0 {
+		sb.WriteString(" {}\n")
+		return sb.String()
+	}
+	sb.WriteString("\n")
+	types := slices.Sorted(maps.Keys(f.ByType))
+	for _, t := range types {
+		sb.WriteString(fmt.Sprintf("    %s: { %s }\n", t, formatMapToInline(f.ByType[t])))
+	}
+	return sb.String()
 }
 
 // generateSummary 生成单段落详细摘要
-func generateSummary(s *AnalysisStats) string {
+func (pc *ProxyChecker) generateSummary(s *AnalysisStats) string {
 	if s.Total == 0 {
 		return "未探测到有效节点数据，请检查订阅源。"
 	}
@@ -281,12 +454,12 @@ func generateSummary(s *AnalysisStats) string {
 	}
 
 	// 3. 分类获取流媒体和 AI 的前几名
-	topMedia := getTopFiltered(s.Media, []string{"Netflix", "YouTube", "Disney+", "TikTok"}, 5)
-	topAI := getTopFiltered(s.Media, []string{"GPT", "GPT+", "Gemini"}, 3)
+	topMedia := getTopCounts(s.Media, 5)
+	topAI := getTopCounts(s.AI, 3)
 
 	var speedText string
-	if speedON {
-		speedText = fmt.Sprintf("，设置速度下限 %d KB/s", config.GlobalConfig.MinSpeed)
+	if pc.speedON {
+		speedText = fmt.Sprintf("，设置速度下限 %d KB/s", pc.opts.MinSpeed)
 	} else {
 		speedText = "，未开启下载测速"
 	}
@@ -297,8 +470,8 @@ func generateSummary(s *AnalysisStats) string {
 			"%s [CF 中转 %.1f%%, VPS %.1f%%]; "+
 			"流媒体解锁: [%s]; AI 解锁[%s]; "+
 			"代理协议: %s。",
-		prettyDuration(CheckDuration),
-		CheckTraffic,
+		prettyDuration(pc.stats.Duration),
+		pc.stats.Traffic,
 		prettyTotal(s.Total),
 		speedText, len(s.Countries), topCountry,
 		lineFeature, cfRatio, vpsRatio,
@@ -308,7 +481,7 @@ func generateSummary(s *AnalysisStats) string {
 }
 
 // logSummary 终端结构化输出
-func logSummary(s *AnalysisStats) {
+func (pc *ProxyChecker) logSummary(s *AnalysisStats) {
 	if s.Total == 0 {
 		slog.Warn("分析完成：未发现有效节点")
 		return
@@ -318,11 +491,11 @@ func logSummary(s *AnalysisStats) {
 	vpsRatio := float64(getSum(s.NonCF)) / float64(max(1, s.Total)) * 100
 
 	slog.Info("检测摘要",
-		"耗时", prettyDuration(CheckDuration),
+		"耗时", prettyDuration(pc.stats.Duration),
 		"CF", fmt.Sprintf("%.0f%%", cfRatio),
 		"VPS", fmt.Sprintf("%.0f%%", vpsRatio),
-		// "媒体解锁", getTopFiltered(s.Media, []string{"Netflix", "YouTube", "Disney+", "TikTok"}, 5),
-		// "AI解锁", getTopFiltered(s.Media, []string{"GPT", "GPT+", "Gemini"}, 3),
+		// "媒体解锁", getTopCounts(s.Media, 5),
+		// "AI解锁", getTopCounts(s.AI, 3),
 		"协议", getTopKeys(s.Types, 10),
 	)
 
@@ -354,23 +527,15 @@ func prettyTotal(n int) string {
 	return fmt.Sprintf("%d", n)
 }
 
-// getTopFiltered 根据白名单过滤并返回前 N 个统计项
-func getTopFiltered(m map[string]int, filter []string, limit int) string {
+// getTopCounts 返回前 N 个统计项，格式 "Netflix:10, YouTube:8"
+func getTopCounts(m map[string]int, limit int) string {
 	type kv struct {
 		K string
 		V int
 	}
 	var filtered []kv
-	filterMap := make(map[string]bool)
-	for _, f := range filter {
-		filterMap[f] = true
-	}
-
 	for k, v := range m {
-		// 检查是否在过滤名单内 (如果是 GPT-US 等前缀匹配也可在此调整)
-		if filterMap[k] || strings.HasPrefix(k, "YT") || strings.HasPrefix(k, "TK") {
-			filtered = append(filtered, kv{k, v})
-		}
+		filtered = append(filtered, kv{k, v})
 	}
 
 	slices.SortFunc(filtered, func(a, b kv) int { return b.V - a.V })
@@ -458,8 +623,8 @@ func getSum(m map[string]int) int {
 }
 
 // checkSubsSuccessRate 将成功率筛选与协议统计整合输出
-func checkSubsSuccessRate(subs map[string]*AnalysisStats, sortedURLs []string) {
-	threshold := config.GlobalConfig.SuccessRate
+func (pc *ProxyChecker) checkSubsSuccessRate(subs map[string]*AnalysisStats, sortedURLs []string) {
+	threshold := pc.opts.SuccessRate
 	var goodPart, lowPart, zeroPart strings.Builder
 
 	// 1. 遍历并分类
@@ -492,6 +657,11 @@ func checkSubsSuccessRate(subs map[string]*AnalysisStats, sortedURLs []string) {
 			protoStr = "[" + strings.Join(protoParts, "; ") + "]"
 		}
 
+		// 回退到缓存内容的订阅单独标记
+		if pStat.Cache == proxyutils.CacheFallback {
+			protoStr += " [cache]"
+		}
+
 		// 格式化行：- URL # 46.667% (7/15) ; vless: 8
 		line := fmt.Sprintf("  - %s # %.4f%% (%d/%d)%s\n", u, rate*100, pStat.Success, pStat.Total, protoStr)
 
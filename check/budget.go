@@ -0,0 +1,220 @@
+package check
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sinspired/subs-check-pro/utils"
+)
+
+// TrafficUsageFileName 当月流量用量文件，保存在 output/stats 目录
+const TrafficUsageFileName = "traffic-usage.json"
+
+// budgetReserveRatio 剩余预算低于总预算的该比例时停止测速
+const budgetReserveRatio = 0.1
+
+// usageFlushInterval 检测过程中当月用量的落盘间隔
+const usageFlushInterval = 30 * time.Second
+
+// ErrTrafficBudgetExhausted 本月流量预算已用完
+var ErrTrafficBudgetExhausted = errors.New("本月流量预算已用完")
+
+// TrafficUsage 一个自然月内检测消耗的流量
+type TrafficUsage struct {
+	Month     string    `json:"month"` // 2006-01
+	Up        uint64    `json:"up"`
+	Down      uint64    `json:"down"`
+	UpdatedAt time.Time `json:"updated_at,omitzero"`
+}
+
+// Total 返回上下行合计
+func (u TrafficUsage) Total() uint64 {
+	return u.Up + u.Down
+}
+
+// usageMu 同一进程内多个会话共享用量文件
+var usageMu sync.Mutex
+
+func trafficUsagePath() (string, error) {
+	dir, err := checkpointDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, TrafficUsageFileName), nil
+}
+
+// MonthlyUsage 返回本月累计检测流量，无记录时用量为 0
+func MonthlyUsage() TrafficUsage {
+	now := time.Now()
+	path, err := trafficUsagePath()
+	if err != nil {
+		return TrafficUsage{Month: now.Format("2006-01")}
+	}
+	usageMu.Lock()
+	defer usageMu.Unlock()
+	return loadUsage(path, now)
+}
+
+// loadUsage 读取用量文件，文件不存在、损坏或已跨月时从 0 开始
+func loadUsage(path string, now time.Time) TrafficUsage {
+	month := now.Format("2006-01")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return TrafficUsage{Month: month}
+	}
+	var u TrafficUsage
+	if err := json.Unmarshal(data, &u); err != nil || u.Month != month {
+		return TrafficUsage{Month: month}
+	}
+	return u
+}
+
+// addUsage 将新增流量计入 now 所在月份并落盘，返回更新后的用量
+func addUsage(path string, now time.Time, up, down uint64) (TrafficUsage, error) {
+	usageMu.Lock()
+	defer usageMu.Unlock()
+
+	u := loadUsage(path, now)
+	u.Up += up
+	u.Down += down
+	u.UpdatedAt = now
+	data, err := json.Marshal(u)
+	if err != nil {
+		return u, fmt.Errorf("序列化流量用量失败: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return u, fmt.Errorf("创建目录失败: %w", err)
+	}
+	return u, utils.WriteFileAtomic(path, data, 0o644)
+}
+
+// trafficBudget 本轮检测的流量预算和当月用量记录
+type trafficBudget struct {
+	mu   sync.Mutex
+	path string // 用量文件，获取路径失败时为空，不持久化
+
+	runLimit   uint64 // 单轮预算(字节)，0 为不限
+	monthLimit uint64 // 月预算(字节)，0 为不限
+	reserve    uint64 // 剩余预算低于该值时停止测速
+
+	monthUsed   uint64 // 最近一次落盘后的当月用量
+	flushedUp   uint64 // 已计入当月用量的 UP 计数
+	flushedDown uint64 // 已计入当月用量的 DOWN 计数
+}
+
+// newBudget 读取当月用量并计算本轮预算，up/down 为当前累计计数，speedWorkers 为测速最大并发
+func (s *Session) newBudget(up, down uint64, speedWorkers int) *trafficBudget {
+	b := &trafficBudget{
+		runLimit:    uint64(max(s.opts.TrafficBudgetPerRun, 0)) * 1024 * 1024,
+		monthLimit:  uint64(max(s.opts.TrafficBudgetPerMonth, 0)) * 1024 * 1024 * 1024,
+		flushedUp:   up,
+		flushedDown: down,
+	}
+	if path, err := trafficUsagePath(); err != nil {
+		slog.Warn(fmt.Sprintf("获取流量用量文件路径失败: %v", err))
+	} else {
+		b.path = path
+		usageMu.Lock()
+		b.monthUsed = loadUsage(path, time.Now()).Total()
+		usageMu.Unlock()
+	}
+
+	// 预留正在进行的测速可能消耗的流量，单节点数据量不限时按比例预留
+	// 预算较小时最多预留一半，避免检测一开始就停止测速
+	if s.speedON {
+		perNode := uint64(max(s.opts.DownloadMB, 0)) * 1024 * 1024
+		if s.uploadON {
+			perNode += uint64(max(s.opts.UploadMB, 1)) * 1024 * 1024
+		}
+		limit, ok := b.remaining(0, up, down)
+		if ok {
+			b.reserve = max(uint64(float64(limit)*budgetReserveRatio), perNode*uint64(max(speedWorkers, 1)))
+			b.reserve = min(b.reserve, limit/2)
+		}
+	}
+	return b
+}
+
+// remaining 返回剩余预算，未设置预算时 ok 为 false
+//
+// runBytes 为本轮已消耗流量，up/down 为当前累计计数，未落盘部分计入当月用量。
+func (b *trafficBudget) remaining(runBytes, up, down uint64) (left uint64, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	left = ^uint64(0)
+	if b.runLimit > 0 {
+		ok = true
+		left = min(left, b.runLimit-min(runBytes, b.runLimit))
+	}
+	if b.monthLimit > 0 {
+		ok = true
+		used := b.monthUsed + (up - b.flushedUp) + (down - b.flushedDown)
+		left = min(left, b.monthLimit-min(used, b.monthLimit))
+	}
+	if !ok {
+		return 0, false
+	}
+	return left, true
+}
+
+// flush 将上次落盘后的流量计入当月用量
+func (b *trafficBudget) flush(up, down uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	dUp, dDown := up-b.flushedUp, down-b.flushedDown
+	if b.path == "" || dUp+dDown == 0 {
+		return
+	}
+	u, err := addUsage(b.path, time.Now(), dUp, dDown)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("保存流量用量失败: %v", err))
+		return
+	}
+	b.monthUsed = u.Total()
+	b.flushedUp, b.flushedDown = up, down
+}
+
+// watchBudget 监测流量预算：接近预算时停止测速，耗尽时结束检测，并定期保存当月用量
+func (pc *ProxyChecker) watchBudget(ctx context.Context, cancel context.CancelFunc) {
+	st := pc.stats
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	lastFlush := time.Now()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		up, down := st.UP.Load(), st.DOWN.Load()
+		if time.Since(lastFlush) >= usageFlushInterval {
+			pc.budget.flush(up, down)
+			lastFlush = time.Now()
+		}
+
+		left, ok := pc.budget.remaining(st.TotalBytes.Load(), up, down)
+		if !ok {
+			continue
+		}
+		if left == 0 {
+			slog.Warn("流量预算已耗尽，结束检测并收集已有结果")
+			pc.spent.Store(true)
+			cancel()
+			return
+		}
+		if pc.speedON && left <= pc.budget.reserve && pc.speedCut.CompareAndSwap(false, true) {
+			slog.Warn(fmt.Sprintf("流量预算剩余 %s，停止测速，后续节点仅测活", utils.FormatTraffic(left)))
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package check
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sinspired/subs-check-pro/config"
+)
+
+func TestMonthlyUsage(t *testing.T) {
+	orig := config.GlobalConfig.OutputDir
+	config.GlobalConfig.OutputDir = t.TempDir()
+	t.Cleanup(func() { config.GlobalConfig.OutputDir = orig })
+
+	path, err := trafficUsagePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	oct := time.Date(2026, 10, 18, 12, 0, 0, 0, time.Local)
+	if _, err := addUsage(path, oct, 100, 900); err != nil {
+		t.Fatal(err)
+	}
+	u, err := addUsage(path, oct.Add(time.Hour), 50, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Month != "2026-10" || u.Up != 150 || u.Down != 950 {
+		t.Errorf("同月累计错误: %+v", u)
+	}
+
+	// 跨月后从 0 开始
+	nov := time.Date(2026, 11, 1, 0, 0, 1, 0, time.Local)
+	if got := loadUsage(path, nov); got.Month != "2026-11" || got.Total() != 0 {
+		t.Errorf("跨月读取错误: %+v", got)
+	}
+	if u, _ := addUsage(path, nov, 1, 2); u.Total() != 3 {
+		t.Errorf("跨月累计错误: %+v", u)
+	}
+}
+
+func TestTrafficBudget(t *testing.T) {
+	orig := config.GlobalConfig.OutputDir
+	config.GlobalConfig.OutputDir = t.TempDir()
+	t.Cleanup(func() { config.GlobalConfig.OutputDir = orig })
+
+	const mb = 1024 * 1024
+
+	s := NewSession(Options{})
+	if _, ok := s.newBudget(0, 0, 1).remaining(1<<40, 1<<40, 0); ok {
+		t.Error("未设置预算时不应限制")
+	}
+
+	s = NewSession(Options{
+		SpeedTestURL:        "https://speed.example/100mb",
+		DownloadMB:          20,
+		TrafficBudgetPerRun: 1000,
+	})
+	b := s.newBudget(0, 0, 4)
+	if b.reserve != 100*mb {
+		t.Errorf("reserve = %d, want 10%% of budget", b.reserve)
+	}
+	if left, _ := b.remaining(600*mb, 0, 0); left != 400*mb {
+		t.Errorf("remaining = %d, want 400MB", left)
+	}
+	if left, _ := b.remaining(2000*mb, 0, 0); left != 0 {
+		t.Errorf("超出预算时 remaining = %d, want 0", left)
+	}
+
+	// 预算小于并发测速的数据量时最多预留一半
+	s = NewSession(Options{
+		SpeedTestURL:        "https://speed.example/100mb",
+		DownloadMB:          50,
+		TrafficBudgetPerRun: 100,
+	})
+	if b := s.newBudget(0, 0, 8); b.reserve != 50*mb {
+		t.Errorf("reserve = %d, want half of budget", b.reserve)
+	}
+
+	// 月预算：已落盘用量 + 未落盘增量
+	s = NewSession(Options{TrafficBudgetPerMonth: 1})
+	b = s.newBudget(10, 20, 1)
+	b.flush(10+300*mb, 20+200*mb)
+	if u := MonthlyUsage(); u.Up != 300*mb || u.Down != 200*mb {
+		t.Errorf("落盘用量错误: %+v", u)
+	}
+	if left, _ := b.remaining(0, 10+300*mb, 20+300*mb); left != 1024*mb-600*mb {
+		t.Errorf("remaining = %d, want %d", left, 1024*mb-600*mb)
+	}
+
+	// 重启后读取已有用量
+	b = s.newBudget(0, 0, 1)
+	if left, _ := b.remaining(0, 0, 0); left != 1024*mb-500*mb {
+		t.Errorf("重启后 remaining = %d, want %d", left, 1024*mb-500*mb)
+	}
+}
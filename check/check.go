@@ -3,13 +3,14 @@ package check
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
-	"math"
 	"net"
 	"net/http"
 	"regexp"
 	"runtime/debug"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -22,61 +23,73 @@ import (
 	"github.com/metacubex/mihomo/constant"
 	"github.com/oschwald/maxminddb-golang/v2"
 	"github.com/sinspired/subs-check-pro/assets"
+	"github.com/sinspired/subs-check-pro/check/exitcache"
+	"github.com/sinspired/subs-check-pro/check/health"
 	"github.com/sinspired/subs-check-pro/check/platform"
 	"github.com/sinspired/subs-check-pro/config"
 	proxyutils "github.com/sinspired/subs-check-pro/proxy"
 	"github.com/sinspired/subs-check-pro/utils"
 )
 
-// 对外暴露变量，兼容GUI调用
-var (
-	Progress   atomic.Uint32 // 已检测数量（语义见算法）
-	Available  atomic.Uint32 // 已可用数量（测速阶段完成,可用即+1）
-	ProxyCount atomic.Uint32 // 总数（动态=总节点；分阶段=当前阶段规模）
+// defaultStats Check() 使用的统计，通过下方变量对外暴露
+var defaultStats Stats
 
-	TotalBytes     atomic.Uint64
-	UP             atomic.Uint64
-	DOWN           atomic.Uint64
-	ForceClose     atomic.Bool
-	Successlimited atomic.Bool
-	ProcessResults atomic.Bool
+// 对外暴露变量，兼容GUI调用，指向 Check() 会话的统计
+var (
+	Progress   = &defaultStats.Progress   // 已检测数量（语义见算法）
+	Available  = &defaultStats.Available  // 已可用数量（测速阶段完成,可用即+1）
+	ProxyCount = &defaultStats.ProxyCount // 总数（动态=总节点；分阶段=当前阶段规模）
+	ETASeconds = &defaultStats.ETASeconds // -1=计算中, 0=空闲/完成, >0=剩余秒数
+
+	TotalBytes     = &defaultStats.TotalBytes
+	UP             = &defaultStats.UP
+	DOWN           = &defaultStats.DOWN
+	ForceClose     = &defaultStats.ForceClose
+	Successlimited = &defaultStats.SuccessLimited
+	ProcessResults = &defaultStats.ProcessResults
 
 	Bucket *ratelimit.Bucket
 
+	// 检测结束后更新
 	CheckStartTime time.Time
 	CheckEndTime   time.Time
 	CheckDuration  time.Duration
 	CheckTraffic   string
 )
 
-// 存储测速和流媒体检测开关状态
-var (
-	speedON        bool
-	mediaON        bool
-	progressWeight ProgressWeight
-)
+// CurrentConcurrency 返回 Check() 各阶段当前并发数
+func CurrentConcurrency() ConcurrencyInfo {
+	return defaultStats.Concurrency()
+}
 
 // Result 存储节点检测结果
 type Result struct {
 	Proxy          map[string]any
-	Openai         bool
-	OpenaiWeb      bool
-	X              bool
-	Youtube        string
-	Netflix        bool
-	Google         bool
-	Cloudflare     bool
-	Disney         bool
-	Gemini         bool
-	TikTok         string
+	Latency        int                                // 延迟中位数(ms)
+	LatencyMin     int                                // 最小延迟(ms)
+	Jitter         int                                // 抖动(ms)
+	Handshake      int                                // 建连耗时(ms)
+	AliveTargets   []string                           // 通过的测活目标
+	Integrity      string                             // 流量劫持类型，为空表示未发现
+	UDP            bool                               // 支持 UDP 转发
+	Speed          int                                // 下载速度(KB/s)
+	UploadSpeed    int                                // 上传速度(KB/s)
+	PeakSpeed      int                                // 峰值下载速度(KB/s)
+	TTFB           int                                // 测速首字节时间(ms)
+	NATType        string                             // NAT 类型，为空表示未知
+	Platforms      map[string]platform.PlatformResult // 平台解锁结果，键为平台名称
 	IP             string
 	IPRisk         string
 	Country        string
 	CountryCodeTag string
+	ASN            uint   // 出口 IP 所属 AS 号，未查询时为 0
+	ISP            string // 运营商标签，如 [原生|住宅]
 }
 
 // ProxyChecker 处理代理检测的主要结构体
 type ProxyChecker struct {
+	*Session
+
 	results     []Result
 	resultChan  chan Result
 	proxyCount  int
@@ -87,20 +100,48 @@ type ProxyChecker struct {
 	speedConcurrent int
 	mediaConcurrent int
 
+	// 各阶段并发控制，未启用动态并发时容量固定
+	aliveCtl *stageCtl
+	speedCtl *stageCtl
+	mediaCtl *stageCtl
+	stages   []*stageCtl
+	adapt    *adaptWindow // 测活超时统计，未启用动态并发时为 nil
+
+	budget   *trafficBudget    // 流量预算和当月用量
+	speedCut atomic.Bool       // 流量预算不足，后续节点跳过测速
+	spent    atomic.Bool       // 流量预算已耗尽，检测提前结束
+	exits    *exitStats        // 出口分组统计，去重后写入
+	asnDB    *maxminddb.Reader // ASN 数据库，isp 检测和按 ASN 去重使用，未启用时为 nil
+
+	subAnalysis map[string]*AnalysisStats // 各订阅的节点分布，出口去重前统计
+
 	aliveChan chan *ProxyJob
 	speedChan chan *ProxyJob
 	mediaChan chan *ProxyJob
 
 	pt *ProgressTracker
+
+	healthDB  *health.Store    // 节点健康数据库，未启用时为 nil
+	exitCache *exitcache.Store // 出口检测结果缓存，未启用时为 nil
+	tracer    *traceWriter     // 检测轨迹，未启用时为 nil
+	failures  *FailureStats    // 失败原因统计
+	ckpt      *checkpoint      // 检测断点，未启用时为 nil
+
+	traceAppend bool         // 检测轨迹追加写入，worker 同一轮次的后续分片使用
+	onResult    func(Result) // 收到可用结果时回调
 }
 
 // ProxyJob 在测活-测速-流媒体检测任务间传输信息
 type ProxyJob struct {
 	Client *ProxyClient
 	Result Result
+	Key    string      // 节点健康数据库键
+	Index  int         // 节点在本轮检测列表中的位置
+	Trail  []TraceStep // 各阶段检测结果
 
-	CfLoc string
-	CfIP  string
+	CfLoc  string
+	CfIP   string
+	traced bool // 未检测 CF 时已单独请求过 CF trace
 
 	doneOnce sync.Once
 
@@ -128,29 +169,29 @@ func (j *ProxyJob) Close() {
 }
 
 // calcSpeedConcurrency 根据总速度限制计算速度测试的最佳并发数。
-func calcSpeedConcurrency(proxyCount int) int {
-	if config.GlobalConfig.TotalSpeedLimit <= 0 {
-		threadCount := min(proxyCount, config.GlobalConfig.Concurrent)
+func (s *Session) calcSpeedConcurrency(proxyCount int) int {
+	if s.opts.TotalSpeedLimit <= 0 {
+		threadCount := min(proxyCount, s.opts.Concurrent)
 		fnSpeed := NewPowerDecay(32, 1.1, 32, 1)
-		return min(config.GlobalConfig.Concurrent, RoundInt(fnSpeed(float64(threadCount))))
+		return min(s.opts.Concurrent, RoundInt(fnSpeed(float64(threadCount))))
 	}
-	L := float64(config.GlobalConfig.TotalSpeedLimit) // 单位: MB/s
-	r := float64(config.GlobalConfig.MinSpeed) / 1024 // 目标每线程吞吐: MB/s
+	L := float64(s.opts.TotalSpeedLimit) // 单位: MB/s
+	r := float64(s.opts.MinSpeed) / 1024 // 目标每线程吞吐: MB/s
 	c := max(int(L/r), 1)
-	c = min(c, config.GlobalConfig.Concurrent)
+	c = min(c, s.opts.Concurrent)
 	return c
 }
 
-// NewProxyChecker 创建新的检测器实例
-func NewProxyChecker(proxyCount int) *ProxyChecker {
-	threadCount := config.GlobalConfig.Concurrent
+// newChecker 创建新的检测器实例
+func (s *Session) newChecker(proxyCount int) *ProxyChecker {
+	threadCount := s.opts.Concurrent
 	if proxyCount < threadCount {
 		threadCount = proxyCount
 	}
 
-	cAlive := config.GlobalConfig.AliveConcurrent
-	cSpeed := config.GlobalConfig.SpeedConcurrent
-	cMedia := config.GlobalConfig.MediaConcurrent
+	cAlive := s.opts.AliveConcurrent
+	cSpeed := s.opts.SpeedConcurrent
+	cMedia := s.opts.MediaConcurrent
 
 	// 分别设置测活\测速\媒体检测阶段并发数
 	// 使用衰减算法,简单防呆设计
@@ -168,12 +209,12 @@ func NewProxyChecker(proxyCount int) *ProxyChecker {
 		// 使用相对平滑的衰减方案
 		fnAlive := NewLogDecay(400, 0.005, 400)
 		fnMedia := NewExpDecay(400, 0.001, 100)
-		if !speedON {
+		if !s.speedON {
 			fnMedia = NewExpDecay(400, 0.001, 150)
 		}
 
 		aliveConc = min(proxyCount, RoundInt(fnAlive(float64(threadCount))))
-		speedConc = min(calcSpeedConcurrency(proxyCount), proxyCount)
+		speedConc = min(s.calcSpeedConcurrency(proxyCount), proxyCount)
 		mediaConc = min(proxyCount, RoundInt(fnMedia(float64(threadCount))))
 
 		// 超大线程数
@@ -187,11 +228,13 @@ func NewProxyChecker(proxyCount int) *ProxyChecker {
 	// 测速阶段的缓冲通道不用太大,以形成阻塞,避免测活浪费资源
 	fnScLength := NewTanhDecay(100, 0.0004, float64(aliveConc))
 	speedChanLength = RoundInt(fnScLength(float64(speedConc)))
-	if !speedON {
+	if !s.speedON {
 		speedChanLength = 1 // 不启用测速时，设置为最小缓冲
 	}
 
-	return &ProxyChecker{
+	pc := &ProxyChecker{
+		Session: s,
+
 		proxyCount:  proxyCount,
 		threadCount: threadCount,
 
@@ -206,34 +249,101 @@ func NewProxyChecker(proxyCount int) *ProxyChecker {
 		mediaChan: make(chan *ProxyJob, mediaConc*2),
 
 		// 设置进度跟踪
-		pt: NewProgressTracker(proxyCount),
+		pt: s.newProgressTracker(proxyCount),
+	}
+
+	pc.aliveCtl = s.newStageCtl(StageAlive, aliveConc, &s.stats.AliveConcurrent,
+		func() int { return len(pc.aliveChan) })
+	pc.speedCtl = s.newStageCtl(StageSpeed, speedConc, &s.stats.SpeedConcurrent,
+		func() int { return len(pc.speedChan) })
+	pc.mediaCtl = s.newStageCtl(StageMedia, mediaConc, &s.stats.MediaConcurrent,
+		func() int { return len(pc.mediaChan) })
+	pc.stages = []*stageCtl{pc.aliveCtl}
+	if s.speedON {
+		pc.stages = append(pc.stages, pc.speedCtl)
+	} else {
+		s.stats.SpeedConcurrent.Store(0)
 	}
+	pc.stages = append(pc.stages, pc.mediaCtl)
+	s.stats.Adaptive.Store(s.opts.AdaptiveConcurrency)
+	if s.opts.AdaptiveConcurrency {
+		pc.adapt = &adaptWindow{}
+	}
+	return pc
 }
 
-// Check 执行代理检测的主函数
+// Check 执行代理检测的主函数，进度和结果通过包级变量对外暴露
 func Check() ([]Result, error) {
 	proxyutils.ResetRenameCounter()
-	ForceClose.Store(false)
-	Successlimited.Store(false)
-	ProcessResults.Store(false)
+	sess := newSession(OptionsFromConfig(config.GlobalConfig), &defaultStats)
+	Bucket = sess.bucket
+	defer func() {
+		CheckStartTime = defaultStats.StartTime
+		CheckEndTime = defaultStats.EndTime
+		CheckDuration = defaultStats.Duration
+		CheckTraffic = defaultStats.Traffic
+	}()
 
-	ProxyCount.Store(0)
-	Available.Store(0)
-	Progress.Store(0)
+	// 从断点恢复，失败时重新获取节点
+	var ckpt *checkpoint
+	var proxies []map[string]any
+	if resumeRequested.Swap(false) {
+		var err error
+		if ckpt, proxies, err = loadCheckpoint(); err != nil {
+			slog.Warn(fmt.Sprintf("无法从断点恢复检测，将重新开始: %v", err))
+			ckpt, proxies = nil, nil
+		}
+	}
 
-	TotalBytes.Store(0)
+	headSize := 0
+	if ckpt == nil {
+		// 重新开始检测，旧断点不再有效
+		discardCheckpoint()
 
-	// 重置预计剩余时间计算
-	ResetETA()
+		var err error
+		if proxies, headSize, err = fetchProxies(); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(proxies) == 0 {
+		slog.Info("没有需要检测的节点")
+		return nil, nil
+	}
+
+	checker := sess.newChecker(len(proxies))
+
+	// 加载节点健康数据库
+	checker.openHealthDB()
+
+	if ckpt != nil {
+		checker.ckpt = ckpt
+	} else {
+		// 根据健康数据库确定检测顺序
+		checker.orderProxies(proxies, headSize, checker.healthDB)
+
+		// 分布式检测：分片交给 worker
+		if checker.opts.Distributed.Mode == ModeCoordinator {
+			return checker.runCoordinator(proxies)
+		}
+
+		// 保存节点列表，用于中断后恢复
+		if checker.checkpointInterval() > 0 {
+			var err error
+			if checker.ckpt, err = newCheckpoint(proxies); err != nil {
+				slog.Warn(fmt.Sprintf("创建检测断点失败: %v", err))
+			}
+		}
+	}
 
-	// 初始化测速和流媒体检测开关
-	speedON = config.GlobalConfig.SpeedTestURL != ""
-	mediaON = config.GlobalConfig.MediaCheck
+	return checker.run(context.Background(), proxies)
+}
 
-	// 获取订阅节点和之前成功的节点数量(已前置)
+// fetchProxies 获取订阅节点，返回节点列表和之前成功的节点数量(已前置)
+func fetchProxies() ([]map[string]any, int, error) {
 	proxies, rawCount, subWasSuccedLength, historyLength, err := proxyutils.GetProxies()
 	if err != nil {
-		return nil, fmt.Errorf("获取节点失败: %w", err)
+		return nil, 0, fmt.Errorf("获取节点失败: %w", err)
 	}
 	slog.Info(fmt.Sprintf("已获取节点数量: %d", rawCount))
 	slog.Info(fmt.Sprintf("去重后节点数量: %d", len(proxies)))
@@ -246,53 +356,23 @@ func Check() ([]Result, error) {
 		slog.Info(fmt.Sprintf("已加载历次检测可用节点，数量: %d", historyLength))
 	}
 
-	// 设置之前成功的节点顺序在前
-	headSize := subWasSuccedLength + historyLength
-	if len(proxies) > headSize {
-		// 假设有 15 个相似的ip
-		calcMinSpacing := max(config.GlobalConfig.Concurrent*5, len(proxies)/15)
-
-		// 随机乱序并根据 server 字段打乱节点顺序, 减少测速直接测死的概率
-		cfg := proxyutils.ShuffleConfig{
-			Threshold:  float64(config.GlobalConfig.Threshold), // CIDR/24 相同, 避免在一组(0.5: CIDR/16)
-			Passes:     3,                                      // 改善轮数（1~3）
-			MinSpacing: calcMinSpacing,                         // CIDR/24 相同, 设置最小间隔
-			ScanLimit:  config.GlobalConfig.Concurrent * 2,     // 冲突向前扫描的最大距离
-		}
-
-		tail := proxies[headSize:]
-		proxyutils.SmartShuffleByServer(tail, cfg)
-
-		cidr := proxyutils.ThresholdToCIDR(cfg.Threshold)
-		slog.Info(fmt.Sprintf("节点乱序, 相同 CIDR%s 最小间距: %d", cidr, cfg.MinSpacing))
-	}
-
-	if len(proxies) == 0 {
-		slog.Info("没有需要检测的节点")
-		return nil, nil
-	}
-
-	checker := NewProxyChecker(len(proxies))
-	return checker.run(proxies)
+	return proxies, subWasSuccedLength + historyLength, nil
 }
 
 // Run 运行检测流程
-func (pc *ProxyChecker) run(proxies []map[string]any) ([]Result, error) {
-	// 限速设置
-	if limit := config.GlobalConfig.TotalSpeedLimit; limit > 0 {
-		rate := float64(limit * 1024 * 1024)
-		capacity := int64(rate / 10)
-		Bucket = ratelimit.NewBucketWithRate(rate, capacity)
-	} else {
-		Bucket = ratelimit.NewBucketWithRate(float64(math.MaxInt64), int64(math.MaxInt64))
-	}
-
-	// // 初始化全局上下文
-	ctx, cancel := context.WithCancel(context.Background())
+func (pc *ProxyChecker) run(parent context.Context, proxies []map[string]any) ([]Result, error) {
+	// 初始化检测上下文
+	ctx, cancel := context.WithCancel(parent)
 	defer cancel()
 
+	// 读取当月流量用量，月预算已用完时不再检测
+	pc.budget = pc.newBudget(pc.stats.UP.Load(), pc.stats.DOWN.Load(), pc.speedCtl.gate.max)
+	if left, ok := pc.budget.remaining(0, pc.stats.UP.Load(), pc.stats.DOWN.Load()); ok && left == 0 {
+		return nil, ErrTrafficBudgetExhausted
+	}
+
 	// 如果 MaxMindDBPath 为空会自动使用 subs-check-pro 内置数据库
-	geoDB, err := assets.OpenMaxMindDB(config.GlobalConfig.MaxMindDBPath)
+	geoDB, err := assets.OpenMaxMindDB(pc.opts.MaxMindDBPath)
 	if err != nil {
 		slog.Debug(fmt.Sprintf("打开 MaxMind 数据库失败: %v", err))
 		geoDB = nil
@@ -307,70 +387,111 @@ func (pc *ProxyChecker) run(proxies []map[string]any) ([]Result, error) {
 		}()
 	}
 
+	// 打开 ASN 数据库，加载离线 IP 风险数据集
+	pc.openASNDB()
+	defer pc.closeASNDB()
+	pc.loadIPRisk()
+
+	// 创建检测轨迹
+	pc.openTrace()
+
+	// 加载出口检测结果缓存
+	pc.openExitCache()
+
+	// 恢复断点中的进度和结果
+	if pc.ckpt != nil && pc.ckpt.restored != nil {
+		pc.restore(pc.ckpt)
+	}
+
 	slog.Info("开始检测节点")
 
 	// 记录开始检测时间
-	CheckStartTime = time.Now()
+	st := pc.stats
+	st.StartTime = time.Now()
 
 	// 组装参数
+	opts := pc.opts
 	args := []any{
-		"enable-speedtest", speedON,
-		"media-check", mediaON,
-		"drop-bad-cf-nodes", config.GlobalConfig.DropBadCfNodes,
+		"enable-speedtest", pc.speedON,
+		"media-check", pc.mediaON,
+		"drop-bad-cf-nodes", opts.DropBadCfNodes,
 	}
 
 	// 流水线并发参数
-	if config.GlobalConfig.AliveConcurrent <= 0 || config.GlobalConfig.SpeedConcurrent <= 0 || config.GlobalConfig.MediaConcurrent <= 0 {
+	if opts.AliveConcurrent <= 0 || opts.SpeedConcurrent <= 0 || opts.MediaConcurrent <= 0 {
 		args = append(args,
-			"auto-concurrent", true, "concurrent", config.GlobalConfig.Concurrent,
+			"auto-concurrent", true, "concurrent", opts.Concurrent,
 			":alive", pc.aliveConcurrent,
 		)
-		if speedON {
+		if pc.speedON {
 			args = append(args, ":speed", pc.speedConcurrent)
 		}
-		if mediaON {
+		if pc.mediaON {
 			args = append(args, ":media", pc.mediaConcurrent)
 		}
 	} else {
 		args = append(args,
-			"concurrent", config.GlobalConfig.Concurrent,
+			"concurrent", opts.Concurrent,
 			":alive", pc.aliveConcurrent)
-		if speedON {
+		if pc.speedON {
 			args = append(args, ":speed", pc.speedConcurrent)
 		}
-		if mediaON {
+		if pc.mediaON {
 			args = append(args, ":media", pc.mediaConcurrent)
 		}
 	}
 	// 只有在 >0 时才输出
-	if config.GlobalConfig.SuccessLimit > 0 {
-		args = append(args, "success-limit", config.GlobalConfig.SuccessLimit)
+	if opts.AdaptiveConcurrency {
+		args = append(args, "adaptive-concurrency", true)
+	}
+	if opts.SuccessLimit > 0 {
+		args = append(args, "success-limit", opts.SuccessLimit)
 	}
-	if config.GlobalConfig.TotalSpeedLimit > 0 && speedON {
-		args = append(args, "total-speed-limit", config.GlobalConfig.TotalSpeedLimit)
+	if opts.TotalSpeedLimit > 0 && pc.speedON {
+		args = append(args, "total-speed-limit", opts.TotalSpeedLimit)
+	}
+	if opts.TrafficBudgetPerRun > 0 {
+		args = append(args, "traffic-budget-per-run", fmt.Sprintf("%dMB", opts.TrafficBudgetPerRun))
+	}
+	if opts.TrafficBudgetPerMonth > 0 {
+		args = append(args, "traffic-budget-per-month", fmt.Sprintf("%dGB", opts.TrafficBudgetPerMonth),
+			"month-used", utils.FormatTraffic(pc.budget.monthUsed))
 	}
 
 	// 再追加剩余参数
 	args = append(args,
-		"timeout", config.GlobalConfig.Timeout,
+		"timeout", opts.Timeout,
 	)
 
-	if speedON {
+	if pc.latencyON {
+		args = append(args, "latency-samples", opts.LatencySamples)
+		if opts.MaxLatency > 0 {
+			args = append(args, "max-latency", opts.MaxLatency)
+		}
+	}
+
+	if pc.speedON {
 		args = append(args,
-			"min-speed", config.GlobalConfig.MinSpeed,
-			"download-timeout", config.GlobalConfig.DownloadTimeout,
-			"download-mb", config.GlobalConfig.DownloadMB,
+			"min-speed", opts.MinSpeed,
+			"download-timeout", opts.DownloadTimeout,
+			"download-mb", opts.DownloadMB,
 		)
+		if opts.SpeedStreams > 1 || opts.SpeedSamples > 1 {
+			args = append(args, "speed-streams", opts.SpeedStreams, "speed-samples", opts.SpeedSamples)
+		}
+		if pc.uploadON {
+			args = append(args, "upload-mb", opts.UploadMB, "min-upload-speed", opts.MinUploadSpeed)
+		}
 	}
 
-	if config.GlobalConfig.KeepSuccessProxies {
-		args = append(args, "keep-success-proxies", config.GlobalConfig.KeepSuccessProxies)
+	if opts.KeepSuccessProxies {
+		args = append(args, "keep-success-proxies", opts.KeepSuccessProxies)
 	}
 
 	args = append(args, "analysis", "true")
 
-	if config.GlobalConfig.SuccessRate > 0 {
-		r := fmt.Sprintf("%.1f%%", config.GlobalConfig.SuccessRate*100)
+	if opts.SuccessRate > 0 {
+		r := fmt.Sprintf("%.1f%%", opts.SuccessRate*100)
 		args = append(args, "success-rate", r)
 	}
 
@@ -386,7 +507,7 @@ func (pc *ProxyChecker) run(proxies []map[string]any) ([]Result, error) {
 			case <-ctx.Done():
 				return
 			case <-ticker.C:
-				if ForceClose.Load() {
+				if st.ForceClose.Load() {
 					slog.Warn("用户手动结束检测,等待收集结果")
 					cancel()
 					return
@@ -399,7 +520,7 @@ func (pc *ProxyChecker) run(proxies []map[string]any) ([]Result, error) {
 	doneCh := make(chan struct{})
 	finishedCh := make(chan struct{})
 
-	if config.GlobalConfig.PrintProgress {
+	if opts.PrintProgress {
 		go func() {
 			pc.showProgress(doneCh)
 			close(finishedCh)
@@ -413,15 +534,44 @@ func (pc *ProxyChecker) run(proxies []map[string]any) ([]Result, error) {
 		for {
 			select {
 			case <-ctx.Done():
-				UpdateETA()
+				pc.updateETA()
 				return
 			case <-ticker.C:
-				SnapshotRate()
-				UpdateETA()
+				pc.snapshotRate()
+				pc.updateETA()
 			}
 		}
 	}()
 
+	// 定期保存断点
+	if pc.ckpt != nil {
+		go func() {
+			ticker := time.NewTicker(pc.checkpointInterval())
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					pc.saveCheckpoint()
+				}
+			}
+		}()
+	}
+
+	// 定期保存节点健康数据库，避免中断后丢失本轮记录
+	if pc.healthDB != nil {
+		go pc.flushHealthDB(ctx)
+	}
+
+	// 流量预算和当月用量
+	go pc.watchBudget(ctx, cancel)
+
+	// 动态调整各阶段并发
+	if pc.adapt != nil {
+		go pc.adjustConcurrency(ctx)
+	}
+
 	// 启动流水线阶段
 	go pc.distributeJobs(proxies, ctx)
 	go pc.runAliveStage(ctx)
@@ -429,7 +579,7 @@ func (pc *ProxyChecker) run(proxies []map[string]any) ([]Result, error) {
 	pc.runMediaStageAndCollect(geoDB, ctx, cancel)
 
 	// 确保进度显示到 100% 再打印收尾日志
-	if config.GlobalConfig.PrintProgress {
+	if opts.PrintProgress {
 		// 收集工作已全部完成，调用 Finalize 强制将进度设置为 100%
 		pc.pt.Finalize()
 
@@ -438,24 +588,53 @@ func (pc *ProxyChecker) run(proxies []map[string]any) ([]Result, error) {
 		<-finishedCh
 	}
 
-	if config.GlobalConfig.SuccessLimit > 0 && pc.available.Load() >= config.GlobalConfig.SuccessLimit {
-		slog.Info(fmt.Sprintf("达到成功节点数量限制 %d, 收集结果完成。", config.GlobalConfig.SuccessLimit))
+	if opts.SuccessLimit > 0 && pc.available.Load() >= opts.SuccessLimit {
+		slog.Info(fmt.Sprintf("达到成功节点数量限制 %d, 收集结果完成。", opts.SuccessLimit))
 	}
 
 	// 标记检测完成，开始处理结果，保存，上传等
-	ProcessResults.Store(true)
+	st.ProcessResults.Store(true)
 
 	// 重置预计剩余时间计算
-	ETASeconds.Store(0)
+	st.ETASeconds.Store(0)
 
 	slog.Info(fmt.Sprintf("可用节点数量: %d", len(pc.results)))
-	CheckTraffic = utils.FormatTraffic(uint64(TotalBytes.Load()))
-	slog.Info(fmt.Sprintf("检测消耗流量: %s", CheckTraffic))
-	slog.Debug("流量", "UP", UP.Load(), "DOWN", DOWN.Load())
+	if dead := pc.speedHealth.Dead(); len(dead) > 0 {
+		slog.Info(fmt.Sprintf("本轮失效的测速地址: %d", len(dead)), "urls", dead)
+	}
+	st.Traffic = utils.FormatTraffic(st.TotalBytes.Load())
+	slog.Info(fmt.Sprintf("检测消耗流量: %s", st.Traffic))
+	slog.Debug("流量", "UP", st.UP.Load(), "DOWN", st.DOWN.Load())
+	pc.budget.flush(st.UP.Load(), st.DOWN.Load())
+	if opts.TrafficBudgetPerMonth > 0 {
+		slog.Info(fmt.Sprintf("本月检测流量: %s / %dGB", utils.FormatTraffic(pc.budget.monthUsed), opts.TrafficBudgetPerMonth))
+	}
 
 	// 计算检测用时
-	CheckEndTime = time.Now()
-	CheckDuration = time.Since(CheckStartTime)
+	st.EndTime = time.Now()
+	st.Duration = st.EndTime.Sub(st.StartTime)
+
+	// 更新节点健康数据库（分析报告依赖最新记录）
+	pc.saveHealthDB()
+	pc.saveExitCache()
+	pc.closeTrace()
+
+	// 检测已完成（包括手动结束），删除断点
+	if pc.ckpt != nil {
+		pc.ckpt.remove()
+	}
+
+	// worker 只负责检测，分析和清理由协调节点完成
+	if !opts.Report {
+		return pc.results, nil
+	}
+
+	// 统计订阅可用节点数，记录订阅健康状况并按策略隔离失效订阅
+	pc.collectSubStats()
+	proxyutils.UpdateSubHealth(proxyutils.SubStats)
+
+	// 按出口去重，分组统计写入分析报告
+	pc.dedupExits()
 
 	// 1. 深度分析 (利用上一步的成功率进行排序，生成 analysis yaml)
 	pc.GenerateAnalysisReport()
@@ -498,10 +677,16 @@ func (pc *ProxyChecker) distributeJobs(proxies []map[string]any, ctx context.Con
 					return // 所有代理都已处理完毕
 				}
 
-				if checkCtxDone(ctx) {
+				if pc.done(ctx) {
 					return
 				}
 
+				// 断点恢复时跳过已完成的节点
+				if pc.ckpt != nil && pc.ckpt.isDone(int(index)) {
+					proxies[index] = nil
+					continue
+				}
+
 				mapping := proxies[index]
 
 				// 任务取出后，立即断开源切片的引用
@@ -519,25 +704,34 @@ func (pc *ProxyChecker) distributeJobs(proxies []map[string]any, ctx context.Con
 					}(index)
 				}
 
-				cli := CreateClient(mapping)
-				if cli == nil {
-					// 创建失败：视为 alive 完成（失败），不进入 speed/media
-					pc.pt.CountAlive(false)
-					continue
+				var key string
+				if pc.healthDB != nil {
+					key = health.Key(mapping)
 				}
 
 				job := &ProxyJob{
-					Client: cli,
 					Result: Result{Proxy: mapping},
+					Key:    key,
+					Index:  int(index),
+				}
+
+				start := time.Now()
+				cli, err := pc.newClient(mapping)
+				if err != nil {
+					// 创建失败：视为 alive 完成（失败），不进入 speed/media
+					pc.pt.CountAlive(false)
+					pc.fail(job, StageParse, start, ReasonParse, err)
+					continue
 				}
-				job.NeedCF = config.GlobalConfig.DropBadCfNodes ||
-					(config.GlobalConfig.MediaCheck && needsCF(config.GlobalConfig.Platforms))
+				job.Client = cli
+				job.NeedCF = pc.opts.DropBadCfNodes ||
+					(pc.opts.MediaCheck && pc.needsCF())
 
 				// 当 aliveChan 满时会阻塞
 				select {
 				case pc.aliveChan <- job:
 				case <-ctx.Done():
-					job.Close()
+					pc.abandon(job, StageAlive)
 					return
 				}
 			}
@@ -553,7 +747,7 @@ func (pc *ProxyChecker) distributeJobs(proxies []map[string]any, ctx context.Con
 // 测活
 func (pc *ProxyChecker) runAliveStage(ctx context.Context) {
 	// 根据是否启用测速，延迟关闭下一个阶段的通道。
-	if speedON {
+	if pc.speedON {
 		defer close(pc.speedChan)
 	} else {
 		close(pc.speedChan)
@@ -561,32 +755,81 @@ func (pc *ProxyChecker) runAliveStage(ctx context.Context) {
 	}
 
 	var wg sync.WaitGroup
-	concurrency := pc.aliveConcurrent
 	pc.pt.currentStage.Store(0)
 
-	for range concurrency {
+	for range pc.aliveCtl.gate.max {
 		wg.Go(func() {
-			for job := range pc.aliveChan {
-				if checkCtxDone(ctx) {
-					job.Close()
+			for job := range pc.aliveCtl.gate.each(pc.aliveChan) {
+				if pc.done(ctx) {
+					pc.abandon(job, StageAlive)
 					continue
 				}
 				// 节点测活
-				isAlive := checkAlive(job)
-
-				if !isAlive {
+				start := time.Now()
+				if err := pc.checkAlive(ctx, job); err != nil {
 					// 记录非存活
 					if job.aliveMarked.CompareAndSwap(false, true) {
 						pc.pt.CountAlive(false)
 					}
+					reason := classifyError(err)
+					pc.observeAlive(reason)
+					pc.fail(job, StageAlive, start, reason, err)
 					job.Close()
 					continue // 不进入 speed/media
 				}
+				pc.observeAlive("")
+				job.trace(StageAlive, start, "", nil)
+
+				// 延迟测试
+				if pc.latencyON {
+					start = time.Now()
+					if reason, err := pc.checkLatency(job); reason != "" {
+						if job.aliveMarked.CompareAndSwap(false, true) {
+							pc.pt.CountAlive(false)
+						}
+						pc.fail(job, StageLatency, start, reason, err)
+						job.Close()
+						continue
+					}
+					job.trace(StageLatency, start, "", nil)
+				}
+
+				// 流量劫持检测
+				if pc.opts.IntegrityCheck {
+					start = time.Now()
+					verdict, err := pc.checkIntegrity(job)
+					if verdict != "" && pc.opts.DropHijackedNodes {
+						if job.aliveMarked.CompareAndSwap(false, true) {
+							pc.pt.CountAlive(false)
+						}
+						pc.fail(job, StageIntegrity, start, verdict, err)
+						job.Close()
+						continue
+					}
+					job.trace(StageIntegrity, start, "", err)
+				}
+
+				// UDP 检测
+				if pc.udpON {
+					start = time.Now()
+					err := pc.checkUDP(ctx, job)
+					if !job.Result.UDP && pc.opts.RequireUDP {
+						if job.aliveMarked.CompareAndSwap(false, true) {
+							pc.pt.CountAlive(false)
+						}
+						pc.fail(job, StageUDP, start, ReasonNoUDP, err)
+						job.Close()
+						continue
+					}
+					job.trace(StageUDP, start, "", err)
+				}
 
 				// CF 过滤
 				if job.NeedCF {
+					start = time.Now()
 					job.IsCfAccessible, job.CfLoc, job.CfIP = platform.CheckCloudflare(job.Client.Client)
-					if config.GlobalConfig.DropBadCfNodes && !job.IsCfAccessible {
+					if pc.opts.DropBadCfNodes && !job.IsCfAccessible {
+						pc.fail(job, StageCF, start, ReasonCFBlocked, nil)
 						job.Close()
 						// 记录丢弃
 						if job.aliveMarked.CompareAndSwap(false, true) {
@@ -594,6 +837,7 @@ func (pc *ProxyChecker) runAliveStage(ctx context.Context) {
 						}
 						continue
 					}
+					job.trace(StageCF, start, "", nil)
 				}
 
 				// 记录存活
@@ -602,12 +846,12 @@ func (pc *ProxyChecker) runAliveStage(ctx context.Context) {
 				}
 
 				// 流转
-				if speedON {
+				if pc.speedON {
 					select {
 					case pc.speedChan <- job:
 
 					case <-ctx.Done():
-						job.Close()
+						pc.abandon(job, StageSpeed)
 					}
 				} else {
 					// 无测速时：通过 alive 即可视为“可用”，确保 Available 与最终可用数量一致
@@ -617,7 +861,7 @@ func (pc *ProxyChecker) runAliveStage(ctx context.Context) {
 					select {
 					case pc.mediaChan <- job:
 					case <-ctx.Done():
-						job.Close()
+						pc.abandon(job, StageMedia)
 					}
 				}
 			}
@@ -631,7 +875,7 @@ func (pc *ProxyChecker) runAliveStage(ctx context.Context) {
 
 // 测速
 func (pc *ProxyChecker) runSpeedStage(ctx context.Context, cancel context.CancelFunc) {
-	if !speedON {
+	if !pc.speedON {
 		return
 	}
 	defer close(pc.mediaChan)
@@ -639,19 +883,76 @@ func (pc *ProxyChecker) runSpeedStage(ctx context.Context, cancel context.Cancel
 	// 确保达到成功节点数量限制的日志只输出一次
 	var stopOnce sync.Once
 
-	var wg sync.WaitGroup
-	concurrency := pc.speedConcurrent
+	// 达到成功节点数量限制时结束检测
+	checkLimit := func() {
+		if pc.opts.SuccessLimit <= 0 || pc.available.Load() < pc.opts.SuccessLimit {
+			return
+		}
+		stopOnce.Do(func() {
+			pc.stats.SuccessLimited.Store(true)
+			pc.pt.FinishAliveStage()
+			if pc.mediaON {
+				if pc.speedON {
+					pc.stats.SuccessLimited.Store(true)
+					slog.Warn(fmt.Sprintf("达到成功节点数量限制 %d, 等待测速和媒体检测任务完成...", pc.opts.SuccessLimit))
+				} else {
+					pc.stats.SuccessLimited.Store(true)
+					slog.Warn(fmt.Sprintf("达到成功节点数量限制 %d, 等待媒体检测任务完成...", pc.opts.SuccessLimit))
+				}
+			} else {
+				if pc.speedON {
+					pc.stats.SuccessLimited.Store(true)
+					slog.Warn(fmt.Sprintf("达到成功节点数量限制 %d, 等待测速和节点重命名任务完成...", pc.opts.SuccessLimit))
+				} else {
+					pc.stats.SuccessLimited.Store(true)
+					slog.Warn(fmt.Sprintf("达到成功节点数量限制 %d, 等待节点重命名任务完成...", pc.opts.SuccessLimit))
+				}
+			}
 
-	for range concurrency {
+			cancel()
+		})
+	}
+
+	var wg sync.WaitGroup
+	for range pc.speedCtl.gate.max {
 		wg.Go(func() {
-			for job := range pc.speedChan {
-				if checkCtxDone(ctx) {
-					job.Close()
+			for job := range pc.speedCtl.gate.each(pc.speedChan) {
+				if pc.done(ctx) {
+					pc.abandon(job, StageSpeed)
+					continue
+				}
+				// 流量预算不足，仅测活
+				if pc.speedCut.Load() {
+					if job.speedMarked.CompareAndSwap(false, true) {
+						pc.pt.CountSpeed(true)
+						pc.incrementAvailable()
+					}
+					checkLimit()
+					pc.mediaChan <- job
 					continue
 				}
+				start := time.Now()
 				getBytes := func() uint64 { return job.Client.Transport.BytesRead.Load() }
-				speed, _, err := platform.CheckSpeed(job.Client.Client, Bucket, getBytes)
-				success := err == nil && speed >= config.GlobalConfig.MinSpeed
+				sr, err := platform.CheckSpeed(job.Client.Client, pc.speedOptions(), getBytes)
+				speed := sr.Speed
+				job.Result.PeakSpeed, job.Result.TTFB = sr.Peak, sr.TTFB
+				success := err == nil && speed >= pc.opts.MinSpeed
+
+				// 上传测速，仅测试下载通过的节点
+				var upStart time.Time
+				var upReason string
+				var upErr error
+				if success {
+					job.Speed = speed
+					job.Result.Speed = speed
+					job.trace(StageSpeed, start, "", nil)
+					if pc.uploadON {
+						upStart = time.Now()
+						upReason, upErr = pc.checkUpload(job)
+						success = upReason == ""
+					}
+				}
+
 				if job.speedMarked.CompareAndSwap(false, true) {
 					pc.pt.CountSpeed(success)
 					// 仅在测速成功时计入可用数量
@@ -660,37 +961,24 @@ func (pc *ProxyChecker) runSpeedStage(ctx context.Context, cancel context.Cancel
 					}
 				}
 				if !success {
+					switch {
+					case upReason != "":
+						pc.fail(job, StageUpload, upStart, upReason, upErr)
+					case err != nil:
+						pc.fail(job, StageSpeed, start, classifyError(err), err)
+					default:
+						pc.fail(job, StageSpeed, start, ReasonTooSlow,
+							fmt.Errorf("%d KB/s < %d KB/s", speed, pc.opts.MinSpeed))
+					}
 					job.Close()
 					continue
 				}
-				job.Speed = speed
-
-				if config.GlobalConfig.SuccessLimit > 0 && pc.available.Load() >= config.GlobalConfig.SuccessLimit {
-					stopOnce.Do(func() {
-						Successlimited.Store(true)
-						pc.pt.FinishAliveStage()
-						if mediaON {
-							if speedON {
-								Successlimited.Store(true)
-								slog.Warn(fmt.Sprintf("达到成功节点数量限制 %d, 等待测速和媒体检测任务完成...", config.GlobalConfig.SuccessLimit))
-							} else {
-								Successlimited.Store(true)
-								slog.Warn(fmt.Sprintf("达到成功节点数量限制 %d, 等待媒体检测任务完成...", config.GlobalConfig.SuccessLimit))
-							}
-						} else {
-							if speedON {
-								Successlimited.Store(true)
-								slog.Warn(fmt.Sprintf("达到成功节点数量限制 %d, 等待测速和节点重命名任务完成...", config.GlobalConfig.SuccessLimit))
-							} else {
-								Successlimited.Store(true)
-								slog.Warn(fmt.Sprintf("达到成功节点数量限制 %d, 等待节点重命名任务完成...", config.GlobalConfig.SuccessLimit))
-							}
-						}
-
-						cancel()
-					})
+				if pc.uploadON {
+					job.trace(StageUpload, upStart, "", upErr)
 				}
 
+				checkLimit()
+
 				// 流转
 				pc.mediaChan <- job
 			}
@@ -706,8 +994,8 @@ func (pc *ProxyChecker) runSpeedStage(ctx context.Context, cancel context.Cancel
 func (pc *ProxyChecker) runMediaStageAndCollect(db *maxminddb.Reader, ctx context.Context, cancel context.CancelFunc) {
 	var wg sync.WaitGroup
 	resultLength := pc.mediaConcurrent
-	if config.GlobalConfig.SuccessLimit != 0 {
-		resultLength = int(config.GlobalConfig.SuccessLimit)
+	if pc.opts.SuccessLimit != 0 {
+		resultLength = int(pc.opts.SuccessLimit)
 	}
 
 	pc.resultChan = make(chan Result, resultLength)
@@ -722,30 +1010,29 @@ func (pc *ProxyChecker) runMediaStageAndCollect(db *maxminddb.Reader, ctx contex
 	})
 
 	// 启动 workers（确保 collector 已启动以避免阻塞在无缓冲时）
-	concurrency := pc.mediaConcurrent
-	for range concurrency {
+	for range pc.mediaCtl.gate.max {
 		wg.Go(func() {
-			for job := range pc.mediaChan {
-				if !speedON {
+			for job := range pc.mediaCtl.gate.each(pc.mediaChan) {
+				if !pc.speedON {
 					// 只在没开启测速时接受媒体检测停止信号
 					// 丢弃结果
-					if checkCtxDone(ctx) {
-						job.Close()
+					if pc.done(ctx) {
+						pc.abandon(job, StageMedia)
 						continue
 					}
 
 					// 设置成功数量限制
-					if config.GlobalConfig.SuccessLimit > 0 && pc.available.Load() >= config.GlobalConfig.SuccessLimit {
+					if pc.opts.SuccessLimit > 0 && pc.available.Load() >= pc.opts.SuccessLimit {
 						stopOnce.Do(func() {
-							Successlimited.Store(true)
+							pc.stats.SuccessLimited.Store(true)
 							pc.pt.FinishAliveStage()
-							if mediaON {
-								Successlimited.Store(true)
-								slog.Warn(fmt.Sprintf("达到成功节点数量限制 %d, 等待媒体检测任务完成...", config.GlobalConfig.SuccessLimit))
+							if pc.mediaON {
+								pc.stats.SuccessLimited.Store(true)
+								slog.Warn(fmt.Sprintf("达到成功节点数量限制 %d, 等待媒体检测任务完成...", pc.opts.SuccessLimit))
 								slog.Warn("测活模式将丢弃多余结果")
 							} else {
-								Successlimited.Store(true)
-								slog.Warn(fmt.Sprintf("达到成功节点数量限制 %d, 等待节点重命名任务完成...", config.GlobalConfig.SuccessLimit))
+								pc.stats.SuccessLimited.Store(true)
+								slog.Warn(fmt.Sprintf("达到成功节点数量限制 %d, 等待节点重命名任务完成...", pc.opts.SuccessLimit))
 								slog.Warn("测活模式将丢弃多余结果")
 							}
 
@@ -754,13 +1041,27 @@ func (pc *ProxyChecker) runMediaStageAndCollect(db *maxminddb.Reader, ctx contex
 					}
 				}
 
-				if mediaON {
-					for _, plat := range config.GlobalConfig.Platforms {
-						mediaCheck(job, plat, db, ctx)
+				if pc.mediaON {
+					start := time.Now()
+					for _, plat := range pc.platforms {
+						pc.checkMedia(job, plat, db, ctx)
 					}
+					job.trace(StageMedia, start, "", nil)
+				}
+
+				// 查询出口 IP 和位置，节点重命名、出口去重和 isp 检测需要
+				if pc.opts.RenameNode || pc.exitDedupON() || pc.opts.ISPCheck {
+					pc.lookupGeo(job, db, ctx)
+				} else if job.Result.IP == "" {
+					// 只统计出口分组时使用已有的 CF trace，不单独请求
+					job.Result.IP = job.CfIP
+				}
+				if pc.opts.ISPCheck {
+					pc.checkISP(job, db)
 				}
 
-				pc.updateProxyName(&job.Result, job.Client, job.Speed, db, job.CfLoc, job.CfIP, ctx)
+				pc.updateProxyName(&job.Result, job.Speed)
+				pc.finish(job, true)
 
 				// 将结果发送到 collector
 				pc.resultChan <- job.Result
@@ -783,101 +1084,287 @@ func (pc *ProxyChecker) runMediaStageAndCollect(db *maxminddb.Reader, ctx contex
 	pc.pt.refresh()
 }
 
+// observe 将节点检测结果写入健康数据库
+func (pc *ProxyChecker) observe(job *ProxyJob, passed bool) {
+	if pc.healthDB == nil || job.Key == "" || job.Result.Proxy == nil {
+		return
+	}
+	o := health.ObservationFromProxy(job.Result.Proxy)
+	o.Passed = passed
+	o.Speed = job.Speed
+	o.Latency = job.Result.Latency
+	o.IP = job.Result.IP
+	o.Country = job.Result.Country
+	pc.healthDB.Observe(job.Key, o, time.Now())
+}
+
+// openHealthDB 加载节点健康数据库
+func (pc *ProxyChecker) openHealthDB() {
+	if !pc.opts.NodeHealth {
+		return
+	}
+	path, err := health.DefaultPath()
+	if err != nil {
+		slog.Warn(fmt.Sprintf("获取节点健康数据库路径失败: %v", err))
+		return
+	}
+	db, err := health.Open(path)
+	if err != nil {
+		// 文件损坏时重新开始记录
+		slog.Warn(fmt.Sprintf("加载节点健康数据库失败，将重新记录: %v", err))
+		db = health.New(path)
+	}
+	pc.healthDB = db
+}
+
+// healthFlushInterval 检测过程中保存节点健康数据库的间隔
+const healthFlushInterval = 5 * time.Minute
+
+// flushHealthDB 检测过程中定期保存节点健康数据库
+func (pc *ProxyChecker) flushHealthDB(ctx context.Context) {
+	ticker := time.NewTicker(healthFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := pc.healthDB.Save(); err != nil {
+				slog.Warn(fmt.Sprintf("保存节点健康数据库失败: %v", err))
+			}
+		}
+	}
+}
+
+// saveHealthDB 清理过期记录并保存节点健康数据库
+func (pc *ProxyChecker) saveHealthDB() {
+	if pc.healthDB == nil {
+		return
+	}
+	retention := pc.opts.NodeHealthRetention
+	if retention <= 0 {
+		retention = 30
+	}
+	if n := pc.healthDB.Prune(time.Now().AddDate(0, 0, -retention)); n > 0 {
+		slog.Debug(fmt.Sprintf("节点健康数据库清理过期记录: %d", n))
+	}
+	if err := pc.healthDB.Save(); err != nil {
+		slog.Warn(fmt.Sprintf("保存节点健康数据库失败: %v", err))
+		return
+	}
+	slog.Info(fmt.Sprintf("节点健康数据库已更新，记录数量: %d", pc.healthDB.Len()))
+}
+
 // collectResults 收集检测结果
 func (pc *ProxyChecker) collectResults() {
 	for result := range pc.resultChan {
 		pc.results = append(pc.results, result)
+		if pc.onResult != nil {
+			pc.onResult(result)
+		}
 	}
 }
 
-// checkAlive 使用谷歌服务执行基本的存活检测。
-func checkAlive(job *ProxyJob) bool {
-	gstatic, err := platform.CheckGstatic(job.Client.Client)
-	if err == nil && gstatic {
-		return true
+// checkAlive 依次请求测活目标，通过数量达到 alive-quorum 即视为存活
+// 未通过的目标按 alive-retries 重试，每次重试前的等待时间翻倍
+func (s *Session) checkAlive(ctx context.Context, job *ProxyJob) error {
+	passed := make([]bool, len(s.aliveTargets))
+	count := 0
+	defer func() {
+		for i, t := range s.aliveTargets {
+			if passed[i] {
+				job.Result.AliveTargets = append(job.Result.AliveTargets, t.Name)
+			}
+		}
+	}()
+
+	var errs []error
+	backoff := time.Duration(s.opts.AliveRetryBackoff) * time.Millisecond
+	for attempt := 0; attempt <= s.opts.AliveRetries; attempt++ {
+		if attempt > 0 {
+			if s.done(ctx) {
+				break
+			}
+			select {
+			case <-ctx.Done():
+				return errors.Join(errs...)
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+
+		errs = errs[:0]
+		for i, t := range s.aliveTargets {
+			if passed[i] {
+				continue
+			}
+			// 剩余目标全部通过也达不到要求时结束本轮
+			if len(s.aliveTargets)-len(errs) < s.aliveQuorum {
+				break
+			}
+			if err := platform.CheckAlive(job.Client.Client, t); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", t.Name, err))
+				continue
+			}
+			passed[i] = true
+			if count++; count >= s.aliveQuorum {
+				return nil
+			}
+		}
 	}
-	return false
+	return errors.Join(errs...)
+}
+
+// checkLatency 对存活节点进行多次延迟采样，未满足 max-latency 限制时返回失败原因。
+func (s *Session) checkLatency(job *ProxyJob) (string, error) {
+	lat, err := platform.CheckLatency(job.Client.Client, s.opts.LatencySamples)
+	if err != nil {
+		slog.Debug(fmt.Sprintf("延迟测试失败: %v", err))
+		return classifyError(err), err
+	}
+
+	job.Result.Latency = lat.Median
+	job.Result.LatencyMin = lat.Min
+	job.Result.Jitter = lat.Jitter
+	job.Result.Handshake = lat.Handshake
+
+	if maxLatency := s.opts.MaxLatency; maxLatency > 0 && lat.Median > maxLatency {
+		return ReasonHighLatency, fmt.Errorf("%dms > %dms", lat.Median, maxLatency)
+	}
+	return "", nil
+}
+
+// checkUpload 上传测速，未满足 min-upload-speed 时返回失败原因
+func (s *Session) checkUpload(job *ProxyJob) (string, error) {
+	getBytes := func() uint64 { return job.Client.Transport.BytesWritten.Load() }
+	speed, _, err := platform.CheckUpload(job.Client.Client, s.uploadOptions(), getBytes)
+	job.Result.UploadSpeed = speed
+	minSpeed := s.opts.MinUploadSpeed
+	switch {
+	case err != nil && minSpeed > 0:
+		return classifyError(err), err
+	case err != nil:
+		// 未设置上传速度下限时，上传失败不影响节点可用
+		slog.Debug(fmt.Sprintf("上传测速失败: %v", err))
+		return "", err
+	case speed < minSpeed:
+		return ReasonTooSlow, fmt.Errorf("%d KB/s < %d KB/s", speed, minSpeed)
+	}
+	return "", nil
+}
+
+// checkIntegrity 检测节点是否劫持流量，发现时在结果中记录劫持类型
+func (s *Session) checkIntegrity(job *ProxyJob) (string, error) {
+	verdict, err := platform.CheckIntegrity(job.Client.Transport.Base, time.Duration(s.opts.Timeout)*time.Millisecond)
+	if verdict != "" {
+		job.Result.Integrity = verdict
+		slog.Debug(fmt.Sprintf("节点流量劫持: %v", err), "type", verdict)
+	}
+	return verdict, err
 }
 
 // needsCF 判断所选的媒体检测平台是否需要Cloudflare访问权限。
-func needsCF(platforms []string) bool {
-	for _, p := range platforms {
-		if p == "openai" || p == "x" {
+func (s *Session) needsCF() bool {
+	for _, p := range s.platforms {
+		if c, ok := s.lookup(p); ok && c.NeedsCF() {
 			return true
 		}
 	}
 	return false
 }
 
-// mediaCheck 根据平台类型分发到相应的检测函数。
-func mediaCheck(job *ProxyJob, plat string, db *maxminddb.Reader, ctx context.Context) {
-	switch plat {
-	case "x":
-		if job.NeedCF && !job.IsCfAccessible {
-			break
-		}
-		job.Result.X = true
-	case "openai":
-		if job.NeedCF && !job.IsCfAccessible {
-			break
-		}
-		cookiesOK, clientOK := platform.CheckOpenAI(job.Client.Client)
-		if clientOK && cookiesOK {
-			job.Result.Openai = true
-		} else if clientOK || cookiesOK {
-			job.Result.OpenaiWeb = true
-		}
-	case "youtube":
-		if region, _ := platform.CheckYoutube(job.Client.Client); region != "" {
-			job.Result.Youtube = region
-		}
-	case "netflix":
-		if ok, _ := platform.CheckNetflix(job.Client.Client); ok {
-			job.Result.Netflix = true
-		}
-	case "disney":
-		if ok, _ := platform.CheckDisney(job.Client.Client); ok {
-			job.Result.Disney = true
-		}
-	case "gemini":
-		if ok, _ := platform.CheckGemini(job.Client.Client); ok {
-			job.Result.Gemini = true
-		}
-	case "tiktok":
-		if region, _ := platform.CheckTikTok(job.Client.Client); region != "" {
-			job.Result.TikTok = region
-		}
-	case "iprisk":
+// mediaCheck 根据平台名称分发到注册表中对应的检测器。
+func (s *Session) mediaCheck(job *ProxyJob, plat string, db *maxminddb.Reader, ctx context.Context) {
+	// IP 风险检测依赖出口 IP 和地理位置，单独处理
+	if plat == "iprisk" {
 		country, ip, countryCodeTag, _ := proxyutils.GetProxyCountry(job.Client.Client, db, ctx, job.CfLoc, job.CfIP)
 		if ip == "" {
-			break
+			return
 		}
 		job.Result.IP = ip
 		job.Result.Country = country
 		job.Result.CountryCodeTag = countryCodeTag
-		if risk, err := platform.CheckIPRisk(job.Client.Client, ip); err == nil {
+		if risk, err := s.ipRisk(job.Client.Client, ip, nil); err == nil {
 			job.Result.IPRisk = risk
 		} else {
 			// 失败的可能性高，所以放上日志
 			slog.Debug(fmt.Sprintf("查询IP风险失败: %v", err))
 		}
+		return
 	}
+
+	checker, ok := s.lookup(plat)
+	if !ok {
+		return
+	}
+	if checker.NeedsCF() && job.NeedCF && !job.IsCfAccessible {
+		return
+	}
+
+	res, err := checker.Check(ctx, job.Client.Client)
+	if err != nil {
+		slog.Debug(fmt.Sprintf("%s 检测失败: %v", checker.Name(), err))
+	}
+	addPlatform(job, checker.Name(), res)
+}
+
+// addPlatform 记录解锁的平台，未解锁时忽略
+func addPlatform(job *ProxyJob, name string, res platform.PlatformResult) {
+	if !res.OK {
+		return
+	}
+	if job.Result.Platforms == nil {
+		job.Result.Platforms = make(map[string]platform.PlatformResult)
+	}
+	job.Result.Platforms[name] = res
+}
+
+// reLatencyTag 匹配节点名称中已有的延迟标签
+var reLatencyTag = regexp.MustCompile(`\s*\|(?:\s*\d+ms)`)
+
+// reUploadTag 匹配节点名称中已有的上传速度标签
+var reUploadTag = regexp.MustCompile(`\s*\|↑[\d.]+[KM]B/s`)
+
+// formatSpeed 格式化速度标签，speed 单位为 KB/s
+func formatSpeed(speed int) string {
+	if speed < 100 {
+		return fmt.Sprintf("%dKB/s", speed)
+	}
+	return fmt.Sprintf("%.1fMB/s", float64(speed)/1024)
+}
+
+// HijackTag 流量劫持节点的名称标签
+const HijackTag = "⚠️劫持"
+
+// buildMediaTagRegex 根据已注册检测器及 extra 的标签生成旧标签清理正则
+func buildMediaTagRegex(extra ...platform.Checker) *regexp.Regexp {
+	tags := []string{"GPT⁺"}
+	for _, c := range slices.Concat(platform.Checkers(), extra) {
+		if c.Tag() != "" {
+			tags = append(tags, c.Tag())
+		}
+	}
+	// 长标签优先匹配，避免 GPT 抢先匹配 GPT⁺
+	slices.SortFunc(tags, func(a, b string) int { return len(b) - len(a) })
+
+	parts := make([]string, 0, len(tags)+2)
+	for _, t := range tags {
+		parts = append(parts, regexp.QuoteMeta(t)+`(?:-[^|]+)?`)
+	}
+	// IP 风险标签带可选的类别后缀，如 65%-tor
+	parts = append(parts, `KeepSucced|KeepHistory|KeepSuccess`, `\d+%(?:-[a-z]+)?`)
+	return regexp.MustCompile(`\s*\|(?:` + strings.Join(parts, "|") + `)`)
 }
 
 // updateProxyName 更新代理名称
-func (pc *ProxyChecker) updateProxyName(res *Result, httpClient *ProxyClient, speed int, db *maxminddb.Reader, cfLoc string, cfIP string, jctx context.Context) {
+func (pc *ProxyChecker) updateProxyName(res *Result, speed int) {
 	// 以节点IP查询位置重命名（如果开启）
-	if config.GlobalConfig.RenameNode {
-		if res.Country == "" {
-			country, _, countryCodeTag, _ := proxyutils.GetProxyCountry(httpClient.Client, db, jctx, cfLoc, cfIP)
-			res.Country = country
-			res.CountryCodeTag = countryCodeTag
-		}
+	if pc.opts.RenameNode {
 		if res.Country != "" {
-			res.Proxy["name"] = config.GlobalConfig.NodePrefix + proxyutils.Rename(res.Country, res.CountryCodeTag)
+			res.Proxy["name"] = pc.opts.NodePrefix + proxyutils.Rename(res.Country, res.CountryCodeTag)
 		} else {
 			originName := res.Proxy["name"].(string)
-			res.Proxy["name"] = config.GlobalConfig.NodePrefix + proxyutils.Rename(res.Country, res.CountryCodeTag) + originName
+			res.Proxy["name"] = pc.opts.NodePrefix + proxyutils.Rename(res.Country, res.CountryCodeTag) + originName
 		}
 	}
 
@@ -887,71 +1374,73 @@ func (pc *ProxyChecker) updateProxyName(res *Result, httpClient *ProxyClient, sp
 	}
 
 	var tags []string
+	// 延迟标签
+	if pc.latencyON && res.Latency > 0 {
+		name = reLatencyTag.ReplaceAllString(name, "")
+		tags = append(tags, fmt.Sprintf("%dms", res.Latency))
+	}
+
 	// 速度标签
-	if config.GlobalConfig.SpeedTestURL != "" && speed > 0 {
+	if pc.opts.SpeedTestURL != "" && speed > 0 {
 		name = regexp.MustCompile(`\s*\|(?:\s*[\d.]+[KM]B/s)`).ReplaceAllString(name, "")
-		var speedStr string
-		if speed < 100 {
-			speedStr = fmt.Sprintf("%dKB/s", speed)
-		} else {
-			speedStr = fmt.Sprintf("%.1fMB/s", float64(speed)/1024)
+		tags = append(tags, formatSpeed(speed))
+	}
+
+	// 上传速度标签
+	if pc.uploadON {
+		name = reUploadTag.ReplaceAllString(name, "")
+		if res.UploadSpeed > 0 {
+			tags = append(tags, "↑"+formatSpeed(res.UploadSpeed))
 		}
-		tags = append(tags, speedStr)
 	}
 
-	if config.GlobalConfig.MediaCheck {
+	if pc.opts.MediaCheck {
 		// 移除旧标签
-		name = regexp.MustCompile(`\s*\|(?:NF|D\+|GPT⁺|GPT|GM|X|YT|KeepSucced|KeepHistory|KeepSuccess|YT-[^|]+|TK|TK-[^|]+|\d+%)`).ReplaceAllString(name, "")
+		name = pc.mediaTags.ReplaceAllString(name, "")
 	}
 
-	// 平台标签（按用户配置顺序）
-	for _, plat := range config.GlobalConfig.Platforms {
-		switch plat {
-		case "openai":
-			if res.Openai {
-				tags = append(tags, "GPT⁺")
-			} else if res.OpenaiWeb {
-				tags = append(tags, "GPT")
-			}
-		case "x":
-			if res.X && !strings.Contains(name, "⁻¹") && !strings.Contains(name, "🏴‍☠️") {
-				tags = append(tags, "X")
-			}
-		case "netflix":
-			if res.Netflix {
-				tags = append(tags, "NF")
-			}
-		case "disney":
-			if res.Disney {
-				tags = append(tags, "D+")
-			}
-		case "gemini":
-			if res.Gemini {
-				tags = append(tags, "GM")
-			}
-		case "iprisk":
+	// 流量劫持标签
+	name = strings.ReplaceAll(name, "|"+HijackTag, "")
+	if res.Integrity != "" {
+		tags = append(tags, HijackTag)
+	}
+
+	// UDP 标签
+	if pc.udpON {
+		name = reUDPTag.ReplaceAllString(name, "")
+		if res.UDP {
+			tags = append(tags, UDPTag)
+		}
+	}
+
+	// 平台标签（按用户配置顺序，自定义探测在后）
+	for _, plat := range pc.platforms {
+		if plat == "iprisk" {
 			if res.IPRisk != "" {
 				tags = append(tags, res.IPRisk)
 			}
-		case "youtube":
-			if res.Youtube != "" {
-				// 只有YouTube地区和节点位置不一致时才添加YouTube地区
-				if res.Country != res.Youtube {
-					tags = append(tags, fmt.Sprintf("YT-%s", res.Youtube))
-				} else {
-					tags = append(tags, "YT")
-				}
-			}
-		case "tiktok":
-			if res.TikTok != "" {
-				// 只有TikTok地区和节点位置不一致时才添加TikTok地区
-				if res.Country != res.TikTok {
-					tags = append(tags, fmt.Sprintf("TK-%s", res.TikTok))
-				} else {
-					tags = append(tags, "TK")
-				}
-			}
+			continue
+		}
+
+		checker, ok := pc.lookup(plat)
+		if !ok {
+			continue
+		}
+		pr, ok := res.Platforms[checker.Name()]
+		if !ok || !pr.OK {
+			continue
+		}
+		// 节点 CF 受限时不添加标签(如 X)
+		if platform.HideOnCFBlock(checker) && (strings.Contains(name, "⁻¹") || strings.Contains(name, "🏴‍☠️")) {
+			continue
 		}
+
+		tag := platform.TagOf(checker, pr)
+		// 只有解锁地区和节点位置不一致时才添加地区
+		if pr.Region != "" && pr.Region != res.Country {
+			tag = fmt.Sprintf("%s-%s", tag, pr.Region)
+		}
+		tags = append(tags, tag)
 	}
 
 	if tag, ok := res.Proxy["sub_tag"].(string); ok && tag != "" {
@@ -959,11 +1448,8 @@ func (pc *ProxyChecker) updateProxyName(res *Result, httpClient *ProxyClient, sp
 	}
 
 	// 运营商标签
-	if config.GlobalConfig.ISPCheck {
-		ISPTag := proxyutils.GetISPInfo(httpClient.Client)
-		if ISPTag != "" {
-			tags = append(tags, ISPTag)
-		}
+	if res.ISP != "" {
+		tags = append(tags, res.ISP)
 	}
 
 	// 将所有标记添加到名称中
@@ -980,20 +1466,31 @@ type ProxyClient struct {
 	ctx       context.Context
 	cancel    context.CancelFunc
 	mProxy    constant.Proxy
+	stats     *Stats // 关闭时累加流量
 }
 
-// CreateClient 创建独立的代理客户端
-func CreateClient(mapping map[string]any) *ProxyClient {
-	pc := &ProxyClient{}
+// CreateClient 创建独立的代理客户端，流量计入 Check() 的统计
+func CreateClient(mapping map[string]any) (*ProxyClient, error) {
+	s := &Session{
+		opts:   OptionsFromConfig(config.GlobalConfig),
+		stats:  &defaultStats,
+		bucket: Bucket,
+	}
+	return s.newClient(mapping)
+}
+
+// newClient 创建独立的代理客户端，使用会话的超时、限速和流量统计
+func (s *Session) newClient(mapping map[string]any) (*ProxyClient, error) {
+	pc := &ProxyClient{stats: s.stats}
 
 	var err error
-	resolver.DisableIPv6 = !config.GlobalConfig.EnableIPv6
+	resolver.DisableIPv6 = !s.opts.EnableIPv6
 
 	// 解析代理
 	pc.mProxy, err = adapter.ParseProxy(mapping)
 	if err != nil {
 		slog.Debug(fmt.Sprintf("底层mihomo创建代理Client失败: %v", err))
-		return nil
+		return nil, err
 	}
 
 	// 初始化全局控制 Context
@@ -1043,6 +1540,7 @@ func CreateClient(mapping map[string]any) *ProxyClient {
 
 			return &countingConn{
 				Conn:         rawConn,
+				bucket:       s.bucket,
 				readCounter:  &statsTransport.BytesRead,
 				writeCounter: &statsTransport.BytesWritten,
 				networkLimit: networkLimitDefault,
@@ -1063,11 +1561,11 @@ func CreateClient(mapping map[string]any) *ProxyClient {
 	pc.Transport = statsTransport
 
 	pc.Client = &http.Client{
-		Timeout:   time.Duration(config.GlobalConfig.Timeout) * time.Millisecond,
+		Timeout:   time.Duration(s.opts.Timeout) * time.Millisecond,
 		Transport: statsTransport,
 	}
 
-	return pc
+	return pc, nil
 }
 
 // Close 关闭客户端，释放所有资源
@@ -1101,12 +1599,12 @@ func (pc *ProxyClient) Close() {
 		bytesWritten := pc.Transport.BytesWritten.Load()
 
 		if bytesRead > 0 {
-			DOWN.Add(bytesRead)
-			TotalBytes.Add(bytesRead)
+			pc.stats.DOWN.Add(bytesRead)
+			pc.stats.TotalBytes.Add(bytesRead)
 		}
 		if bytesWritten > 0 {
-			UP.Add(bytesWritten)
-			TotalBytes.Add(bytesWritten)
+			pc.stats.UP.Add(bytesWritten)
+			pc.stats.TotalBytes.Add(bytesWritten)
 		}
 
 		if pc.Transport.Base != nil {
@@ -1131,6 +1629,7 @@ func (s *StatsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 // countingConn 包裹 net.Conn，在网络连接层统计读/写字节数。
 type countingConn struct {
 	net.Conn
+	bucket       *ratelimit.Bucket
 	readCounter  *atomic.Uint64
 	writeCounter *atomic.Uint64
 	networkLimit bool
@@ -1141,8 +1640,8 @@ func (c *countingConn) Read(b []byte) (int, error) {
 	if n > 0 {
 		c.readCounter.Add(uint64(n))
 		// 在连接层消耗 token
-		if Bucket != nil && c.networkLimit {
-			Bucket.Wait(int64(n))
+		if c.bucket != nil && c.networkLimit {
+			c.bucket.Wait(int64(n))
 		}
 	}
 	return n, err
@@ -1152,6 +1651,10 @@ func (c *countingConn) Write(b []byte) (int, error) {
 	n, err := c.Conn.Write(b)
 	if n > 0 {
 		c.writeCounter.Add(uint64(n))
+		// 上传与下载共用限速
+		if c.bucket != nil && c.networkLimit {
+			c.bucket.Wait(int64(n))
+		}
 	}
 	return n, err
 }
@@ -1159,18 +1662,5 @@ func (c *countingConn) Write(b []byte) (int, error) {
 // 工具函数
 func (pc *ProxyChecker) incrementAvailable() {
 	pc.available.Add(1)
-	Available.Add(1)
-}
-
-// checkCtxDone 提供一个非阻塞的检查，判断上下文是否已结束或是否收到强制关闭信号。
-func checkCtxDone(c context.Context) bool {
-	if ForceClose.Load() {
-		return true
-	}
-	select {
-	case <-c.Done():
-		return true
-	default:
-		return false
-	}
+	pc.stats.Available.Add(1)
 }
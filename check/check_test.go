@@ -0,0 +1,31 @@
+package check
+
+import "testing"
+
+func TestMediaTagRegex(t *testing.T) {
+	re := buildMediaTagRegex()
+	tests := map[string]string{
+		"🇭🇰HK¹⁺|GPT⁺|YT-US|NF|85%": "🇭🇰HK¹⁺",
+		"🇯🇵JP²|GM|TK|D+|X":         "🇯🇵JP²",
+		"🇺🇸US¹|KeepSuccess":        "🇺🇸US¹",
+		"🇸🇬SG²|订阅A":                "🇸🇬SG²|订阅A",
+		"🇩🇪DE¹|NF|80%-tor":         "🇩🇪DE¹",
+	}
+	for in, want := range tests {
+		if got := re.ReplaceAllString(in, ""); got != want {
+			t.Errorf("清理 %q = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestUpdateProxyNameIdempotent(t *testing.T) {
+	pc := NewSession(Options{MediaCheck: true, Platforms: []string{"iprisk"}}).newChecker(1)
+	res := &Result{Proxy: map[string]any{"name": "🇩🇪DE¹"}, IPRisk: "65%-tor"}
+
+	pc.updateProxyName(res, 0)
+	first := res.Proxy["name"]
+	pc.updateProxyName(res, 0)
+	if got := res.Proxy["name"]; got != first || got != "🇩🇪DE¹|65%-tor" {
+		t.Errorf("重复重命名 = %q, 第一次 %q", got, first)
+	}
+}
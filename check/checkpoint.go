@@ -0,0 +1,294 @@
+package check
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"maps"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	proxyutils "github.com/sinspired/subs-check-pro/proxy"
+	"github.com/sinspired/subs-check-pro/save/method"
+	"github.com/sinspired/subs-check-pro/utils"
+)
+
+// 断点文件，保存在 output/stats 目录
+const (
+	CheckpointFileName      = "checkpoint.yaml"       // 检测进度
+	CheckpointNodesFileName = "checkpoint-nodes.yaml" // 本轮待检测节点(已排序)
+)
+
+// checkpointVersion 断点格式版本，不一致时放弃恢复
+const checkpointVersion = 1
+
+// resumeRequested 下一次检测是否从断点恢复
+var resumeRequested atomic.Bool
+
+// RequestResume 请求下一次检测从断点恢复，无断点时正常检测
+func RequestResume() {
+	resumeRequested.Store(true)
+}
+
+// HasCheckpoint 是否存在未完成的检测断点
+func HasCheckpoint() bool {
+	dir, err := checkpointDir()
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(filepath.Join(dir, CheckpointFileName))
+	return err == nil
+}
+
+// checkpointState 断点文件内容
+type checkpointState struct {
+	Version    int                           `yaml:"version"`
+	StartedAt  time.Time                     `yaml:"started-at"`
+	SavedAt    time.Time                     `yaml:"saved-at"`
+	Total      int                           `yaml:"total"`
+	Done       string                        `yaml:"done"` // 已完成节点位图(base64)
+	TotalBytes uint64                        `yaml:"total-bytes"`
+	SubStats   map[string]proxyutils.SubStat `yaml:"sub-stats"`
+	Failures   *failureSnapshot              `yaml:"failures"`
+	Results    []Result                      `yaml:"results"`
+}
+
+// checkpoint 记录已完成节点和可用结果，定期落盘
+type checkpoint struct {
+	mu        sync.Mutex
+	dir       string
+	startedAt time.Time
+	total     int
+	done      []byte
+	doneCount int
+	results   []Result
+	dirty     bool
+
+	// 恢复时读取的历史数据
+	restored   *checkpointState
+	subStats   map[string]proxyutils.SubStat
+	totalBytes uint64
+}
+
+func checkpointDir() (string, error) {
+	saver, err := method.NewStatsSaver()
+	if err != nil {
+		return "", err
+	}
+	return saver.StatsPath, nil
+}
+
+// newCheckpoint 保存本轮节点列表并创建断点
+func newCheckpoint(proxies []map[string]any) (*checkpoint, error) {
+	dir, err := checkpointDir()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("创建目录失败: %w", err)
+	}
+
+	// 先删除旧断点，避免节点列表与进度不匹配
+	removeCheckpointFiles(dir)
+
+	data, err := yaml.Marshal(map[string]any{"proxies": proxies})
+	if err != nil {
+		return nil, fmt.Errorf("序列化节点列表失败: %w", err)
+	}
+	if err := utils.WriteFileAtomic(filepath.Join(dir, CheckpointNodesFileName), data, 0o644); err != nil {
+		removeCheckpointFiles(dir)
+		return nil, err
+	}
+
+	return &checkpoint{
+		dir:       dir,
+		startedAt: time.Now(),
+		total:     len(proxies),
+		done:      make([]byte, (len(proxies)+7)/8),
+		subStats:  cloneSubStats(proxyutils.SubStats),
+		dirty:     true,
+	}, nil
+}
+
+// loadCheckpoint 读取断点和节点列表
+func loadCheckpoint() (*checkpoint, []map[string]any, error) {
+	dir, err := checkpointDir()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, CheckpointFileName))
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取断点失败: %w", err)
+	}
+	var st checkpointState
+	if err := yaml.Unmarshal(data, &st); err != nil {
+		return nil, nil, fmt.Errorf("解析断点失败: %w", err)
+	}
+	if st.Version != checkpointVersion {
+		return nil, nil, fmt.Errorf("断点版本不匹配: %d", st.Version)
+	}
+
+	data, err = os.ReadFile(filepath.Join(dir, CheckpointNodesFileName))
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取断点节点列表失败: %w", err)
+	}
+	var nodes struct {
+		Proxies []map[string]any `yaml:"proxies"`
+	}
+	if err := yaml.Unmarshal(data, &nodes); err != nil {
+		return nil, nil, fmt.Errorf("解析断点节点列表失败: %w", err)
+	}
+
+	done, err := base64.StdEncoding.DecodeString(st.Done)
+	if err != nil {
+		return nil, nil, fmt.Errorf("解析断点进度失败: %w", err)
+	}
+	if len(nodes.Proxies) != st.Total || len(done) != (st.Total+7)/8 {
+		return nil, nil, fmt.Errorf("断点与节点列表不匹配")
+	}
+
+	doneCount := 0
+	for _, b := range done {
+		doneCount += bits.OnesCount8(b)
+	}
+
+	return &checkpoint{
+		dir:        dir,
+		startedAt:  st.StartedAt,
+		total:      st.Total,
+		done:       done,
+		doneCount:  doneCount,
+		results:    st.Results,
+		restored:   &st,
+		subStats:   st.SubStats,
+		totalBytes: st.TotalBytes,
+	}, nodes.Proxies, nil
+}
+
+// isDone 节点是否已在上次运行中完成检测
+func (c *checkpoint) isDone(i int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.done[i/8]&(1<<(i%8)) != 0
+}
+
+// markDone 标记节点完成，passed 时记录可用结果
+func (c *checkpoint) markDone(i int, result *Result) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if i < 0 || i >= c.total || c.done[i/8]&(1<<(i%8)) != 0 {
+		return
+	}
+	c.done[i/8] |= 1 << (i % 8)
+	c.doneCount++
+	if result != nil {
+		c.results = append(c.results, *result)
+	}
+	c.dirty = true
+}
+
+// save 写入断点文件，无变化时跳过
+func (c *checkpoint) save(failures *FailureStats, totalBytes uint64) error {
+	c.mu.Lock()
+	if !c.dirty {
+		c.mu.Unlock()
+		return nil
+	}
+	// 仅在锁内复制，序列化在锁外进行，避免阻塞检测
+	st := checkpointState{
+		Version:    checkpointVersion,
+		StartedAt:  c.startedAt,
+		SavedAt:    time.Now(),
+		Total:      c.total,
+		Done:       base64.StdEncoding.EncodeToString(c.done),
+		TotalBytes: totalBytes,
+		SubStats:   c.subStats,
+		Results:    slices.Clone(c.results),
+	}
+	c.dirty = false
+	c.mu.Unlock()
+
+	if failures != nil {
+		st.Failures = failures.snapshot()
+	}
+	data, err := yaml.Marshal(&st)
+	if err != nil {
+		return fmt.Errorf("序列化断点失败: %w", err)
+	}
+	return utils.WriteFileAtomic(filepath.Join(c.dir, CheckpointFileName), data, 0o644)
+}
+
+// remove 检测正常结束后删除断点
+func (c *checkpoint) remove() {
+	removeCheckpointFiles(c.dir)
+}
+
+// discardCheckpoint 删除无法恢复或已被新一轮检测取代的断点
+func discardCheckpoint() {
+	if dir, err := checkpointDir(); err == nil {
+		removeCheckpointFiles(dir)
+	}
+}
+
+// removeCheckpointFiles 同时删除断点和节点列表，避免遗留较大的节点列表文件
+func removeCheckpointFiles(dir string) {
+	_ = os.Remove(filepath.Join(dir, CheckpointFileName))
+	_ = os.Remove(filepath.Join(dir, CheckpointNodesFileName))
+}
+
+// restore 将断点中的统计恢复到本轮检测
+func (pc *ProxyChecker) restore(c *checkpoint) {
+	proxyutils.SubStats = cloneSubStats(c.subStats)
+	pc.stats.TotalBytes.Store(c.totalBytes)
+
+	if c.restored != nil && c.restored.Failures != nil {
+		pc.failures.merge(c.restored.Failures)
+	}
+
+	passed := len(c.results)
+	failed := c.doneCount - passed
+	pt := pc.pt
+	pt.aliveDone.Add(int32(c.doneCount))
+	pt.aliveSuccess.Add(int32(passed))
+	if pc.speedON {
+		pt.speedDone.Add(int32(passed))
+		pt.speedSuccess.Add(int32(passed))
+	}
+	pt.mediaDone.Add(int32(passed))
+	pc.results = append(pc.results, c.results...)
+	pc.available.Add(int32(passed))
+	pc.stats.Available.Add(uint32(passed))
+	pt.refresh()
+
+	slog.Info(fmt.Sprintf("已从断点恢复检测: 已完成 %d/%d, 可用 %d, 失败 %d, 断点时间 %s",
+		c.doneCount, c.total, passed, failed, c.restored.SavedAt.Format(time.DateTime)))
+}
+
+// saveCheckpoint 保存断点
+func (pc *ProxyChecker) saveCheckpoint() {
+	if pc.ckpt == nil {
+		return
+	}
+	if err := pc.ckpt.save(pc.failures, pc.stats.TotalBytes.Load()); err != nil {
+		slog.Warn(fmt.Sprintf("保存检测断点失败: %v", err))
+	}
+}
+
+// checkpointInterval 断点保存间隔，<=0 表示关闭断点
+func (s *Session) checkpointInterval() time.Duration {
+	return time.Duration(s.opts.CheckpointInterval) * time.Second
+}
+
+func cloneSubStats(src map[string]proxyutils.SubStat) map[string]proxyutils.SubStat {
+	if src == nil {
+		return make(map[string]proxyutils.SubStat)
+	}
+	return maps.Clone(src)
+}
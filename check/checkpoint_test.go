@@ -0,0 +1,88 @@
+package check
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sinspired/subs-check-pro/check/platform"
+	"github.com/sinspired/subs-check-pro/config"
+)
+
+func TestCheckpointRoundTrip(t *testing.T) {
+	orig := config.GlobalConfig.OutputDir
+	config.GlobalConfig.OutputDir = t.TempDir()
+	t.Cleanup(func() { config.GlobalConfig.OutputDir = orig })
+
+	proxies := []map[string]any{
+		{"name": "a", "type": "ss", "server": "1.1.1.1", "port": 443},
+		{"name": "b", "type": "vmess", "server": "2.2.2.2", "port": 8443},
+		{"name": "c", "type": "trojan", "server": "3.3.3.3", "port": 443},
+	}
+	c, err := newCheckpoint(proxies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.markDone(0, nil)
+	c.markDone(2, &Result{
+		Proxy:     proxies[2],
+		Latency:   88,
+		Platforms: map[string]platform.PlatformResult{"youtube": {OK: true, Region: "US"}},
+	})
+	c.markDone(2, nil) // 重复标记忽略
+
+	failures := newFailureStats()
+	failures.Add("https://a.example/sub", "ss", StageAlive, ReasonTimeout)
+	if err := c.save(failures, 0); err != nil {
+		t.Fatal(err)
+	}
+	if !HasCheckpoint() {
+		t.Fatal("保存后应存在断点")
+	}
+
+	got, nodes, err := loadCheckpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 3 || nodes[1]["name"] != "b" {
+		t.Fatalf("节点列表恢复错误: %v", nodes)
+	}
+	if got.doneCount != 2 || !got.isDone(0) || got.isDone(1) || !got.isDone(2) {
+		t.Errorf("完成位图恢复错误: count=%d", got.doneCount)
+	}
+	if len(got.results) != 1 || got.results[0].Latency != 88 || !got.results[0].Platforms["youtube"].OK {
+		t.Errorf("结果恢复错误: %+v", got.results)
+	}
+	if got.restored.Failures.Total["alive/timeout"] != 1 {
+		t.Errorf("失败统计恢复错误: %+v", got.restored.Failures)
+	}
+
+	got.remove()
+	if HasCheckpoint() {
+		t.Error("删除后不应存在断点")
+	}
+	if _, err := os.Stat(filepath.Join(got.dir, CheckpointNodesFileName)); !os.IsNotExist(err) {
+		t.Error("删除后不应存在断点节点列表")
+	}
+}
+
+func TestDiscardCheckpoint(t *testing.T) {
+	orig := config.GlobalConfig.OutputDir
+	config.GlobalConfig.OutputDir = t.TempDir()
+	t.Cleanup(func() { config.GlobalConfig.OutputDir = orig })
+
+	c, err := newCheckpoint([]map[string]any{{"name": "a", "type": "ss", "server": "1.1.1.1", "port": 443}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.save(nil, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	discardCheckpoint()
+	for _, name := range []string{CheckpointFileName, CheckpointNodesFileName} {
+		if _, err := os.Stat(filepath.Join(c.dir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s 未删除", name)
+		}
+	}
+}
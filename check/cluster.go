@@ -0,0 +1,679 @@
+package check
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/sinspired/subs-check-pro/check/health"
+	"github.com/sinspired/subs-check-pro/config"
+	proxyutils "github.com/sinspired/subs-check-pro/proxy"
+	"github.com/sinspired/subs-check-pro/utils"
+)
+
+// 分布式检测模式
+const (
+	ModeCoordinator = "coordinator"
+	ModeWorker      = "worker"
+)
+
+// 协调节点接口路径
+const (
+	ClusterLeasePath  = "/api/cluster/lease"
+	ClusterReportPath = "/api/cluster/report"
+)
+
+var (
+	// ErrNoClusterRun 协调节点当前没有进行中的检测
+	ErrNoClusterRun = errors.New("没有进行中的分布式检测")
+	// ErrStaleLease 分片租约已过期或已被重新分配
+	ErrStaleLease = errors.New("分片租约已失效")
+)
+
+// ShardLease 协调节点分配给 worker 的分片
+type ShardLease struct {
+	RunID        string           `yaml:"run-id"`
+	ShardID      int              `yaml:"shard-id"`
+	LeaseID      string           `yaml:"lease-id"`
+	LeaseTimeout int              `yaml:"lease-timeout"` // 秒，worker 须在此时间内上报
+	Proxies      []map[string]any `yaml:"proxies"`
+}
+
+// ShardReport worker 上报的分片进度和新增结果，无结果时作为心跳
+type ShardReport struct {
+	RunID   string   `yaml:"run-id"`
+	ShardID int      `yaml:"shard-id"`
+	LeaseID string   `yaml:"lease-id"`
+	Worker  string   `yaml:"worker"`
+	Checked int      `yaml:"checked"` // 已检测节点数(累计)
+	Bytes   uint64   `yaml:"bytes"`   // 消耗流量(累计)
+	Results []Result `yaml:"results"` // 本次新增的可用结果
+	Done    bool     `yaml:"done"`
+	Release bool     `yaml:"release"` // 未检测完时交还分片，由协调节点重新排队
+}
+
+// ClusterInfo 协调节点状态
+type ClusterInfo struct {
+	RunID   string               `json:"runId"`
+	Shards  int                  `json:"shards"`
+	Pending int                  `json:"pending"`
+	Leased  int                  `json:"leased"`
+	Done    int                  `json:"done"`
+	Workers map[string]time.Time `json:"workers"` // worker -> 最后上报时间
+}
+
+type shardState int
+
+const (
+	shardPending shardState = iota
+	shardLeased
+	shardDone
+)
+
+type shard struct {
+	proxies  []map[string]any
+	state    shardState
+	leaseID  string
+	worker   string
+	deadline time.Time
+	checked  int
+	bytes    uint64
+	results  []Result
+}
+
+// coordinator 管理一轮分布式检测的分片队列
+type coordinator struct {
+	mu      sync.Mutex
+	runID   string
+	timeout time.Duration
+	shards  []*shard
+	queue   []int
+	done    int
+	closed  bool
+	workers map[string]time.Time
+}
+
+// activeCoordinator 当前进行中的分布式检测，供 HTTP 接口访问
+var activeCoordinator atomic.Pointer[coordinator]
+
+func newCoordinator(proxies []map[string]any, shardSize int, timeout time.Duration) *coordinator {
+	if shardSize <= 0 {
+		shardSize = 2000
+	}
+	c := &coordinator{
+		runID:   randomID(),
+		timeout: timeout,
+		workers: make(map[string]time.Time),
+	}
+	for start := 0; start < len(proxies); start += shardSize {
+		end := min(start+shardSize, len(proxies))
+		c.queue = append(c.queue, len(c.shards))
+		c.shards = append(c.shards, &shard{proxies: proxies[start:end]})
+	}
+	return c
+}
+
+// lease 分配一个待检测分片，没有时返回 nil
+func (c *coordinator) lease(worker string, now time.Time) *ShardLease {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.workers[worker] = now
+	if c.closed || len(c.queue) == 0 {
+		return nil
+	}
+
+	id := c.queue[0]
+	c.queue = c.queue[1:]
+	s := c.shards[id]
+	s.state = shardLeased
+	s.leaseID = randomID()
+	s.worker = worker
+	s.deadline = now.Add(c.timeout)
+
+	return &ShardLease{
+		RunID:        c.runID,
+		ShardID:      id,
+		LeaseID:      s.leaseID,
+		LeaseTimeout: int(c.timeout.Seconds()),
+		Proxies:      s.proxies,
+	}
+}
+
+// report 合并 worker 上报的进度和结果，并续期租约
+func (c *coordinator) report(r *ShardReport, now time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if r.RunID != c.runID || c.closed {
+		return ErrNoClusterRun
+	}
+	if r.ShardID < 0 || r.ShardID >= len(c.shards) {
+		return ErrStaleLease
+	}
+	s := c.shards[r.ShardID]
+	if s.state != shardLeased || s.leaseID != r.LeaseID {
+		return ErrStaleLease
+	}
+
+	c.workers[r.Worker] = now
+	if r.Release {
+		c.requeue(r.ShardID)
+		return nil
+	}
+	s.deadline = now.Add(c.timeout)
+	s.checked = min(r.Checked, len(s.proxies))
+	s.bytes = r.Bytes
+	s.results = append(s.results, r.Results...)
+	if r.Done {
+		s.state = shardDone
+		s.checked = len(s.proxies)
+		c.done++
+	}
+	return nil
+}
+
+// requeueExpired 将租约过期的分片重新排队，丢弃其部分结果，返回失联的 worker
+func (c *coordinator) requeueExpired(now time.Time) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var lost []string
+	for id, s := range c.shards {
+		if s.state != shardLeased || now.Before(s.deadline) {
+			continue
+		}
+		lost = append(lost, s.worker)
+		c.requeue(id)
+	}
+	return lost
+}
+
+// requeue 丢弃分片的部分结果并重新排队，调用方需持有锁
+func (c *coordinator) requeue(id int) {
+	s := c.shards[id]
+	*s = shard{proxies: s.proxies}
+	// 重新排队的分片优先分配
+	c.queue = append([]int{id}, c.queue...)
+}
+
+// stats 返回已检测节点数、可用节点数和消耗流量
+func (c *coordinator) stats() (checked, available int, traffic uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, s := range c.shards {
+		checked += s.checked
+		available += len(s.results)
+		traffic += s.bytes
+	}
+	return checked, available, traffic
+}
+
+// lastSeen 返回 worker 最后一次领取或上报的时间，没有 worker 时为零值
+func (c *coordinator) lastSeen() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var last time.Time
+	for _, t := range c.workers {
+		if t.After(last) {
+			last = t
+		}
+	}
+	return last
+}
+
+func (c *coordinator) finished() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.done == len(c.shards)
+}
+
+// close 停止分配分片，返回所有已收到的结果
+func (c *coordinator) close() []Result {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	var results []Result
+	for _, s := range c.shards {
+		results = append(results, s.results...)
+	}
+	return results
+}
+
+// eachDone 遍历已完成的分片，返回分片节点和可用结果
+func (c *coordinator) eachDone(fn func(proxies []map[string]any, results []Result)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, s := range c.shards {
+		if s.state == shardDone {
+			fn(s.proxies, s.results)
+		}
+	}
+}
+
+func (c *coordinator) info() *ClusterInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	info := &ClusterInfo{
+		RunID:   c.runID,
+		Shards:  len(c.shards),
+		Workers: make(map[string]time.Time, len(c.workers)),
+	}
+	for _, s := range c.shards {
+		switch s.state {
+		case shardPending:
+			info.Pending++
+		case shardLeased:
+			info.Leased++
+		case shardDone:
+			info.Done++
+		}
+	}
+	for w, t := range c.workers {
+		info.Workers[w] = t
+	}
+	return info
+}
+
+// ClusterLease 为 worker 分配分片，没有待检测分片时返回 nil
+func ClusterLease(worker string) *ShardLease {
+	c := activeCoordinator.Load()
+	if c == nil {
+		return nil
+	}
+	return c.lease(worker, time.Now())
+}
+
+// ClusterReport 接收 worker 上报
+func ClusterReport(r *ShardReport) error {
+	c := activeCoordinator.Load()
+	if c == nil {
+		return ErrNoClusterRun
+	}
+	return c.report(r, time.Now())
+}
+
+// ClusterStatus 返回协调节点状态，没有进行中的分布式检测时返回 nil
+func ClusterStatus() *ClusterInfo {
+	c := activeCoordinator.Load()
+	if c == nil {
+		return nil
+	}
+	return c.info()
+}
+
+// runCoordinator 协调模式：将节点分片交给 worker 检测，合并结果后统一分析
+func (pc *ProxyChecker) runCoordinator(proxies []map[string]any) ([]Result, error) {
+	dc := pc.opts.Distributed
+	if dc.Token == "" {
+		return nil, fmt.Errorf("分布式检测未设置 token")
+	}
+	timeout := time.Duration(dc.LeaseTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = 120 * time.Second
+	}
+
+	c := newCoordinator(proxies, dc.ShardSize, timeout)
+	activeCoordinator.Store(c)
+	defer activeCoordinator.Store(nil)
+
+	st := pc.stats
+	st.ProxyCount.Store(uint32(len(proxies)))
+	st.StartTime = time.Now()
+	slog.Info(fmt.Sprintf("协调模式: %d 个节点分为 %d 个分片，等待 worker 领取", len(proxies), len(c.shards)))
+
+	// 没有 worker 存活时不再无限等待
+	stall := 3 * timeout
+	var deadline time.Time
+	if dc.RunTimeout > 0 {
+		deadline = st.StartTime.Add(time.Duration(dc.RunTimeout) * time.Minute)
+	}
+	runLimit := uint64(max(pc.opts.TrafficBudgetPerRun, 0)) * 1024 * 1024
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		for _, w := range c.requeueExpired(now) {
+			slog.Warn(fmt.Sprintf("worker %s 超时未上报，分片已重新排队", w))
+		}
+
+		checked, available, traffic := c.stats()
+		st.Progress.Store(uint32(checked))
+		st.Available.Store(uint32(available))
+		st.TotalBytes.Store(traffic)
+
+		if c.finished() {
+			break
+		}
+		last := c.lastSeen()
+		if now.Sub(st.StartTime) > stall && now.Sub(last) > stall {
+			if last.IsZero() {
+				slog.Warn(fmt.Sprintf("%s 内没有 worker 领取分片，结束本轮检测", stall))
+			} else {
+				slog.Warn(fmt.Sprintf("%s 内没有 worker 上报，结束本轮检测并收集已有结果", stall))
+			}
+			break
+		}
+		if !deadline.IsZero() && now.After(deadline) {
+			slog.Warn(fmt.Sprintf("超过 run-timeout %d 分钟，结束本轮检测并收集已有结果", dc.RunTimeout))
+			break
+		}
+		if runLimit > 0 && traffic >= runLimit {
+			slog.Warn("流量预算已耗尽，结束检测并收集已有结果")
+			break
+		}
+		if st.ForceClose.Load() {
+			slog.Warn("用户手动结束检测,等待收集结果")
+			break
+		}
+		if limit := pc.opts.SuccessLimit; limit > 0 && available >= int(limit) {
+			st.SuccessLimited.Store(true)
+			slog.Info(fmt.Sprintf("达到成功节点数量限制 %d, 收集结果完成。", limit))
+			break
+		}
+	}
+
+	pc.results = c.close()
+	pc.observeShards(c)
+
+	// 标记检测完成，开始处理结果，保存，上传等
+	st.ProcessResults.Store(true)
+	st.ETASeconds.Store(0)
+
+	slog.Info(fmt.Sprintf("可用节点数量: %d", len(pc.results)))
+	st.Traffic = utils.FormatTraffic(st.TotalBytes.Load())
+	slog.Info(fmt.Sprintf("检测消耗流量: %s", st.Traffic))
+
+	st.EndTime = time.Now()
+	st.Duration = st.EndTime.Sub(st.StartTime)
+
+	pc.saveHealthDB()
+
+	pc.collectSubStats()
+	proxyutils.UpdateSubHealth(proxyutils.SubStats)
+	pc.dedupExits()
+	pc.GenerateAnalysisReport()
+	pc.CleanupMetadata()
+	return pc.results, nil
+}
+
+// observeShards 将 worker 检测完成的分片记入节点健康数据库，未出现在结果中的节点视为失败
+func (pc *ProxyChecker) observeShards(c *coordinator) {
+	if pc.healthDB == nil {
+		return
+	}
+	c.eachDone(func(proxies []map[string]any, results []Result) {
+		passed := make(map[string]*Result, len(results))
+		for i := range results {
+			passed[health.Key(results[i].Proxy)] = &results[i]
+		}
+		for _, p := range proxies {
+			key := health.Key(p)
+			job := &ProxyJob{Result: Result{Proxy: p}, Key: key}
+			r, ok := passed[key]
+			if ok {
+				job.Result = *r
+				job.Speed = r.Speed
+			}
+			pc.observe(job, ok)
+		}
+	})
+}
+
+// RunWorker 工作模式：循环从协调节点领取分片并检测，直到 ctx 结束
+// busy 在检测分片期间为 true
+func RunWorker(ctx context.Context, busy *atomic.Bool) {
+	dc := config.GlobalConfig.Distributed
+	if dc.Token == "" || dc.Coordinator == "" {
+		slog.Error("worker 模式需要设置 distributed.token 和 distributed.coordinator")
+		return
+	}
+	name := dc.WorkerName
+	if name == "" {
+		name, _ = os.Hostname()
+	}
+
+	w := &workerClient{
+		base:   strings.TrimRight(dc.Coordinator, "/"),
+		token:  dc.Token,
+		name:   name,
+		client: &http.Client{Timeout: 2 * time.Minute},
+	}
+	slog.Info("worker 模式已启动", "coordinator", w.base, "worker", name)
+
+	const idle = 10 * time.Second
+	// 本月流量预算用完时暂停领取，避免反复领取又交还分片
+	const budgetIdle = time.Hour
+	for {
+		wait := idle
+		lease, err := w.lease(ctx)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("领取分片失败: %v", err))
+		}
+		if lease != nil {
+			busy.Store(true)
+			err = w.runShard(ctx, lease)
+			busy.Store(false)
+			if !errors.Is(err, ErrTrafficBudgetExhausted) {
+				continue
+			}
+			slog.Warn(fmt.Sprintf("本月流量预算已用完，%s 后再领取分片", budgetIdle))
+			wait = budgetIdle
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// workerClient 与协调节点通信
+type workerClient struct {
+	base   string
+	token  string
+	name   string
+	client *http.Client
+
+	traceRun string // 检测轨迹对应的协调节点轮次
+}
+
+func (w *workerClient) lease(ctx context.Context) (*ShardLease, error) {
+	u := w.base + ClusterLeasePath + "?worker=" + url.QueryEscape(w.name)
+	resp, err := w.do(ctx, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNoContent {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("http status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var lease ShardLease
+	if err := yaml.Unmarshal(data, &lease); err != nil {
+		return nil, fmt.Errorf("解析分片失败: %w", err)
+	}
+	return &lease, nil
+}
+
+// send 上报进度和结果，租约失效时返回 ErrStaleLease
+func (w *workerClient) send(ctx context.Context, r *ShardReport) error {
+	data, err := yaml.Marshal(r)
+	if err != nil {
+		return err
+	}
+	resp, err := w.do(ctx, w.base+ClusterReportPath, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusConflict:
+		return ErrStaleLease
+	}
+	return fmt.Errorf("http status %d", resp.StatusCode)
+}
+
+func (w *workerClient) do(ctx context.Context, u string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+w.token)
+	req.Header.Set("Content-Type", "application/yaml")
+	return w.client.Do(req)
+}
+
+// runShard 检测分片，期间定期上报新结果作为心跳
+//
+// 未检测完全部节点时(流量预算耗尽、手动结束等)交还分片，由协调节点重新分配。
+func (w *workerClient) runShard(ctx context.Context, lease *ShardLease) error {
+	slog.Info(fmt.Sprintf("领取分片 %d，节点数量: %d", lease.ShardID, len(lease.Proxies)))
+
+	// 分析和清理元数据由协调节点完成；进度写入包级统计，供 /api/status 查看
+	// 成功数量限制由协调节点按全部结果判断，worker 须检测完整个分片
+	opts := OptionsFromConfig(config.GlobalConfig)
+	opts.Report = false
+	opts.SuccessLimit = 0
+	checker := newSession(opts, &defaultStats).newChecker(len(lease.Proxies))
+	// 每轮分布式检测重新生成检测轨迹，同一轮次的分片追加写入
+	checker.traceAppend = lease.RunID == w.traceRun
+	w.traceRun = lease.RunID
+	checker.openHealthDB()
+
+	shardCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	var mu sync.Mutex
+	var pending []Result
+	checker.onResult = func(r Result) {
+		mu.Lock()
+		pending = append(pending, r)
+		mu.Unlock()
+	}
+
+	report := func(done bool) error {
+		mu.Lock()
+		batch := pending
+		pending = nil
+		mu.Unlock()
+
+		r := &ShardReport{
+			RunID:   lease.RunID,
+			ShardID: lease.ShardID,
+			LeaseID: lease.LeaseID,
+			Worker:  w.name,
+			Checked: int(checker.pt.aliveDone.Load()),
+			Bytes:   checker.stats.TotalBytes.Load(),
+			Results: batch,
+			Done:    done,
+		}
+		err := w.send(ctx, r)
+		if err != nil && !errors.Is(err, ErrStaleLease) {
+			// 网络错误时保留结果，下次重试
+			mu.Lock()
+			pending = append(batch, pending...)
+			mu.Unlock()
+		}
+		return err
+	}
+
+	// 按租约时间的 1/3 上报，保证网络抖动时不被判定失联
+	interval := max(time.Duration(lease.LeaseTimeout)*time.Second/3, 5*time.Second)
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := report(false); errors.Is(err, ErrStaleLease) {
+					slog.Warn(fmt.Sprintf("分片 %d 已被协调节点收回，停止检测", lease.ShardID))
+					cancel(ErrStaleLease)
+					return
+				} else if err != nil {
+					slog.Debug(fmt.Sprintf("上报分片进度失败: %v", err))
+				}
+			}
+		}
+	})
+
+	results, err := checker.run(shardCtx, lease.Proxies)
+	close(stop)
+	wg.Wait()
+
+	// 检测中断时交还分片，已被收回的分片无需交还
+	if cause := context.Cause(shardCtx); errors.Is(cause, ErrStaleLease) {
+		return cause
+	}
+	if checker.spent.Load() {
+		err = ErrTrafficBudgetExhausted
+	}
+	checked := int(checker.pt.aliveDone.Load())
+	if err == nil && (shardCtx.Err() != nil || checker.stats.ForceClose.Load() || checked < len(lease.Proxies)) {
+		err = fmt.Errorf("已检测 %d/%d", checked, len(lease.Proxies))
+	}
+	if err != nil {
+		w.release(ctx, lease, err)
+		return err
+	}
+	for attempt := range 3 {
+		err := report(true)
+		if err == nil {
+			slog.Info(fmt.Sprintf("分片 %d 检测完成，可用节点: %d", lease.ShardID, len(results)))
+			return nil
+		}
+		if errors.Is(err, ErrStaleLease) {
+			slog.Warn(fmt.Sprintf("分片 %d 已被协调节点收回，丢弃结果", lease.ShardID))
+			return err
+		}
+		slog.Warn(fmt.Sprintf("提交分片 %d 结果失败(%d/3): %v", lease.ShardID, attempt+1, err))
+		time.Sleep(5 * time.Second)
+	}
+	return nil
+}
+
+// release 交还未检测完的分片，失败时等待租约过期后由协调节点重新排队
+func (w *workerClient) release(ctx context.Context, lease *ShardLease, cause error) {
+	slog.Warn(fmt.Sprintf("分片 %d 未检测完，交还协调节点: %v", lease.ShardID, cause))
+	// 退出时 ctx 已取消，仍尝试通知协调节点
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+	r := &ShardReport{
+		RunID:   lease.RunID,
+		ShardID: lease.ShardID,
+		LeaseID: lease.LeaseID,
+		Worker:  w.name,
+		Release: true,
+	}
+	if err := w.send(ctx, r); err != nil && !errors.Is(err, ErrStaleLease) {
+		slog.Debug(fmt.Sprintf("交还分片 %d 失败: %v", lease.ShardID, err))
+	}
+}
+
+func randomID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,143 @@
+package check
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sinspired/subs-check-pro/check/health"
+)
+
+func TestCoordinatorLease(t *testing.T) {
+	proxies := make([]map[string]any, 5)
+	for i := range proxies {
+		proxies[i] = map[string]any{"name": i}
+	}
+	now := time.Now()
+	c := newCoordinator(proxies, 2, time.Minute)
+	if len(c.shards) != 3 {
+		t.Fatalf("shards = %d, want 3", len(c.shards))
+	}
+
+	a := c.lease("a", now)
+	b := c.lease("b", now)
+	if a == nil || b == nil || a.ShardID == b.ShardID {
+		t.Fatalf("lease failed: %v %v", a, b)
+	}
+
+	// 过期租约重新排队，旧租约上报被拒绝
+	lost := c.requeueExpired(now.Add(2 * time.Minute))
+	if len(lost) != 2 {
+		t.Fatalf("lost = %v, want 2 workers", lost)
+	}
+	stale := &ShardReport{RunID: a.RunID, ShardID: a.ShardID, LeaseID: a.LeaseID, Done: true}
+	if err := c.report(stale, now); !errors.Is(err, ErrStaleLease) {
+		t.Fatalf("stale report err = %v", err)
+	}
+
+	for range 3 {
+		l := c.lease("a", now)
+		if l == nil {
+			t.Fatal("expected shard")
+		}
+		r := &ShardReport{
+			RunID:   l.RunID,
+			ShardID: l.ShardID,
+			LeaseID: l.LeaseID,
+			Worker:  "a",
+			Results: []Result{{Proxy: l.Proxies[0]}},
+			Done:    true,
+		}
+		if err := c.report(r, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if c.lease("a", now) != nil {
+		t.Fatal("unexpected shard after all leased")
+	}
+	if !c.finished() {
+		t.Fatal("coordinator not finished")
+	}
+	checked, available, _ := c.stats()
+	if checked != 5 || available != 3 {
+		t.Fatalf("stats = %d/%d, want 5/3", checked, available)
+	}
+
+	results := c.close()
+	if len(results) != 3 {
+		t.Fatalf("results = %d, want 3", len(results))
+	}
+	if err := c.report(&ShardReport{RunID: c.runID}, now); !errors.Is(err, ErrNoClusterRun) {
+		t.Fatalf("report after close err = %v", err)
+	}
+}
+
+func TestCoordinatorRelease(t *testing.T) {
+	proxies := make([]map[string]any, 4)
+	for i := range proxies {
+		proxies[i] = map[string]any{"name": i}
+	}
+	now := time.Now()
+	c := newCoordinator(proxies, 2, time.Minute)
+
+	a := c.lease("a", now)
+	progress := &ShardReport{
+		RunID:   a.RunID,
+		ShardID: a.ShardID,
+		LeaseID: a.LeaseID,
+		Worker:  "a",
+		Checked: 1,
+		Results: []Result{{Proxy: a.Proxies[0]}},
+	}
+	if err := c.report(progress, now); err != nil {
+		t.Fatal(err)
+	}
+
+	// 交还的分片丢弃部分结果并优先重新分配
+	release := &ShardReport{RunID: a.RunID, ShardID: a.ShardID, LeaseID: a.LeaseID, Worker: "a", Release: true}
+	if err := c.report(release, now); err != nil {
+		t.Fatal(err)
+	}
+	if checked, available, _ := c.stats(); checked != 0 || available != 0 {
+		t.Fatalf("stats after release = %d/%d, want 0/0", checked, available)
+	}
+	b := c.lease("b", now)
+	if b == nil || b.ShardID != a.ShardID {
+		t.Fatalf("released shard not leased again: %v", b)
+	}
+	if err := c.report(release, now); !errors.Is(err, ErrStaleLease) {
+		t.Fatalf("release with old lease err = %v", err)
+	}
+}
+
+func TestObserveShards(t *testing.T) {
+	proxies := []map[string]any{
+		{"name": "a", "type": "ss", "server": "1.1.1.1", "port": 1},
+		{"name": "b", "type": "ss", "server": "2.2.2.2", "port": 2},
+	}
+	now := time.Now()
+	c := newCoordinator(proxies, 2, time.Minute)
+	if !c.lastSeen().IsZero() {
+		t.Fatal("lastSeen should be zero before any worker")
+	}
+	l := c.lease("a", now)
+	if got := c.lastSeen(); !got.Equal(now) {
+		t.Fatalf("lastSeen = %v, want %v", got, now)
+	}
+	r := &ShardReport{RunID: l.RunID, ShardID: l.ShardID, LeaseID: l.LeaseID, Worker: "a", Results: []Result{{Proxy: proxies[0], Speed: 100}}, Done: true}
+	if err := c.report(r, now); err != nil {
+		t.Fatal(err)
+	}
+	c.close()
+
+	pc := NewSession(Options{}).newChecker(len(proxies))
+	pc.healthDB = health.New(filepath.Join(t.TempDir(), "health.json"))
+	pc.observeShards(c)
+
+	a, _ := pc.healthDB.Get(health.Key(proxies[0]))
+	b, _ := pc.healthDB.Get(health.Key(proxies[1]))
+	if a.Passes != 1 || a.LastSpeed != 100 || b.Checks != 1 || b.Passes != 0 {
+		t.Errorf("a = %+v, b = %+v", a, b)
+	}
+}
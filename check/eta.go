@@ -3,22 +3,21 @@ package check
 import (
 	"math"
 	"sync"
-	"sync/atomic"
 	"time"
 )
 
-// ETASeconds 供 /api/status 读取：-1=计算中, 0=空闲/完成, >0=剩余秒数
-var ETASeconds atomic.Int64
-
 type ratePoint struct {
 	t time.Time
 	n uint32
 }
 
-var (
-	rateHistMu sync.Mutex
-	rateHist   []ratePoint
+// etaTracker 记录进度快照，用于计算实时速率
+type etaTracker struct {
+	mu   sync.Mutex
+	hist []ratePoint
+}
 
+var (
 	histRateMu sync.RWMutex
 	histRate   float64 // 上次检测速率（节点/秒），冷启动参考
 )
@@ -30,41 +29,42 @@ func SetHistoricalRate(nodesPerSec float64) {
 	histRateMu.Unlock()
 }
 
-// ResetETA 新检测开始时清空快照（histRate 保留，供本轮冷启动使用）
-func ResetETA() {
-	rateHistMu.Lock()
-	rateHist = rateHist[:0]
-	rateHistMu.Unlock()
-	ETASeconds.Store(-1)
+// resetETA 新检测开始时清空快照（histRate 保留，供本轮冷启动使用）
+func (s *Session) resetETA() {
+	s.eta.mu.Lock()
+	s.eta.hist = s.eta.hist[:0]
+	s.eta.mu.Unlock()
+	s.stats.ETASeconds.Store(-1)
 }
 
-// SnapshotRate 每 500ms 由后台 goroutine 调用，记录进度快照
-func SnapshotRate() {
-	rateHistMu.Lock()
-	defer rateHistMu.Unlock()
+// snapshotRate 每 500ms 由后台 goroutine 调用，记录进度快照
+func (s *Session) snapshotRate() {
+	s.eta.mu.Lock()
+	defer s.eta.mu.Unlock()
 	now := time.Now()
-	rateHist = append(rateHist, ratePoint{t: now, n: Progress.Load()})
+	s.eta.hist = append(s.eta.hist, ratePoint{t: now, n: s.stats.Progress.Load()})
 	cutoff := now.Add(-60 * time.Second)
 	i := 0
-	for i < len(rateHist) && rateHist[i].t.Before(cutoff) {
+	for i < len(s.eta.hist) && s.eta.hist[i].t.Before(cutoff) {
 		i++
 	}
 	if i > 0 {
-		rateHist = append(rateHist[:0], rateHist[i:]...)
+		s.eta.hist = append(s.eta.hist[:0], s.eta.hist[i:]...)
 	}
 }
 
-// UpdateETA 根据实时速率与历史速率融合计算剩余时间
-func UpdateETA() {
-	total := int64(ProxyCount.Load())
-	done := int64(Progress.Load())
+// updateETA 根据实时速率与历史速率融合计算剩余时间
+func (s *Session) updateETA() {
+	st := s.stats
+	total := int64(st.ProxyCount.Load())
+	done := int64(st.Progress.Load())
 	if total <= 0 || done >= total {
-		ETASeconds.Store(0)
+		st.ETASeconds.Store(0)
 		return
 	}
-	elapsed := time.Since(CheckStartTime).Seconds()
+	elapsed := time.Since(st.StartTime).Seconds()
 	if elapsed < 3 {
-		ETASeconds.Store(-1)
+		st.ETASeconds.Store(-1)
 		return
 	}
 
@@ -73,16 +73,16 @@ func UpdateETA() {
 
 	// 滑动窗口实时速率
 	var rtRate float64
-	rateHistMu.Lock()
-	if len(rateHist) >= 2 {
-		oldest := rateHist[0]
+	s.eta.mu.Lock()
+	if len(s.eta.hist) >= 2 {
+		oldest := s.eta.hist[0]
 		winSec := time.Since(oldest.t).Seconds()
-		winN := int64(Progress.Load()) - int64(oldest.n)
+		winN := int64(st.Progress.Load()) - int64(oldest.n)
 		if winSec > 0 && winN >= 0 {
 			rtRate = float64(winN) / winSec
 		}
 	}
-	rateHistMu.Unlock()
+	s.eta.mu.Unlock()
 
 	// 全局平均兜底（刷新页面后立即可用）
 	if rtRate <= 0 && elapsed > 0 && done > 0 {
@@ -113,8 +113,8 @@ func UpdateETA() {
 	}
 
 	if finalRate <= 0 {
-		ETASeconds.Store(-1)
+		st.ETASeconds.Store(-1)
 		return
 	}
-	ETASeconds.Store(int64(math.Ceil(float64(remaining) / finalRate)))
+	st.ETASeconds.Store(int64(math.Ceil(float64(remaining) / finalRate)))
 }
@@ -0,0 +1,200 @@
+package check
+
+import (
+	"cmp"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/netip"
+	"slices"
+
+	"github.com/oschwald/maxminddb-golang/v2"
+	"github.com/sinspired/subs-check-pro/assets"
+)
+
+// 出口去重模式
+const (
+	ExitDedupOff      = "off"       // 只统计，不去重
+	ExitDedupKeepBest = "keep-best" // 每个出口保留最优的 1 个节点
+	ExitDedupKeepN    = "keep-n"    // 每个出口保留最优的 exit-dedup-keep 个节点
+)
+
+// 出口分组方式
+const (
+	ExitGroupIP     = "ip"     // 相同出口 IP
+	ExitGroupSubnet = "subnet" // 相同网段：IPv4 /24，IPv6 /48
+	ExitGroupASN    = "asn"    // 相同自治系统，需要 ASN 数据库
+)
+
+// exitClusterTopN 分析报告中列出的最大分组数量
+const exitClusterTopN = 10
+
+// ExitCluster 共用同一出口的节点分组
+type ExitCluster struct {
+	Key  string // 出口 IP、网段或 AS 号
+	Size int    // 分组内可用节点数量
+	Kept int    // 去重后保留的数量
+}
+
+// exitStats 出口分组统计，写入分析报告
+type exitStats struct {
+	By       string
+	Mode     string
+	Clusters []ExitCluster // 按节点数量降序，仅包含多于 1 个节点的分组
+	Groups   int           // 分组总数
+	Unknown  int           // 无出口 IP 的节点数量
+	Removed  int           // 去重移除的节点数量
+	// 各订阅被去重移除的节点数量
+	RemovedBySub map[string]int
+}
+
+// asnRecord GeoLite2-ASN 数据库记录
+type asnRecord struct {
+	Number uint `maxminddb:"autonomous_system_number"`
+}
+
+// exitKey 返回节点出口的分组键，出口 IP 未知时返回空
+func exitKey(res *Result, by string, asnDB *maxminddb.Reader) string {
+	addr, err := netip.ParseAddr(res.IP)
+	if err != nil {
+		return ""
+	}
+	addr = addr.Unmap()
+
+	switch by {
+	case ExitGroupSubnet:
+		bits := 24
+		if addr.Is6() {
+			bits = 48
+		}
+		prefix, _ := addr.Prefix(bits)
+		return prefix.String()
+	case ExitGroupASN:
+		if asnDB != nil {
+			var rec asnRecord
+			if err := asnDB.Lookup(addr).Decode(&rec); err == nil && rec.Number > 0 {
+				return fmt.Sprintf("AS%d", rec.Number)
+			}
+		}
+		// 数据库中没有记录时退化为按 IP 分组
+	}
+	return addr.String()
+}
+
+// compareExit 节点优劣排序：下载速度降序，延迟升序，未知延迟排在最后
+func compareExit(a, b *Result) int {
+	if c := cmp.Compare(b.Speed, a.Speed); c != 0 {
+		return c
+	}
+	la, lb := a.Latency, b.Latency
+	if la <= 0 {
+		la = math.MaxInt
+	}
+	if lb <= 0 {
+		lb = math.MaxInt
+	}
+	return cmp.Compare(la, lb)
+}
+
+// dedupExits 按出口分组，每组保留最优的 keep 个节点，keep <= 0 时只统计
+//
+// 返回保留的结果，顺序与输入一致。
+func dedupExits(results []Result, by string, keep int, asnDB *maxminddb.Reader) ([]Result, *exitStats) {
+	st := &exitStats{By: by}
+	groups := make(map[string][]int)
+	var order []string
+	for i := range results {
+		key := exitKey(&results[i], by, asnDB)
+		if key == "" {
+			st.Unknown++
+			continue
+		}
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], i)
+	}
+	st.Groups = len(groups)
+
+	drop := make([]bool, len(results))
+	for _, key := range order {
+		idx := groups[key]
+		kept := len(idx)
+		if keep > 0 && len(idx) > keep {
+			ranked := slices.Clone(idx)
+			slices.SortStableFunc(ranked, func(a, b int) int { return compareExit(&results[a], &results[b]) })
+			for _, i := range ranked[keep:] {
+				drop[i] = true
+			}
+			kept = keep
+		}
+		if len(idx) > 1 {
+			st.Clusters = append(st.Clusters, ExitCluster{Key: key, Size: len(idx), Kept: kept})
+		}
+	}
+	slices.SortStableFunc(st.Clusters, func(a, b ExitCluster) int { return b.Size - a.Size })
+
+	out := results[:0]
+	for i := range results {
+		if drop[i] {
+			st.Removed++
+			if u, ok := results[i].Proxy["sub_url"].(string); ok {
+				if st.RemovedBySub == nil {
+					st.RemovedBySub = make(map[string]int)
+				}
+				st.RemovedBySub[u]++
+			}
+			continue
+		}
+		out = append(out, results[i])
+	}
+	clear(results[len(out):])
+	return out, st
+}
+
+// exitDedupON 是否启用出口去重
+func (s *Session) exitDedupON() bool {
+	return s.opts.ExitDedup != "" && s.opts.ExitDedup != ExitDedupOff
+}
+
+// dedupExits 按配置对可用结果进行出口去重，统计写入 pc.exits
+func (pc *ProxyChecker) dedupExits() {
+	mode, by := pc.opts.ExitDedup, pc.opts.ExitDedupBy
+	if mode == "" {
+		mode = ExitDedupOff
+	}
+	if by == "" {
+		by = ExitGroupIP
+	}
+
+	// 检测阶段已打开的数据库直接复用，协调节点汇总时单独打开
+	asnDB := pc.asnDB
+	if by == ExitGroupASN && asnDB == nil {
+		if db, err := assets.OpenASNDB(pc.opts.ASNDBPath); err != nil {
+			slog.Warn(fmt.Sprintf("打开 ASN 数据库失败，按出口 IP 分组: %v", err))
+		} else {
+			asnDB = db
+			defer db.Close()
+		}
+	}
+
+	keep := 0
+	switch mode {
+	case ExitDedupKeepBest:
+		keep = 1
+	case ExitDedupKeepN:
+		keep = max(pc.opts.ExitDedupKeep, 1)
+	}
+
+	var st *exitStats
+	pc.results, st = dedupExits(pc.results, by, keep, asnDB)
+	st.Mode = mode
+	pc.exits = st
+
+	if st.Removed > 0 {
+		slog.Info(fmt.Sprintf("出口去重: 移除 %d 个重复出口节点，剩余 %d", st.Removed, len(pc.results)),
+			"by", by, "clusters", len(st.Clusters))
+	} else if len(st.Clusters) > 0 {
+		slog.Debug(fmt.Sprintf("共用出口的节点分组: %d", len(st.Clusters)), "by", by)
+	}
+}
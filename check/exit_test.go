@@ -0,0 +1,80 @@
+package check
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDedupExits(t *testing.T) {
+	results := func() []Result {
+		return []Result{
+			{Proxy: map[string]any{"name": "a"}, IP: "1.1.1.1", Speed: 100, Latency: 80},
+			{Proxy: map[string]any{"name": "b"}, IP: "1.1.1.1", Speed: 500, Latency: 200},
+			{Proxy: map[string]any{"name": "c"}, IP: "1.1.1.2", Speed: 300},
+			{Proxy: map[string]any{"name": "d"}, IP: "::ffff:1.1.1.1", Speed: 500, Latency: 90},
+			{Proxy: map[string]any{"name": "e"}},
+			{Proxy: map[string]any{"name": "f"}, IP: "2001:db8:1::1", Speed: 10},
+			{Proxy: map[string]any{"name": "g"}, IP: "2001:db8:1:2::1", Speed: 20},
+		}
+	}
+	names := func(rs []Result) string {
+		var s []string
+		for _, r := range rs {
+			s = append(s, r.Proxy["name"].(string))
+		}
+		return strings.Join(s, ",")
+	}
+
+	tests := []struct {
+		by       string
+		keep     int
+		want     string
+		clusters int
+		top      ExitCluster
+	}{
+		{ExitGroupIP, 0, "a,b,c,d,e,f,g", 1, ExitCluster{Key: "1.1.1.1", Size: 3, Kept: 3}},
+		{ExitGroupIP, 1, "c,d,e,f,g", 1, ExitCluster{Key: "1.1.1.1", Size: 3, Kept: 1}},
+		{ExitGroupIP, 2, "b,c,d,e,f,g", 1, ExitCluster{Key: "1.1.1.1", Size: 3, Kept: 2}},
+		{ExitGroupSubnet, 1, "d,e,g", 2, ExitCluster{Key: "1.1.1.0/24", Size: 4, Kept: 1}},
+		// 未提供数据库时按 IP 分组
+		{ExitGroupASN, 1, "c,d,e,f,g", 1, ExitCluster{Key: "1.1.1.1", Size: 3, Kept: 1}},
+	}
+	for _, tt := range tests {
+		got, st := dedupExits(results(), tt.by, tt.keep, nil)
+		if names(got) != tt.want {
+			t.Errorf("%s/keep=%d: got %s, want %s", tt.by, tt.keep, names(got), tt.want)
+		}
+		if len(st.Clusters) != tt.clusters || st.Clusters[0] != tt.top {
+			t.Errorf("%s/keep=%d: clusters = %+v", tt.by, tt.keep, st.Clusters)
+		}
+		if st.Unknown != 1 || st.Removed != 7-len(got) {
+			t.Errorf("%s/keep=%d: unknown=%d removed=%d", tt.by, tt.keep, st.Unknown, st.Removed)
+		}
+	}
+}
+
+func TestExitOfReusesCFTrace(t *testing.T) {
+	pc := &ProxyChecker{}
+
+	// 测活阶段已检测 CF 时复用结果，Client 为 nil，重新请求会 panic
+	job := &ProxyJob{NeedCF: true, CfLoc: "HKG", CfIP: "1.1.1.1"}
+	if got := pc.exitOf(job, true); got != "1.1.1.1@HKG" {
+		t.Errorf("exitOf() = %q, want 1.1.1.1@HKG", got)
+	}
+	job = &ProxyJob{NeedCF: true}
+	if got := pc.exitOf(job, false); got != "" || job.traced {
+		t.Errorf("CF 检测失败时 exitOf() = %q, traced = %v", got, job.traced)
+	}
+}
+
+func TestDedupExitsRemovedBySub(t *testing.T) {
+	results := []Result{
+		{Proxy: map[string]any{"name": "a", "sub_url": "s1"}, IP: "1.1.1.1", Speed: 100},
+		{Proxy: map[string]any{"name": "b", "sub_url": "s2"}, IP: "1.1.1.1", Speed: 500},
+		{Proxy: map[string]any{"name": "c", "sub_url": "s2"}, IP: "2.2.2.2", Speed: 100},
+	}
+	_, st := dedupExits(results, ExitGroupIP, 1, nil)
+	if st.Removed != 1 || st.RemovedBySub["s1"] != 1 || st.RemovedBySub["s2"] != 0 {
+		t.Errorf("removed = %d, by sub = %v", st.Removed, st.RemovedBySub)
+	}
+}
@@ -0,0 +1,210 @@
+package check
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang/v2"
+	"github.com/sinspired/subs-check-pro/check/exitcache"
+	"github.com/sinspired/subs-check-pro/check/platform"
+	proxyutils "github.com/sinspired/subs-check-pro/proxy"
+)
+
+// 出口缓存中地理位置和 IP 风险的检测项名称，媒体平台使用平台名称
+const (
+	cacheGeo    = "geo"
+	cacheIPRisk = "iprisk"
+)
+
+// defaultExitCacheTTL exit-cache-ttl 未设置时的缓存有效期
+const defaultExitCacheTTL = 6 * time.Hour
+
+// geoCache 地理位置缓存结果
+type geoCache struct {
+	IP      string `json:"ip"`
+	Country string `json:"country"`
+	Tag     string `json:"tag"`
+}
+
+// exitCacheTTL 返回检测项的缓存有效期
+func (s *Session) exitCacheTTL(name string) time.Duration {
+	if m, ok := s.opts.ExitCachePlatformTTL[name]; ok && m > 0 {
+		return time.Duration(m) * time.Minute
+	}
+	if s.opts.ExitCacheTTL > 0 {
+		return time.Duration(s.opts.ExitCacheTTL) * time.Minute
+	}
+	return defaultExitCacheTTL
+}
+
+// openExitCache 加载出口缓存
+func (pc *ProxyChecker) openExitCache() {
+	if !pc.opts.ExitCache {
+		return
+	}
+	path, err := exitcache.DefaultPath()
+	if err != nil {
+		slog.Warn(fmt.Sprintf("获取出口缓存路径失败: %v", err))
+		return
+	}
+	c, err := exitcache.Open(path)
+	if err != nil {
+		// 文件损坏时重新开始缓存
+		slog.Warn(fmt.Sprintf("加载出口缓存失败，将重新缓存: %v", err))
+		c = exitcache.New(path)
+	}
+	pc.exitCache = c
+}
+
+// saveExitCache 输出命中统计，清理过期结果并保存出口缓存
+func (pc *ProxyChecker) saveExitCache() {
+	if pc.exitCache == nil {
+		return
+	}
+	names, stats := pc.exitCache.Stats()
+	if len(names) > 0 {
+		var hits, misses int
+		parts := make([]string, 0, len(names))
+		for _, name := range names {
+			c := stats[name]
+			hits += c.Hits
+			misses += c.Misses
+			parts = append(parts, fmt.Sprintf("%s %d/%d", name, c.Hits, c.Hits+c.Misses))
+		}
+		slog.Info(fmt.Sprintf("出口缓存: 命中 %d, 未命中 %d", hits, misses), "detail", strings.Join(parts, ", "))
+	}
+
+	if n := pc.exitCache.Prune(time.Now(), pc.exitCacheTTL); n > 0 {
+		slog.Debug(fmt.Sprintf("出口缓存清理过期结果: %d", n))
+	}
+	if err := pc.exitCache.Save(); err != nil {
+		slog.Warn(fmt.Sprintf("保存出口缓存失败: %v", err))
+	}
+}
+
+// cfTrace 返回节点的 CF trace 结果
+//
+// 测活阶段已检测 CF 时直接复用(包括失败的结果)，否则只单独请求一次并保存到 job。
+func (job *ProxyJob) cfTrace() (loc, ip string) {
+	if !job.NeedCF && !job.traced {
+		job.traced = true
+		job.CfLoc, job.CfIP = platform.GetCFTrace(job.Client.Client)
+	}
+	return job.CfLoc, job.CfIP
+}
+
+// exitOf 返回节点的出口标识，获取失败时返回空
+//
+// withLoc 为 true 时附加 CF 节点位置，用于结果受 CF 中转位置影响的检测项。
+func (pc *ProxyChecker) exitOf(job *ProxyJob, withLoc bool) string {
+	loc, ip := job.cfTrace()
+	if ip == "" {
+		return ""
+	}
+	if withLoc {
+		return ip + "@" + loc
+	}
+	return ip
+}
+
+// checkMedia 执行平台检测，启用出口缓存时优先使用相同出口的检测结果
+func (pc *ProxyChecker) checkMedia(job *ProxyJob, plat string, db *maxminddb.Reader, ctx context.Context) {
+	if plat == "iprisk" {
+		pc.checkIPRisk(job, db, ctx)
+		return
+	}
+
+	if pc.exitCache == nil {
+		pc.mediaCheck(job, plat, db, ctx)
+		return
+	}
+
+	checker, ok := pc.lookup(plat)
+	if !ok {
+		return
+	}
+	if checker.NeedsCF() && job.NeedCF && !job.IsCfAccessible {
+		return
+	}
+
+	now := time.Now()
+	exit := pc.exitOf(job, checker.NeedsCF())
+	var res platform.PlatformResult
+	if exit != "" && pc.exitCache.Get(exit, plat, pc.exitCacheTTL(plat), now, &res) {
+		addPlatform(job, checker.Name(), res)
+		return
+	}
+
+	res, err := checker.Check(ctx, job.Client.Client)
+	if err != nil {
+		// 请求失败的结果不缓存
+		slog.Debug(fmt.Sprintf("%s 检测失败: %v", checker.Name(), err))
+	} else if exit != "" {
+		pc.exitCache.Put(exit, plat, res, now)
+	}
+	addPlatform(job, checker.Name(), res)
+}
+
+// checkIPRisk 查询出口 IP 风险，在线查询的结果按出口 IP 缓存
+func (pc *ProxyChecker) checkIPRisk(job *ProxyJob, db *maxminddb.Reader, ctx context.Context) {
+	pc.lookupGeo(job, db, ctx)
+	ip := job.Result.IP
+	if ip == "" {
+		return
+	}
+
+	// 离线评估无需请求，不使用缓存
+	cached := pc.exitCache != nil && !pc.ipRiskOffline()
+	now := time.Now()
+	var risk string
+	if cached && pc.exitCache.Get(ip, cacheIPRisk, pc.exitCacheTTL(cacheIPRisk), now, &risk) {
+		job.Result.IPRisk = risk
+		return
+	}
+	risk, err := pc.ipRisk(job.Client.Client, ip, pc.asnDB)
+	if err != nil {
+		slog.Debug(fmt.Sprintf("查询IP风险失败: %v", err))
+		return
+	}
+	job.Result.IPRisk = risk
+	if cached {
+		pc.exitCache.Put(ip, cacheIPRisk, risk, now)
+	}
+}
+
+// lookupGeo 查询节点出口 IP 和位置，已有结果时跳过
+func (pc *ProxyChecker) lookupGeo(job *ProxyJob, db *maxminddb.Reader, ctx context.Context) {
+	res := &job.Result
+	if res.Country != "" {
+		return
+	}
+
+	// 位置标签受 CF 节点位置影响，传入 CF 信息时按 IP+位置 缓存
+	var exit string
+	now := time.Now()
+	if pc.exitCache != nil {
+		loc, _ := job.cfTrace()
+		exit = pc.exitOf(job, loc != "")
+		var g geoCache
+		if exit != "" && pc.exitCache.Get(exit, cacheGeo, pc.exitCacheTTL(cacheGeo), now, &g) {
+			res.Country, res.CountryCodeTag = g.Country, g.Tag
+			if res.IP == "" {
+				res.IP = g.IP
+			}
+			return
+		}
+	}
+
+	country, ip, countryCodeTag, _ := proxyutils.GetProxyCountry(job.Client.Client, db, ctx, job.CfLoc, job.CfIP)
+	res.Country = country
+	res.CountryCodeTag = countryCodeTag
+	if res.IP == "" {
+		res.IP = ip
+	}
+	if exit != "" && country != "" {
+		pc.exitCache.Put(exit, cacheGeo, geoCache{IP: ip, Country: country, Tag: countryCodeTag}, now)
+	}
+}
@@ -0,0 +1,172 @@
+// Package exitcache 按出口缓存媒体解锁和地理位置检测结果
+//
+// 不同节点经常共用同一个出口，缓存命中时无需再次请求检测网站，
+// 避免重复检测和触发限流。
+package exitcache
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/sinspired/subs-check-pro/save/method"
+	"github.com/sinspired/subs-check-pro/utils"
+)
+
+// FileName 缓存文件名，保存在 output/stats 目录
+const FileName = "exit-cache.json"
+
+// entry 单个出口单项检测的缓存结果
+type entry struct {
+	At   int64           `json:"t"` // Unix 时间戳(秒)
+	Data json.RawMessage `json:"d"`
+}
+
+// Counter 命中统计
+type Counter struct {
+	Hits   int
+	Misses int
+}
+
+// Store 出口检测结果缓存，并发安全
+//
+// 键为出口标识，值为 检测项名称 -> 结果。
+type Store struct {
+	mu      sync.Mutex
+	path    string
+	entries map[string]map[string]entry
+	stats   map[string]*Counter
+}
+
+// New 创建空缓存
+func New(path string) *Store {
+	return &Store{
+		path:    path,
+		entries: make(map[string]map[string]entry),
+		stats:   make(map[string]*Counter),
+	}
+}
+
+// Open 打开缓存文件，文件不存在时返回空缓存
+func Open(path string) (*Store, error) {
+	s := New(path)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("读取出口缓存失败: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.entries); err != nil {
+		return nil, fmt.Errorf("解析出口缓存失败: %w", err)
+	}
+	if s.entries == nil {
+		s.entries = make(map[string]map[string]entry)
+	}
+	return s, nil
+}
+
+// Get 读取未超过 ttl 的缓存结果并解码到 v，同时记录命中统计
+func (s *Store) Get(exit, name string, ttl time.Duration, now time.Time, v any) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := s.stats[name]
+	if c == nil {
+		c = &Counter{}
+		s.stats[name] = c
+	}
+
+	e, ok := s.entries[exit][name]
+	if !ok || now.Sub(time.Unix(e.At, 0)) > ttl || json.Unmarshal(e.Data, v) != nil {
+		c.Misses++
+		return false
+	}
+	c.Hits++
+	return true
+}
+
+// Put 写入缓存结果
+func (s *Store) Put(exit, name string, v any, now time.Time) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m := s.entries[exit]
+	if m == nil {
+		m = make(map[string]entry)
+		s.entries[exit] = m
+	}
+	m[name] = entry{At: now.Unix(), Data: data}
+}
+
+// Stats 返回各检测项的命中统计，按名称排序
+func (s *Store) Stats() ([]string, map[string]Counter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]Counter, len(s.stats))
+	for k, c := range s.stats {
+		out[k] = *c
+	}
+	return slices.Sorted(maps.Keys(out)), out
+}
+
+// Len 返回缓存的出口数量
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+// Prune 删除超过 ttl 的结果，返回删除数量
+func (s *Store) Prune(now time.Time, ttl func(name string) time.Duration) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for exit, m := range s.entries {
+		for name, e := range m {
+			if now.Sub(time.Unix(e.At, 0)) > ttl(name) {
+				delete(m, name)
+				n++
+			}
+		}
+		if len(m) == 0 {
+			delete(s.entries, exit)
+		}
+	}
+	return n
+}
+
+// Save 原子写入缓存文件
+func (s *Store) Save() error {
+	s.mu.Lock()
+	data, err := json.Marshal(s.entries)
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("序列化出口缓存失败: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("创建目录失败: %w", err)
+	}
+	if err := utils.WriteFileAtomic(s.path, data, 0o644); err != nil {
+		return fmt.Errorf("保存出口缓存失败: %w", err)
+	}
+	return nil
+}
+
+// DefaultPath 返回缓存文件的默认路径
+func DefaultPath() (string, error) {
+	saver, err := method.NewStatsSaver()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(saver.StatsPath, FileName), nil
+}
@@ -0,0 +1,66 @@
+package exitcache
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type result struct {
+	OK     bool
+	Region string
+}
+
+func TestStoreGetPutAndReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	s := New(path)
+	now := time.Now()
+
+	var got result
+	if s.Get("1.1.1.1", "netflix", time.Hour, now, &got) {
+		t.Fatal("空缓存不应命中")
+	}
+	s.Put("1.1.1.1", "netflix", result{OK: true, Region: "US"}, now)
+	s.Put("1.1.1.1@HKG", "openai", result{OK: true}, now.Add(-2*time.Hour))
+
+	if !s.Get("1.1.1.1", "netflix", time.Hour, now, &got) || !got.OK || got.Region != "US" {
+		t.Errorf("Get() = %+v, want cached result", got)
+	}
+	if s.Get("1.1.1.1@HKG", "openai", time.Hour, now, &got) {
+		t.Error("过期结果不应命中")
+	}
+
+	names, stats := s.Stats()
+	if len(names) != 2 || stats["netflix"] != (Counter{Hits: 1, Misses: 1}) || stats["openai"] != (Counter{Misses: 1}) {
+		t.Errorf("Stats() = %v %+v", names, stats)
+	}
+
+	if err := s.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	loaded, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if loaded.Len() != 2 || !loaded.Get("1.1.1.1", "netflix", time.Hour, now, &got) {
+		t.Errorf("重新加载后缓存丢失: len=%d", loaded.Len())
+	}
+
+	// 按检测项有效期清理
+	n := loaded.Prune(now, func(name string) time.Duration {
+		if name == "openai" {
+			return time.Hour
+		}
+		return 24 * time.Hour
+	})
+	if n != 1 || loaded.Len() != 1 {
+		t.Errorf("Prune() = %d, len = %d", n, loaded.Len())
+	}
+}
+
+func TestOpenMissing(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), FileName))
+	if err != nil || s.Len() != 0 {
+		t.Fatalf("Open() = %v, %v", s, err)
+	}
+}
@@ -0,0 +1,337 @@
+// Package health 持久化记录节点历次检测的健康状况
+package health
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	proxyutils "github.com/sinspired/subs-check-pro/proxy"
+	"github.com/sinspired/subs-check-pro/save/method"
+	"github.com/sinspired/subs-check-pro/utils"
+)
+
+// FileName 健康数据库文件名，保存在 output/stats 目录
+const FileName = "node-health.json"
+
+// RecentDays 每个节点保留最近检测结果的天数
+//
+// 覆盖分析报告与 /api/node-health 使用的 7 天窗口，并留出余量，
+// 按时间而不是数量裁剪，避免检测频繁时窗口内的记录被截断。
+const RecentDays = 14
+
+// Outcome 单次检测结果
+type Outcome struct {
+	At int64 `json:"t"`  // Unix 时间戳(秒)
+	OK bool  `json:"ok"` // 是否通过
+}
+
+// Record 单个节点的健康记录
+type Record struct {
+	Key         string    `json:"key"`
+	Name        string    `json:"name"`
+	Type        string    `json:"type"`
+	Server      string    `json:"server"`
+	SubURL      string    `json:"sub_url,omitempty"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+	LastPass    time.Time `json:"last_pass,omitzero"`
+	Checks      int       `json:"checks"`
+	Passes      int       `json:"passes"`
+	ConsecFails int       `json:"consecutive_failures"`
+	LastSpeed   int       `json:"last_speed"`   // KB/s
+	LastLatency int       `json:"last_latency"` // ms
+	LastIP      string    `json:"last_ip,omitempty"`
+	LastCountry string    `json:"last_country,omitempty"`
+	Recent      []Outcome `json:"recent,omitempty"`
+}
+
+// PassRatio 返回全部检测的通过率
+func (r *Record) PassRatio() float64 {
+	if r.Checks == 0 {
+		return 0
+	}
+	return float64(r.Passes) / float64(r.Checks)
+}
+
+// 评分参数
+const (
+	// UnknownScore 无历史记录节点的默认评分，介于稳定节点与失效节点之间
+	UnknownScore = 0.3
+
+	scoreRatioWeight   = 0.6
+	scoreRecencyWeight = 0.25
+	scoreSpeedWeight   = 0.15
+
+	scoreRecencyDays = 7.0    // 最近通过时间的衰减周期(天)
+	scoreSpeedRef    = 5120.0 // 速度满分参考值(KB/s)
+)
+
+// Score 估算节点本次检测通过的期望价值，范围 [0, 1]
+//
+// 由平滑后的通过率、最近通过时间和上次速度加权得到，连续失败会进一步降低评分。
+func (r *Record) Score(now time.Time) float64 {
+	ratio := float64(r.Passes+1) / float64(r.Checks+2)
+
+	recency := 0.0
+	if !r.LastPass.IsZero() {
+		days := now.Sub(r.LastPass).Hours() / 24
+		recency = math.Exp(-max(days, 0) / scoreRecencyDays)
+	}
+
+	speed := 0.0
+	if r.LastSpeed > 0 {
+		speed = min(float64(r.LastSpeed)/scoreSpeedRef, 1)
+	}
+
+	score := scoreRatioWeight*ratio + scoreRecencyWeight*recency + scoreSpeedWeight*speed
+	return score / (1 + 0.5*float64(r.ConsecFails))
+}
+
+// WindowStats 返回 since 之后的检测次数和通过次数
+func (r *Record) WindowStats(since time.Time) (checks, passes int) {
+	ts := since.Unix()
+	for _, o := range r.Recent {
+		if o.At < ts {
+			continue
+		}
+		checks++
+		if o.OK {
+			passes++
+		}
+	}
+	return checks, passes
+}
+
+// Observation 一次检测得到的节点信息
+type Observation struct {
+	Name    string
+	Type    string
+	Server  string
+	SubURL  string
+	Passed  bool
+	Speed   int
+	Latency int
+	IP      string
+	Country string
+}
+
+// ObservationFromProxy 从节点配置中提取基础信息
+func ObservationFromProxy(p map[string]any) Observation {
+	var o Observation
+	o.Name, _ = p["name"].(string)
+	o.Type, _ = p["type"].(string)
+	o.SubURL, _ = p["sub_url"].(string)
+	if v, ok := p["server"]; ok {
+		o.Server = fmt.Sprint(v)
+	}
+	return o
+}
+
+// Key 根据节点指纹生成记录键
+// 指纹包含凭证信息，落盘前做哈希处理
+func Key(p map[string]any) string {
+	sum := sha1.Sum([]byte(proxyutils.GenerateProxyKey(p)))
+	return hex.EncodeToString(sum[:])
+}
+
+// Store 节点健康数据库
+type Store struct {
+	mu      sync.RWMutex
+	path    string
+	records map[string]*Record
+}
+
+// New 创建空的健康数据库
+func New(path string) *Store {
+	return &Store{path: path, records: make(map[string]*Record)}
+}
+
+// Open 打开健康数据库，文件不存在时返回空库
+func Open(path string) (*Store, error) {
+	s := New(path)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("读取健康数据库失败: %w", err)
+	}
+
+	var list []*Record
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("解析健康数据库失败: %w", err)
+	}
+	for _, r := range list {
+		if r != nil && r.Key != "" {
+			s.records[r.Key] = r
+		}
+	}
+	return s, nil
+}
+
+// Len 返回记录数量
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.records)
+}
+
+// Get 返回指定节点记录的副本
+func (s *Store) Get(key string) (Record, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	r, ok := s.records[key]
+	if !ok {
+		return Record{}, false
+	}
+	return *r, true
+}
+
+// Observe 记录一次检测结果，并发安全
+func (s *Store) Observe(key string, o Observation, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.records[key]
+	if !ok {
+		r = &Record{Key: key, FirstSeen: now}
+		s.records[key] = r
+	}
+
+	if o.Name != "" {
+		r.Name = o.Name
+	}
+	if o.Type != "" {
+		r.Type = o.Type
+	}
+	if o.Server != "" {
+		r.Server = o.Server
+	}
+	if o.SubURL != "" {
+		r.SubURL = o.SubURL
+	}
+
+	r.LastSeen = now
+	r.Checks++
+	if o.Passed {
+		r.Passes++
+		r.ConsecFails = 0
+		r.LastPass = now
+		if o.Speed > 0 {
+			r.LastSpeed = o.Speed
+		}
+		if o.Latency > 0 {
+			r.LastLatency = o.Latency
+		}
+		if o.IP != "" {
+			r.LastIP = o.IP
+		}
+		if o.Country != "" {
+			r.LastCountry = o.Country
+		}
+	} else {
+		r.ConsecFails++
+	}
+
+	r.Recent = append(r.Recent, Outcome{At: now.Unix(), OK: o.Passed})
+	cutoff := now.AddDate(0, 0, -RecentDays).Unix()
+	if i := slices.IndexFunc(r.Recent, func(o Outcome) bool { return o.At >= cutoff }); i > 0 {
+		r.Recent = slices.Clone(r.Recent[i:])
+	}
+}
+
+// Prune 删除 before 之前未再出现的节点，返回删除数量
+func (s *Store) Prune(before time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for k, r := range s.records {
+		if r.LastSeen.Before(before) {
+			delete(s.records, k)
+			n++
+		}
+	}
+	return n
+}
+
+// Reliable 返回 since 之后检测次数不少于 minChecks 的节点，按窗口通过率降序排列
+// limit <= 0 表示不限制数量
+func (s *Store) Reliable(since time.Time, minChecks int, limit int) []Record {
+	type scored struct {
+		r      Record
+		checks int
+		ratio  float64
+	}
+
+	s.mu.RLock()
+	list := make([]scored, 0)
+	for _, r := range s.records {
+		checks, passes := r.WindowStats(since)
+		if checks == 0 || checks < minChecks || passes == 0 {
+			continue
+		}
+		list = append(list, scored{r: *r, checks: checks, ratio: float64(passes) / float64(checks)})
+	}
+	s.mu.RUnlock()
+
+	slices.SortFunc(list, func(a, b scored) int {
+		if a.ratio != b.ratio {
+			if a.ratio > b.ratio {
+				return -1
+			}
+			return 1
+		}
+		if a.checks != b.checks {
+			return b.checks - a.checks
+		}
+		return b.r.LastSpeed - a.r.LastSpeed
+	})
+
+	if limit > 0 && len(list) > limit {
+		list = list[:limit]
+	}
+	out := make([]Record, len(list))
+	for i, item := range list {
+		out[i] = item.r
+	}
+	return out
+}
+
+// Save 原子写入数据库文件
+func (s *Store) Save() error {
+	s.mu.RLock()
+	list := make([]*Record, 0, len(s.records))
+	for _, r := range s.records {
+		list = append(list, r)
+	}
+	data, err := json.Marshal(list)
+	s.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("序列化健康数据库失败: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("创建目录失败: %w", err)
+	}
+	if err := utils.WriteFileAtomic(s.path, data, 0o644); err != nil {
+		return fmt.Errorf("保存健康数据库失败: %w", err)
+	}
+	return nil
+}
+
+// DefaultPath 返回健康数据库的默认路径
+func DefaultPath() (string, error) {
+	saver, err := method.NewStatsSaver()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(saver.StatsPath, FileName), nil
+}
@@ -0,0 +1,91 @@
+package health
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStoreObserveAndReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	db := New(path)
+
+	now := time.Now()
+	node := map[string]any{"name": "HK-01", "type": "ss", "server": "1.2.3.4", "port": 443, "password": "pw"}
+	key := Key(node)
+
+	base := ObservationFromProxy(node)
+	for i, ok := range []bool{true, false, true, true} {
+		o := base
+		o.Passed = ok
+		o.Speed = 1000 + i
+		db.Observe(key, o, now.Add(time.Duration(i)*time.Hour))
+	}
+
+	r, ok := db.Get(key)
+	if !ok {
+		t.Fatal("记录不存在")
+	}
+	if r.Checks != 4 || r.Passes != 3 || r.ConsecFails != 0 || r.LastSpeed != 1003 {
+		t.Errorf("记录统计错误: %+v", r)
+	}
+
+	if err := db.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	loaded, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if loaded.Len() != 1 {
+		t.Fatalf("重新加载记录数 = %d, want 1", loaded.Len())
+	}
+
+	list := loaded.Reliable(now.Add(-time.Hour), 3, 10)
+	if len(list) != 1 || list[0].Key != key {
+		t.Errorf("Reliable() = %v", list)
+	}
+
+	if n := loaded.Prune(now.Add(24 * time.Hour)); n != 1 || loaded.Len() != 0 {
+		t.Errorf("Prune() = %d, Len = %d", n, loaded.Len())
+	}
+}
+
+func TestRecordScore(t *testing.T) {
+	now := time.Now()
+	good := Record{Checks: 10, Passes: 9, LastPass: now.Add(-time.Hour), LastSpeed: 4096}
+	stale := Record{Checks: 10, Passes: 9, LastPass: now.AddDate(0, 0, -20), LastSpeed: 4096}
+	dead := Record{Checks: 10, Passes: 1, LastPass: now.AddDate(0, 0, -20), ConsecFails: 6}
+
+	if good.Score(now) <= stale.Score(now) {
+		t.Errorf("最近通过的节点评分应更高: %f <= %f", good.Score(now), stale.Score(now))
+	}
+	if good.Score(now) <= UnknownScore {
+		t.Errorf("稳定节点评分应高于未知节点: %f", good.Score(now))
+	}
+	if dead.Score(now) >= UnknownScore {
+		t.Errorf("失效节点评分应低于未知节点: %f", dead.Score(now))
+	}
+	if s := good.Score(now); s < 0 || s > 1 {
+		t.Errorf("评分超出范围: %f", s)
+	}
+}
+
+func TestObserveKeepsRecentByAge(t *testing.T) {
+	db := New(filepath.Join(t.TempDir(), FileName))
+	start := time.Unix(time.Now().Unix(), 0).AddDate(0, 0, -20)
+
+	// 每小时检测一次，持续 20 天
+	for i := range 20 * 24 {
+		db.Observe("k", Observation{Passed: true}, start.Add(time.Duration(i)*time.Hour))
+	}
+	r, _ := db.Get("k")
+	last := start.Add(time.Duration(20*24-1) * time.Hour)
+
+	if oldest := time.Unix(r.Recent[0].At, 0); last.Sub(oldest) > RecentDays*24*time.Hour {
+		t.Errorf("最早记录 %v 超出保留窗口", oldest)
+	}
+	if checks, _ := r.WindowStats(last.AddDate(0, 0, -7)); checks != 7*24+1 {
+		t.Errorf("7 天窗口内检测次数 = %d, want %d", checks, 7*24+1)
+	}
+}
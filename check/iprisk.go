@@ -0,0 +1,145 @@
+package check
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang/v2"
+	"github.com/sinspired/subs-check-pro/check/iprisk"
+	"github.com/sinspired/subs-check-pro/check/platform"
+	"github.com/sinspired/subs-check-pro/config"
+	proxyutils "github.com/sinspired/subs-check-pro/proxy"
+	"github.com/sinspired/subs-check-pro/utils"
+)
+
+// IP 风险来源
+const (
+	IPRiskOffline     = "offline"     // 本地数据集评估
+	IPRiskScamalytics = "scamalytics" // 在线查询 scamalytics
+)
+
+// defaultIPRiskUpdateInterval ip-risk-update-interval 未设置时的数据集更新间隔
+const defaultIPRiskUpdateInterval = 24 * time.Hour
+
+// riskEngine 当前加载的离线风险引擎，检测开始和定时更新后替换
+var riskEngine atomic.Pointer[iprisk.Engine]
+
+// ipRiskON 是否启用 IP 风险检测
+func (s *Session) ipRiskON() bool {
+	return s.mediaON && slices.Contains(s.platforms, "iprisk")
+}
+
+// ipRiskOffline 是否使用本地数据集评估 IP 风险
+func (s *Session) ipRiskOffline() bool {
+	return s.opts.IPRiskSource != IPRiskScamalytics
+}
+
+// ipRiskInterval 返回数据集更新间隔
+func ipRiskInterval(hours int) time.Duration {
+	if hours > 0 {
+		return time.Duration(hours) * time.Hour
+	}
+	return defaultIPRiskUpdateInterval
+}
+
+// riskDatasets 合并内置和自定义数据集，同名时使用自定义数据集
+func riskDatasets(lists []config.IPRiskList) []iprisk.Dataset {
+	out := slices.Clone(iprisk.DefaultDatasets)
+	for _, l := range lists {
+		if l.Name == "" || l.URL == "" {
+			slog.Warn(fmt.Sprintf("忽略无效的 IP 风险数据集: %+v", l))
+			continue
+		}
+		ds := iprisk.Dataset{Name: l.Name, Category: l.Category, URL: l.URL}
+		if i := slices.IndexFunc(out, func(d iprisk.Dataset) bool { return d.Name == l.Name }); i >= 0 {
+			out[i] = ds
+		} else {
+			out = append(out, ds)
+		}
+	}
+	return out
+}
+
+// updateRiskDatasets 更新需要更新的数据集并重新加载引擎
+func updateRiskDatasets(lists []config.IPRiskList, maxAge time.Duration) error {
+	dir, err := iprisk.DefaultDir()
+	if err != nil {
+		return fmt.Errorf("获取 IP 风险数据集目录失败: %w", err)
+	}
+	datasets := riskDatasets(lists)
+
+	var updateErr error
+	if stale := iprisk.Stale(dir, datasets, maxAge); len(stale) > 0 {
+		// GitHub 地址按配置使用代理
+		if slices.ContainsFunc(stale, func(d iprisk.Dataset) bool { return strings.Contains(d.URL, "githubusercontent.com") }) && utils.GetGhProxy() {
+			for i := range stale {
+				if strings.Contains(stale[i].URL, "githubusercontent.com") {
+					stale[i].URL = config.GlobalConfig.GithubProxy + stale[i].URL
+				}
+			}
+		}
+		n, err := iprisk.Update(dir, stale)
+		if n > 0 {
+			slog.Info(fmt.Sprintf("IP 风险数据集已更新: %d/%d", n, len(stale)))
+		}
+		updateErr = err
+	}
+
+	engine, err := iprisk.Open(dir, datasets)
+	if err != nil {
+		return err
+	}
+	riskEngine.Store(engine)
+	return updateErr
+}
+
+// loadIPRisk 检测开始时加载离线风险数据集，缺失或过期的数据集先下载
+func (s *Session) loadIPRisk() {
+	if !s.ipRiskON() || !s.ipRiskOffline() {
+		return
+	}
+	if err := updateRiskDatasets(s.opts.IPRiskLists, ipRiskInterval(s.opts.IPRiskUpdateInterval)); err != nil {
+		slog.Warn(fmt.Sprintf("加载 IP 风险数据集: %v", err))
+	}
+	if e := riskEngine.Load(); e == nil || e.Len() == 0 {
+		slog.Warn("没有可用的 IP 风险数据集，仅按 ASN 分类评估")
+	}
+}
+
+// UpdateIPRiskData 按配置强制更新离线 IP 风险数据集，用于定时任务
+func UpdateIPRiskData() error {
+	cfg := config.GlobalConfig
+	if !cfg.MediaCheck || !slices.Contains(cfg.Platforms, "iprisk") || cfg.IPRiskSource == IPRiskScamalytics {
+		return nil
+	}
+	return updateRiskDatasets(cfg.IPRiskLists, 0)
+}
+
+// ipRisk 查询出口 IP 风险，离线模式使用本地数据集和 ASN 分类，asnDB 可为 nil
+func (s *Session) ipRisk(client *http.Client, ip string, asnDB *maxminddb.Reader) (string, error) {
+	if !s.ipRiskOffline() {
+		return platform.CheckIPRisk(client, ip)
+	}
+
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return "", fmt.Errorf("无效的 IP: %s", ip)
+	}
+	hosting := false
+	if asnDB != nil {
+		if info, _, err := proxyutils.LookupISPInfo(ip, asnDB, nil); err == nil {
+			hosting = info.Type == proxyutils.ISPHosting
+		}
+	}
+	engine := riskEngine.Load()
+	if engine == nil {
+		engine = &iprisk.Engine{}
+	}
+	return engine.Score(addr, hosting).String(), nil
+}
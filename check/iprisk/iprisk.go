@@ -0,0 +1,269 @@
+// Package iprisk 使用本地缓存的数据集离线评估出口 IP 风险
+//
+// 数据集包括机房 IP 段、VPN 出口、Tor 出口和公开的滥用黑名单，
+// 每个数据集为一个文本文件，每行一个 IP 或 CIDR，# 和 ; 之后为注释。
+package iprisk
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sinspired/subs-check-pro/save/method"
+	"github.com/sinspired/subs-check-pro/utils"
+)
+
+// DirName 数据集保存目录，位于 output 目录下
+const DirName = "IPRiskData"
+
+// 风险分类，按风险从高到低
+const (
+	CategoryAbuse   = "abuse"   // 滥用黑名单
+	CategoryTor     = "tor"     // Tor 出口
+	CategoryVPN     = "vpn"     // 公共 VPN/代理出口
+	CategoryHosting = "hosting" // 机房
+	CategoryClean   = "clean"   // 未命中
+)
+
+// weights 各分类的风险分，多个分类命中时累加，最高 100
+var weights = map[string]int{
+	CategoryAbuse:   60,
+	CategoryTor:     50,
+	CategoryVPN:     35,
+	CategoryHosting: 30,
+}
+
+// Dataset 数据集定义
+type Dataset struct {
+	Name     string // 文件名(不含扩展名)
+	Category string
+	URL      string // http(s) 地址或本地文件路径
+}
+
+// DefaultDatasets 内置数据集
+var DefaultDatasets = []Dataset{
+	{Name: "x4b-datacenter", Category: CategoryHosting, URL: "https://raw.githubusercontent.com/X4BNet/lists_vpn/main/output/datacenter/ipv4.txt"},
+	{Name: "x4b-vpn", Category: CategoryVPN, URL: "https://raw.githubusercontent.com/X4BNet/lists_vpn/main/output/vpn/ipv4.txt"},
+	{Name: "tor-exit", Category: CategoryTor, URL: "https://check.torproject.org/torbulkexitlist"},
+	{Name: "firehol-level1", Category: CategoryAbuse, URL: "https://raw.githubusercontent.com/firehol/blocklist-ipsets/master/firehol_level1.netset"},
+	{Name: "spamhaus-drop", Category: CategoryAbuse, URL: "https://www.spamhaus.org/drop/drop.txt"},
+}
+
+// Risk 风险评估结果
+type Risk struct {
+	Score    int      // 0-100
+	Category string   // 命中的最高风险分类
+	Hits     []string // 命中的数据集名称
+}
+
+// String 返回节点标签格式，如 0%、65%-tor
+func (r Risk) String() string {
+	if r.Category == CategoryClean {
+		return fmt.Sprintf("%d%%", r.Score)
+	}
+	return fmt.Sprintf("%d%%-%s", r.Score, r.Category)
+}
+
+// ipRange 闭区间 [from, to]
+type ipRange struct {
+	from, to netip.Addr
+}
+
+// list 单个数据集，区间按起始地址排序且互不重叠
+type list struct {
+	Dataset
+	ranges []ipRange
+}
+
+// contains 二分查找 addr 是否在数据集中
+func (l *list) contains(addr netip.Addr) bool {
+	i, _ := slices.BinarySearchFunc(l.ranges, addr, func(r ipRange, a netip.Addr) int {
+		return r.from.Compare(a)
+	})
+	// i 为第一个起始地址 >= addr 的区间
+	if i < len(l.ranges) && l.ranges[i].from == addr {
+		return true
+	}
+	return i > 0 && l.ranges[i-1].to.Compare(addr) >= 0
+}
+
+// Engine 离线风险评估引擎，加载后只读，可并发使用
+type Engine struct {
+	lists []*list
+}
+
+// Open 从 dir 加载数据集，不存在的数据集跳过
+func Open(dir string, datasets []Dataset) (*Engine, error) {
+	e := &Engine{}
+	for _, ds := range datasets {
+		data, err := os.ReadFile(datasetPath(dir, ds.Name))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, fmt.Errorf("读取数据集 %s 失败: %w", ds.Name, err)
+		}
+		e.lists = append(e.lists, &list{Dataset: ds, ranges: parseList(data)})
+	}
+	return e, nil
+}
+
+// Len 返回已加载的数据集数量
+func (e *Engine) Len() int {
+	return len(e.lists)
+}
+
+// Score 评估 IP 风险，hostingASN 为 ASN 分类结果是否为机房
+func (e *Engine) Score(addr netip.Addr, hostingASN bool) Risk {
+	addr = addr.Unmap()
+	r := Risk{Category: CategoryClean}
+	hit := make(map[string]bool)
+	for _, l := range e.lists {
+		if l.contains(addr) {
+			hit[l.Category] = true
+			r.Hits = append(r.Hits, l.Name)
+		}
+	}
+	if hostingASN && !hit[CategoryHosting] {
+		hit[CategoryHosting] = true
+		r.Hits = append(r.Hits, "asn")
+	}
+
+	for cat := range hit {
+		w := weights[cat]
+		r.Score += w
+		if r.Category == CategoryClean || w > weights[r.Category] {
+			r.Category = cat
+		}
+	}
+	r.Score = min(r.Score, 100)
+	return r
+}
+
+// parseList 解析数据集文本，返回排序合并后的区间
+func parseList(data []byte) []ipRange {
+	var ranges []ipRange
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line := sc.Text()
+		if i := strings.IndexAny(line, "#;"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if p, err := netip.ParsePrefix(fields[0]); err == nil {
+			p = p.Masked()
+			ranges = append(ranges, ipRange{from: p.Addr().Unmap(), to: lastAddr(p).Unmap()})
+		} else if a, err := netip.ParseAddr(fields[0]); err == nil {
+			a = a.Unmap()
+			ranges = append(ranges, ipRange{from: a, to: a})
+		}
+	}
+
+	slices.SortFunc(ranges, func(a, b ipRange) int { return a.from.Compare(b.from) })
+	merged := ranges[:0]
+	for _, r := range ranges {
+		if n := len(merged); n > 0 && merged[n-1].to.BitLen() == r.from.BitLen() &&
+			(merged[n-1].to.Compare(r.from) >= 0 || merged[n-1].to.Next() == r.from) {
+			if r.to.Compare(merged[n-1].to) > 0 {
+				merged[n-1].to = r.to
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// lastAddr 返回网段的最后一个地址
+func lastAddr(p netip.Prefix) netip.Addr {
+	b := p.Addr().AsSlice()
+	for i := p.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 0x80 >> (i % 8)
+	}
+	a, _ := netip.AddrFromSlice(b)
+	return a
+}
+
+// Stale 返回不存在或超过 maxAge 的数据集，maxAge 为 0 时返回全部
+func Stale(dir string, datasets []Dataset, maxAge time.Duration) []Dataset {
+	var out []Dataset
+	for _, ds := range datasets {
+		fi, err := os.Stat(datasetPath(dir, ds.Name))
+		if err != nil || time.Since(fi.ModTime()) >= maxAge {
+			out = append(out, ds)
+		}
+	}
+	return out
+}
+
+// Update 下载数据集，单个数据集失败时保留原文件，返回成功数量和最后一个错误
+func Update(dir string, datasets []Dataset) (updated int, err error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return 0, fmt.Errorf("创建数据集目录失败: %w", err)
+	}
+	client := &http.Client{Timeout: 60 * time.Second}
+	for _, ds := range datasets {
+		if e := fetch(client, ds.URL, datasetPath(dir, ds.Name)); e != nil {
+			err = fmt.Errorf("更新数据集 %s 失败: %w", ds.Name, e)
+			continue
+		}
+		updated++
+	}
+	return updated, err
+}
+
+// fetch 下载或复制数据集，内容无法解析出任何地址时视为失败
+func fetch(client *http.Client, src, path string) error {
+	var data []byte
+	if strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") {
+		resp, err := client.Get(src)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("HTTP 状态码 %d", resp.StatusCode)
+		}
+		if data, err = io.ReadAll(resp.Body); err != nil {
+			return err
+		}
+	} else {
+		var err error
+		if data, err = os.ReadFile(src); err != nil {
+			return err
+		}
+	}
+	if len(parseList(data)) == 0 {
+		return fmt.Errorf("数据集为空或格式错误")
+	}
+
+	return utils.WriteFileAtomic(path, data, 0o644)
+}
+
+// datasetPath 返回数据集文件路径
+func datasetPath(dir, name string) string {
+	return filepath.Join(dir, name+".txt")
+}
+
+// DefaultDir 返回数据集默认保存目录
+func DefaultDir() (string, error) {
+	saver, err := method.NewLocalSaver()
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(saver.OutputPath) {
+		saver.OutputPath = filepath.Join(saver.BasePath, saver.OutputPath)
+	}
+	return filepath.Join(saver.OutputPath, DirName), nil
+}
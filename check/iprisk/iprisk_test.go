@@ -0,0 +1,107 @@
+package iprisk
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseListAndContains(t *testing.T) {
+	data := []byte(`# comment
+10.0.0.0/24
+10.0.1.0/24 ; SBL123
+10.0.0.128/25
+192.0.2.1
+2001:db8::/32
+bad line
+`)
+	l := &list{ranges: parseList(data)}
+	if len(l.ranges) != 3 {
+		t.Fatalf("ranges = %+v, want 3 merged ranges", l.ranges)
+	}
+
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"10.0.0.0", true},
+		{"10.0.1.255", true},
+		{"10.0.2.0", false},
+		{"192.0.2.1", true},
+		{"192.0.2.2", false},
+		{"2001:db8:ffff::1", true},
+		{"2001:db9::1", false},
+		{"9.255.255.255", false},
+	}
+	for _, tt := range tests {
+		if got := l.contains(netip.MustParseAddr(tt.ip)); got != tt.want {
+			t.Errorf("contains(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestEngineScore(t *testing.T) {
+	e := &Engine{lists: []*list{
+		{Dataset: Dataset{Name: "dc", Category: CategoryHosting}, ranges: parseList([]byte("1.1.1.0/24"))},
+		{Dataset: Dataset{Name: "tor", Category: CategoryTor}, ranges: parseList([]byte("1.1.1.1"))},
+	}}
+
+	tests := []struct {
+		ip      string
+		hosting bool
+		want    string
+	}{
+		{"1.1.1.1", false, "80%-tor"},
+		{"1.1.1.2", false, "30%-hosting"},
+		{"::ffff:1.1.1.2", true, "30%-hosting"},
+		{"8.8.8.8", true, "30%-hosting"},
+		{"8.8.8.8", false, "0%"},
+	}
+	for _, tt := range tests {
+		if got := e.Score(netip.MustParseAddr(tt.ip), tt.hosting).String(); got != tt.want {
+			t.Errorf("Score(%s, %v) = %s, want %s", tt.ip, tt.hosting, got, tt.want)
+		}
+	}
+}
+
+func TestUpdateAndOpen(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/empty" {
+			return
+		}
+		_, _ = w.Write([]byte("203.0.113.0/24\n"))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	datasets := []Dataset{
+		{Name: "ok", Category: CategoryAbuse, URL: srv.URL + "/ok"},
+		{Name: "empty", Category: CategoryTor, URL: srv.URL + "/empty"},
+	}
+	if len(Stale(dir, datasets, time.Hour)) != 2 {
+		t.Fatal("不存在的数据集应需要更新")
+	}
+	n, err := Update(dir, datasets)
+	if n != 1 || err == nil {
+		t.Fatalf("Update() = %d, %v", n, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "empty.txt")); !os.IsNotExist(err) {
+		t.Error("空数据集不应保存")
+	}
+	// 未过期的数据集不再更新
+	if stale := Stale(dir, datasets, time.Hour); len(stale) != 1 || stale[0].Name != "empty" {
+		t.Errorf("Stale() = %+v", stale)
+	}
+
+	e, err := Open(dir, datasets)
+	if err != nil || e.Len() != 1 {
+		t.Fatalf("Open() = %v, %v", e, err)
+	}
+	if r := e.Score(netip.MustParseAddr("203.0.113.9"), false); r.Category != CategoryAbuse || r.Score != 60 {
+		t.Errorf("Score() = %+v", r)
+	}
+}
@@ -0,0 +1,65 @@
+package check
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/oschwald/maxminddb-golang/v2"
+	"github.com/sinspired/subs-check-pro/assets"
+	proxyutils "github.com/sinspired/subs-check-pro/proxy"
+)
+
+// asnON 是否需要 ASN 数据库
+func (s *Session) asnON() bool {
+	return s.opts.ISPCheck || s.exitDedupON() && s.opts.ExitDedupBy == ExitGroupASN ||
+		s.ipRiskON() && s.ipRiskOffline()
+}
+
+// openASNDB 加载 ASN 分类规则并打开 ASN 数据库，失败时 isp 检测按配置回退在线查询
+func (pc *ProxyChecker) openASNDB() {
+	if !pc.asnON() {
+		return
+	}
+	if pc.opts.ISPCheck || pc.ipRiskON() {
+		if err := proxyutils.LoadASNRules(pc.opts.ASNRulesPath); err != nil {
+			slog.Warn(fmt.Sprintf("加载 ASN 分类规则失败，使用内置规则: %v", err))
+			_ = proxyutils.LoadASNRules("")
+		}
+	}
+	db, err := assets.OpenASNDB(pc.opts.ASNDBPath)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("打开 ASN 数据库失败: %v", err))
+		return
+	}
+	pc.asnDB = db
+}
+
+// closeASNDB 关闭 ASN 数据库
+func (pc *ProxyChecker) closeASNDB() {
+	if pc.asnDB == nil {
+		return
+	}
+	if err := pc.asnDB.Close(); err != nil {
+		slog.Debug(fmt.Sprintf("关闭 ASN 数据库失败: %v", err))
+	}
+	pc.asnDB = nil
+}
+
+// checkISP 识别出口 IP 的运营商类型，结果写入 Result.ASN 和 Result.ISP
+//
+// 优先使用 ASN 数据库离线分类，无法识别且开启 isp-online-fallback 时通过节点请求 ipapi.is。
+func (pc *ProxyChecker) checkISP(job *ProxyJob, geoDB *maxminddb.Reader) {
+	res := &job.Result
+	if res.IP != "" && pc.asnDB != nil {
+		info, asn, err := proxyutils.LookupISPInfo(res.IP, pc.asnDB, geoDB)
+		if err != nil {
+			slog.Debug(fmt.Sprintf("离线查询 isp 失败: %v", err))
+		} else {
+			res.ASN = asn
+			res.ISP = proxyutils.FormatISPInfo(info)
+		}
+	}
+	if res.ISP == "" && pc.opts.ISPOnlineFallback {
+		res.ISP = proxyutils.GetISPInfo(job.Client.Client)
+	}
+}
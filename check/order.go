@@ -0,0 +1,89 @@
+package check
+
+import (
+	"cmp"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"github.com/sinspired/subs-check-pro/check/health"
+	proxyutils "github.com/sinspired/subs-check-pro/proxy"
+)
+
+// prevPassScore 上次/历次检测成功但无健康记录的节点评分
+const prevPassScore = 0.5
+
+// shuffleConfig 根据节点数量生成乱序参数
+func (s *Session) shuffleConfig(total int) proxyutils.ShuffleConfig {
+	// 假设有 15 个相似的ip
+	calcMinSpacing := max(s.opts.Concurrent*5, total/15)
+
+	return proxyutils.ShuffleConfig{
+		Threshold:  s.opts.Threshold,      // CIDR/24 相同, 避免在一组(0.5: CIDR/16)
+		Passes:     3,                     // 改善轮数（1~3）
+		MinSpacing: calcMinSpacing,        // CIDR/24 相同, 设置最小间隔
+		ScanLimit:  s.opts.Concurrent * 2, // 冲突向前扫描的最大距离
+	}
+}
+
+// orderProxies 确定检测顺序
+//
+// 有健康记录时按历史评分降序排列，评分相同的节点随机排列，
+// 再在保留顺序的前提下做局部交换以满足 CIDR 间距；
+// 否则之前成功的节点在前，其余节点乱序。
+func (s *Session) orderProxies(proxies []map[string]any, headSize int, db *health.Store) {
+	cfg := s.shuffleConfig(len(proxies))
+	cidr := proxyutils.ThresholdToCIDR(cfg.Threshold)
+
+	if db == nil || db.Len() == 0 {
+		if len(proxies) > headSize {
+			// 随机乱序并根据 server 字段打乱节点顺序, 减少测速直接测死的概率
+			proxyutils.SmartShuffleByServer(proxies[headSize:], cfg)
+			slog.Info(fmt.Sprintf("节点乱序, 相同 CIDR%s 最小间距: %d", cidr, cfg.MinSpacing))
+		}
+		return
+	}
+
+	known := sortByScore(proxies, headSize, db, time.Now())
+
+	cfg.KeepOrder = true
+	proxyutils.SmartShuffleByServer(proxies, cfg)
+	slog.Info(fmt.Sprintf("节点按历史评分排序, 有记录: %d, 相同 CIDR%s 最小间距: %d", known, cidr, cfg.MinSpacing))
+}
+
+// sortByScore 按节点评分降序排列 proxies，返回有健康记录的节点数量
+// 前 headSize 个节点为之前成功的节点，无记录时使用 prevPassScore
+func sortByScore(proxies []map[string]any, headSize int, db *health.Store, now time.Time) int {
+	type scored struct {
+		proxy map[string]any
+		score float64
+	}
+
+	known := 0
+	list := make([]scored, len(proxies))
+	for i, p := range proxies {
+		score := health.UnknownScore
+		if r, ok := db.Get(health.Key(p)); ok {
+			score = r.Score(now)
+			known++
+		} else if i < headSize {
+			score = prevPassScore
+		}
+		list[i] = scored{proxy: p, score: score}
+	}
+
+	// 先打乱再稳定排序，评分相同的节点保持随机
+	rand.Shuffle(len(list), func(i, j int) {
+		list[i], list[j] = list[j], list[i]
+	})
+	slices.SortStableFunc(list, func(a, b scored) int {
+		return cmp.Compare(b.score, a.score)
+	})
+
+	for i := range list {
+		proxies[i] = list[i].proxy
+	}
+	return known
+}
@@ -0,0 +1,81 @@
+package platform
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// AliveTarget 测活目标
+type AliveTarget struct {
+	Name   string
+	URL    string
+	Method string
+	Status int    // 期望的状态码，0 为接受 2xx
+	Body   string // 响应内容必须包含的字符串，为空时不检查
+}
+
+// 内置测活目标，可在 alive-targets 中按名称引用
+var builtinAliveTargets = map[string]AliveTarget{
+	"gstatic":  {Name: "gstatic", URL: "https://gstatic.com/generate_204", Method: http.MethodHead, Status: 204},
+	"google":   {Name: "google", URL: "https://www.google.com/generate_204", Method: http.MethodHead, Status: 204},
+	"cf-trace": {Name: "cf-trace", URL: "https://www.cloudflare.com/cdn-cgi/trace", Method: http.MethodGet, Status: 200, Body: "ip="},
+}
+
+// DefaultAliveTarget 未配置 alive-targets 时使用的测活目标
+var DefaultAliveTarget = builtinAliveTargets["gstatic"]
+
+// ParseAliveTarget 解析测活目标：内置名称(gstatic, google, cf-trace)或 http(s) 地址
+func ParseAliveTarget(s string) (AliveTarget, error) {
+	s = strings.TrimSpace(s)
+	if t, ok := builtinAliveTargets[strings.ToLower(s)]; ok {
+		return t, nil
+	}
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return AliveTarget{}, fmt.Errorf("无效的测活目标: %q", s)
+	}
+	return AliveTarget{Name: strings.TrimSuffix(u.Host+u.Path, "/"), URL: s, Method: http.MethodGet}, nil
+}
+
+// CheckAlive 通过节点请求测活目标，失败时返回原始错误以便分类
+func CheckAlive(httpClient *http.Client, t AliveTarget) error {
+	if t.Method == http.MethodHead && t.Body == "" {
+		ok, err := checkGoogleEndpoint(httpClient, t.URL, t.Status)
+		if err == nil && !ok {
+			err = fmt.Errorf("unexpected response")
+		}
+		return err
+	}
+
+	req, err := http.NewRequest(t.Method, t.URL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
+	req.Header.Set("Connection", "close")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if t.Status != 0 && resp.StatusCode != t.Status ||
+		t.Status == 0 && (resp.StatusCode < 200 || resp.StatusCode >= 300) {
+		return &StatusError{Code: resp.StatusCode}
+	}
+	if t.Body == "" {
+		return nil
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(string(body), t.Body) {
+		return fmt.Errorf("response does not contain %q", t.Body)
+	}
+	return nil
+}
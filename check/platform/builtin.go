@@ -0,0 +1,73 @@
+package platform
+
+import (
+	"context"
+	"net/http"
+)
+
+// 内置平台检测器，包装各平台的 CheckXxx 函数
+func init() {
+	Register(openaiChecker{Meta{PlatformName: "openai", DisplayName: "GPT", TagName: "GPT", Kind: CategoryAI, CF: true}})
+	Register(xChecker{Meta{PlatformName: "x", DisplayName: "X", TagName: "X", Kind: CategoryMedia, CF: true, CFBlockHide: true}})
+	Register(geminiChecker{Meta{PlatformName: "gemini", DisplayName: "Gemini", TagName: "GM", Kind: CategoryAI}})
+	Register(youtubeChecker{Meta{PlatformName: "youtube", DisplayName: "YouTube", TagName: "YT", Kind: CategoryMedia}})
+	Register(netflixChecker{Meta{PlatformName: "netflix", DisplayName: "Netflix", TagName: "NF", Kind: CategoryMedia}})
+	Register(disneyChecker{Meta{PlatformName: "disney", DisplayName: "Disney+", TagName: "D+", Kind: CategoryMedia}})
+	Register(tiktokChecker{Meta{PlatformName: "tiktok", DisplayName: "TikTok", TagName: "TK", Kind: CategoryMedia}})
+}
+
+type openaiChecker struct{ Meta }
+
+// Check 全部通过标记为 GPT⁺，仅通过一项标记为 GPT，详见 CheckOpenAI
+func (openaiChecker) Check(_ context.Context, httpClient *http.Client) (PlatformResult, error) {
+	cookiesOK, clientOK := CheckOpenAI(httpClient)
+	switch {
+	case cookiesOK && clientOK:
+		return PlatformResult{OK: true, Tag: "GPT⁺", Label: "GPT+"}, nil
+	case cookiesOK || clientOK:
+		return PlatformResult{OK: true}, nil
+	}
+	return PlatformResult{}, nil
+}
+
+type xChecker struct{ Meta }
+
+// Check X 仅依赖 Cloudflare 可达，调用方已完成 CF 检测
+func (xChecker) Check(_ context.Context, _ *http.Client) (PlatformResult, error) {
+	return PlatformResult{OK: true}, nil
+}
+
+type geminiChecker struct{ Meta }
+
+func (geminiChecker) Check(_ context.Context, httpClient *http.Client) (PlatformResult, error) {
+	ok, err := CheckGemini(httpClient)
+	return PlatformResult{OK: ok}, err
+}
+
+type youtubeChecker struct{ Meta }
+
+func (youtubeChecker) Check(_ context.Context, httpClient *http.Client) (PlatformResult, error) {
+	region, err := CheckYoutube(httpClient)
+	return PlatformResult{OK: region != "", Region: region}, err
+}
+
+type netflixChecker struct{ Meta }
+
+func (netflixChecker) Check(_ context.Context, httpClient *http.Client) (PlatformResult, error) {
+	ok, err := CheckNetflix(httpClient)
+	return PlatformResult{OK: ok}, err
+}
+
+type disneyChecker struct{ Meta }
+
+func (disneyChecker) Check(_ context.Context, httpClient *http.Client) (PlatformResult, error) {
+	ok, err := CheckDisney(httpClient)
+	return PlatformResult{OK: ok}, err
+}
+
+type tiktokChecker struct{ Meta }
+
+func (tiktokChecker) Check(_ context.Context, httpClient *http.Client) (PlatformResult, error) {
+	region, err := CheckTikTok(httpClient)
+	return PlatformResult{OK: region != "", Region: region}, err
+}
@@ -12,11 +12,9 @@ func CheckGoogle(httpClient *http.Client) (bool, error) {
 	return false, nil
 }
 
+// CheckGstatic 检测 gstatic 连通性，失败时返回原始错误以便分类
 func CheckGstatic(httpClient *http.Client) (bool, error) {
-	if success, err := checkGoogleEndpoint(httpClient, "https://gstatic.com/generate_204", 204); err == nil && success {
-		return true, nil
-	}
-	return false, nil
+	return checkGoogleEndpoint(httpClient, "https://gstatic.com/generate_204", 204)
 }
 
 // checkGoogleEndpoint 使用 HEAD 方法检查 URL 的状态码。
@@ -38,5 +36,8 @@ func checkGoogleEndpoint(httpClient *http.Client, url string, statusCode int) (b
 	}
 	defer resp.Body.Close()
 
-	return resp.StatusCode == statusCode, nil
+	if resp.StatusCode != statusCode {
+		return false, &StatusError{Code: resp.StatusCode}
+	}
+	return true, nil
 }
@@ -0,0 +1,136 @@
+package platform
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// 完整性检测结论
+const (
+	IntegrityMITM       = "mitm"       // 证书链无法验证，TLS 被中间人劫持
+	IntegrityInjected   = "injected"   // 明文内容被篡改或替换
+	IntegrityRedirected = "redirected" // 请求被重定向到其他页面，如登录页
+)
+
+// integrityResource 内容固定的明文资源
+type integrityResource struct {
+	URL    string
+	Status int
+	SHA256 string
+}
+
+var integrityResources = []integrityResource{
+	{URL: "http://www.gstatic.com/generate_204", Status: 204,
+		SHA256: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+	{URL: "http://detectportal.firefox.com/success.txt", Status: 200,
+		SHA256: "81b2bd4ea98c8db66554fbc8d7637a1a69a130f331feb732b75caab4c4868fd5"},
+}
+
+// integrityHosts 校验证书链的知名站点
+var integrityHosts = []string{
+	"https://www.google.com",
+	"https://www.cloudflare.com",
+	"https://github.com",
+}
+
+// CheckIntegrity 检测节点是否劫持流量
+//
+// 通过节点请求内容固定的明文资源并比对哈希，再跳过系统校验与知名站点握手，
+// 自行验证证书链。返回检测结论，正常或无法判断时为空；error 为异常详情。
+// 请求失败视为无法判断，由测活负责处理。
+func CheckIntegrity(base *http.Transport, timeout time.Duration) (string, error) {
+	return checkIntegrity(base, timeout, integrityResources, integrityHosts, nil)
+}
+
+func checkIntegrity(base *http.Transport, timeout time.Duration, resources []integrityResource, hosts []string, roots *x509.CertPool) (string, error) {
+	plain := &http.Client{
+		Transport: base,
+		Timeout:   timeout,
+		// 重定向本身就是劫持的特征，不跟随
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	for _, r := range resources {
+		if verdict, err := checkResource(plain, r); verdict != "" {
+			return verdict, err
+		}
+	}
+
+	insecure := base.Clone()
+	insecure.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	defer insecure.CloseIdleConnections()
+	tlsClient := &http.Client{
+		Transport:     insecure,
+		Timeout:       timeout,
+		CheckRedirect: plain.CheckRedirect,
+	}
+	for _, h := range hosts {
+		if err := checkCertChain(tlsClient, h, roots); err != nil {
+			return IntegrityMITM, err
+		}
+	}
+	return "", nil
+}
+
+// checkResource 请求明文资源，重定向或内容不一致时返回结论
+//
+// 4xx/5xx(如出口被限流返回 429、503)无法判断，不视为劫持。
+func checkResource(client *http.Client, r integrityResource) (string, error) {
+	resp, err := client.Get(r.URL)
+	if err != nil {
+		return "", nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 && resp.StatusCode < 400 {
+		return IntegrityRedirected, fmt.Errorf("%s -> %d %s", r.URL, resp.StatusCode, resp.Header.Get("Location"))
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", nil
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return "", nil
+	}
+	sum := sha256.Sum256(body)
+	if resp.StatusCode != r.Status || hex.EncodeToString(sum[:]) != r.SHA256 {
+		return IntegrityInjected, fmt.Errorf("%s: status %d, %d bytes", r.URL, resp.StatusCode, len(body))
+	}
+	return "", nil
+}
+
+// checkCertChain 与站点握手并验证证书链，roots 为 nil 时使用系统根证书
+func checkCertChain(client *http.Client, rawURL string, roots *x509.CertPool) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+	resp, err := client.Head(rawURL)
+	if err != nil {
+		return nil
+	}
+	resp.Body.Close()
+	if resp.TLS == nil || len(resp.TLS.PeerCertificates) == 0 {
+		return nil
+	}
+
+	certs := resp.TLS.PeerCertificates
+	opts := x509.VerifyOptions{
+		DNSName:       u.Hostname(),
+		Roots:         roots,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, c := range certs[1:] {
+		opts.Intermediates.AddCert(c)
+	}
+	if _, err := certs[0].Verify(opts); err != nil {
+		return fmt.Errorf("%s: issuer %q: %w", u.Hostname(), certs[0].Issuer.CommonName, err)
+	}
+	return nil
+}
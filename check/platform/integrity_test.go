@@ -0,0 +1,62 @@
+package platform
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCheckIntegrity(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/success.txt", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("success\n"))
+	})
+	mux.HandleFunc("/injected.txt", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("success\n<script src=//ads.example/a.js></script>"))
+	})
+	mux.HandleFunc("/portal", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://login.example/", http.StatusFound)
+	})
+	mux.HandleFunc("/limited", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "too many requests", http.StatusTooManyRequests)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	tlsSrv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer tlsSrv.Close()
+
+	sum := sha256.Sum256([]byte("success\n"))
+	hash := hex.EncodeToString(sum[:])
+	resource := func(path string) []integrityResource {
+		return []integrityResource{{URL: srv.URL + path, Status: 200, SHA256: hash}}
+	}
+	trusted := x509.NewCertPool()
+	trusted.AddCert(tlsSrv.Certificate())
+
+	tests := []struct {
+		name      string
+		resources []integrityResource
+		hosts     []string
+		roots     *x509.CertPool
+		want      string
+	}{
+		{"正常", resource("/success.txt"), []string{tlsSrv.URL}, trusted, ""},
+		{"内容注入", resource("/injected.txt"), nil, nil, IntegrityInjected},
+		{"重定向", resource("/portal"), nil, nil, IntegrityRedirected},
+		{"限流无法判断", resource("/limited"), nil, nil, ""},
+		{"证书不可信", resource("/success.txt"), []string{tlsSrv.URL}, nil, IntegrityMITM},
+		{"请求失败无法判断", resource("/success.txt"), []string{"https://127.0.0.1:1"}, nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := checkIntegrity(&http.Transport{}, 2*time.Second, tt.resources, tt.hosts, tt.roots)
+			if got != tt.want {
+				t.Errorf("checkIntegrity() = %q (%v), want %q", got, err, tt.want)
+			}
+		})
+	}
+}
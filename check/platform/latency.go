@@ -0,0 +1,135 @@
+package platform
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"slices"
+	"time"
+)
+
+// latencyTestURL 延迟测试地址，与测活使用同一端点
+const latencyTestURL = "https://www.gstatic.com/generate_204"
+
+// LatencyResult 存储多次采样后的延迟统计（单位：毫秒）
+type LatencyResult struct {
+	Handshake int // 首次建连耗时：TCP 连接 + TLS 握手
+	Min       int // 最小 HTTP 往返延迟
+	Median    int // HTTP 往返延迟中位数
+	Jitter    int // 相邻采样差值的平均值
+	Samples   int // 成功采样次数
+}
+
+// CheckLatency 通过多次 generate_204 请求测量节点延迟
+//
+// 第一次请求需要新建连接，记录握手耗时；后续请求复用连接，仅统计 HTTP 往返时间。
+// 每次采样的往返时间从请求写出到收到首字节为止，不包含握手耗时。
+func CheckLatency(httpClient *http.Client, samples int) (LatencyResult, error) {
+	var res LatencyResult
+	if samples <= 0 {
+		samples = 1
+	}
+
+	rtts := make([]int, 0, samples)
+	var lastErr error
+
+	for range samples {
+		rtt, handshake, err := latencySample(httpClient)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if handshake > 0 && res.Handshake == 0 {
+			res.Handshake = handshake
+		}
+		rtts = append(rtts, rtt)
+	}
+
+	if len(rtts) == 0 {
+		if lastErr == nil {
+			lastErr = fmt.Errorf("no latency sample")
+		}
+		return res, lastErr
+	}
+
+	res.Min, res.Median, res.Jitter = summarizeLatency(rtts)
+	res.Samples = len(rtts)
+	return res, nil
+}
+
+// latencySample 执行一次采样，返回 HTTP 往返延迟和本次建连耗时（复用连接时为 0）
+func latencySample(httpClient *http.Client) (rtt int, handshake int, err error) {
+	var connectStart, connectDone, tlsDone, wroteRequest, firstByte time.Time
+
+	trace := &httptrace.ClientTrace{
+		ConnectStart: func(_, _ string) {
+			if connectStart.IsZero() {
+				connectStart = time.Now()
+			}
+		},
+		ConnectDone: func(_, _ string, _ error) { connectDone = time.Now() },
+		TLSHandshakeDone: func(_ tls.ConnectionState, _ error) {
+			tlsDone = time.Now()
+		},
+		WroteRequest:         func(_ httptrace.WroteRequestInfo) { wroteRequest = time.Now() },
+		GotFirstResponseByte: func() { firstByte = time.Now() },
+	}
+
+	ctx := httptrace.WithClientTrace(context.Background(), trace)
+	req, err := http.NewRequestWithContext(ctx, "HEAD", latencyTestURL, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36")
+
+	start := time.Now()
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, 0, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return 0, 0, fmt.Errorf("http status %d", resp.StatusCode)
+	}
+
+	if !connectStart.IsZero() {
+		end := connectDone
+		if !tlsDone.IsZero() {
+			end = tlsDone
+		}
+		if end.After(connectStart) {
+			handshake = int(end.Sub(connectStart).Milliseconds())
+		}
+	}
+
+	// 缺少 trace 事件时退回整体耗时
+	if wroteRequest.IsZero() || firstByte.IsZero() || !firstByte.After(wroteRequest) {
+		return int(time.Since(start).Milliseconds()), handshake, nil
+	}
+	return int(firstByte.Sub(wroteRequest).Milliseconds()), handshake, nil
+}
+
+// summarizeLatency 计算采样的最小值、中位数和抖动
+// 抖动取相邻两次采样差值绝对值的平均数（按采样顺序）
+func summarizeLatency(rtts []int) (minRTT, med, jitter int) {
+	if len(rtts) == 0 {
+		return 0, 0, 0
+	}
+
+	if len(rtts) > 1 {
+		sum := 0
+		for i := 1; i < len(rtts); i++ {
+			d := rtts[i] - rtts[i-1]
+			if d < 0 {
+				d = -d
+			}
+			sum += d
+		}
+		jitter = sum / (len(rtts) - 1)
+	}
+
+	return slices.Min(rtts), median(rtts), jitter
+}
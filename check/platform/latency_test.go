@@ -0,0 +1,26 @@
+package platform
+
+import "testing"
+
+func TestSummarizeLatency(t *testing.T) {
+	tests := []struct {
+		name                      string
+		rtts                      []int
+		wantMin, wantMed, wantJit int
+	}{
+		{"空采样", nil, 0, 0, 0},
+		{"单次采样", []int{120}, 120, 120, 0},
+		{"奇数采样", []int{100, 80, 120}, 80, 100, 30},
+		{"偶数采样", []int{60, 70, 90, 80}, 60, 75, 13},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotMin, gotMed, gotJit := summarizeLatency(tt.rtts)
+			if gotMin != tt.wantMin || gotMed != tt.wantMed || gotJit != tt.wantJit {
+				t.Errorf("summarizeLatency(%v) = (%d, %d, %d), want (%d, %d, %d)",
+					tt.rtts, gotMin, gotMed, gotJit, tt.wantMin, tt.wantMed, tt.wantJit)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package platform
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/metacubex/mihomo/common/convert"
+	"github.com/sinspired/subs-check-pro/config"
+)
+
+// probeBodyLimit 自定义探测读取响应内容的上限
+const probeBodyLimit = 1 << 20
+
+// probeChecker 根据配置 custom-probes 生成的声明式 HTTP 检测器
+type probeChecker struct {
+	Meta
+	probe    config.CustomProbe
+	reBody   *regexp.Regexp
+	reNot    *regexp.Regexp
+	reRegion *regexp.Regexp
+}
+
+// NewProbeChecker 校验探测配置并编译正则
+func NewProbeChecker(p config.CustomProbe) (Checker, error) {
+	if p.Name == "" || p.URL == "" {
+		return nil, fmt.Errorf("name 和 url 不能为空")
+	}
+	tag := p.Tag
+	if tag == "" {
+		tag = p.Name
+	}
+	c := &probeChecker{
+		Meta:  Meta{PlatformName: p.Name, DisplayName: p.Name, TagName: tag, Kind: CategoryOther, CF: p.NeedCF},
+		probe: p,
+	}
+
+	var err error
+	if c.reBody, err = compileOptional(p.BodyRegex); err != nil {
+		return nil, fmt.Errorf("body-regex: %w", err)
+	}
+	if c.reNot, err = compileOptional(p.BodyNotRegex); err != nil {
+		return nil, fmt.Errorf("body-not-regex: %w", err)
+	}
+	if c.reRegion, err = compileOptional(p.RegionRegex); err != nil {
+		return nil, fmt.Errorf("region-regex: %w", err)
+	}
+	return c, nil
+}
+
+func compileOptional(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	return regexp.Compile(expr)
+}
+
+// Check 发送请求并依次校验状态码、正向/反向正则，最后提取地区
+func (c *probeChecker) Check(ctx context.Context, httpClient *http.Client) (PlatformResult, error) {
+	method := strings.ToUpper(c.probe.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.probe.URL, nil)
+	if err != nil {
+		return PlatformResult{}, err
+	}
+	req.Header.Set("User-Agent", convert.RandUserAgent())
+	for k, v := range c.probe.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return PlatformResult{}, err
+	}
+	defer resp.Body.Close()
+
+	if len(c.probe.ExpectStatus) > 0 {
+		if !slices.Contains(c.probe.ExpectStatus, resp.StatusCode) {
+			return PlatformResult{}, nil
+		}
+	} else if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return PlatformResult{}, nil
+	}
+
+	// 无需检查内容时不读取响应
+	if c.reBody == nil && c.reNot == nil && c.reRegion == nil {
+		return PlatformResult{OK: true}, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, probeBodyLimit))
+	if err != nil {
+		return PlatformResult{}, err
+	}
+
+	if c.reBody != nil && !c.reBody.Match(body) {
+		return PlatformResult{}, nil
+	}
+	if c.reNot != nil && c.reNot.Match(body) {
+		return PlatformResult{}, nil
+	}
+
+	res := PlatformResult{OK: true}
+	if c.reRegion != nil {
+		if m := c.reRegion.FindSubmatch(body); len(m) > 1 {
+			res.Region = strings.ToUpper(string(m[1]))
+		}
+	}
+	return res, nil
+}
+
+// NewProbes 按配置生成自定义探测，不写入全局注册表
+//
+// 探测由检测会话持有，并发的检测各自使用创建时的配置；
+// 与内置平台重名、同名重复或配置错误的探测会被跳过。
+func NewProbes(probes []config.CustomProbe) []Checker {
+	checkers := make([]Checker, 0, len(probes))
+	for _, p := range probes {
+		if _, ok := Lookup(p.Name); ok {
+			slog.Warn(fmt.Sprintf("自定义探测 %s 与内置平台重名，已跳过", p.Name))
+			continue
+		}
+		if slices.ContainsFunc(checkers, func(c Checker) bool { return strings.EqualFold(c.Name(), p.Name) }) {
+			slog.Warn(fmt.Sprintf("自定义探测 %s 重复，已跳过", p.Name))
+			continue
+		}
+		c, err := NewProbeChecker(p)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("自定义探测 %s 配置错误: %v", p.Name, err))
+			continue
+		}
+		checkers = append(checkers, c)
+	}
+	return checkers
+}
@@ -0,0 +1,94 @@
+package platform
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sinspired/subs-check-pro/config"
+)
+
+func TestProbeChecker(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Token") != "secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":"ok","country":"jp"}`))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name       string
+		probe      config.CustomProbe
+		wantOK     bool
+		wantRegion string
+	}{
+		{
+			name:   "缺少请求头",
+			probe:  config.CustomProbe{Name: "p1", URL: srv.URL},
+			wantOK: false,
+		},
+		{
+			name:   "状态码匹配",
+			probe:  config.CustomProbe{Name: "p2", URL: srv.URL, ExpectStatus: []int{403}},
+			wantOK: true,
+		},
+		{
+			name: "正则和地区",
+			probe: config.CustomProbe{
+				Name: "p3", URL: srv.URL, Headers: map[string]string{"X-Token": "secret"},
+				BodyRegex: `"status":"ok"`, RegionRegex: `"country":"([a-z]{2})"`,
+			},
+			wantOK:     true,
+			wantRegion: "JP",
+		},
+		{
+			name: "反向正则",
+			probe: config.CustomProbe{
+				Name: "p4", URL: srv.URL, Headers: map[string]string{"X-Token": "secret"},
+				BodyNotRegex: `country`,
+			},
+			wantOK: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewProbeChecker(tt.probe)
+			if err != nil {
+				t.Fatalf("NewProbeChecker() error = %v", err)
+			}
+			res, err := c.Check(context.Background(), srv.Client())
+			if err != nil {
+				t.Fatalf("Check() error = %v", err)
+			}
+			if res.OK != tt.wantOK || res.Region != tt.wantRegion {
+				t.Errorf("Check() = %+v, want OK=%v Region=%q", res, tt.wantOK, tt.wantRegion)
+			}
+		})
+	}
+}
+
+func TestNewProbesSkipsBuiltin(t *testing.T) {
+	probes := NewProbes([]config.CustomProbe{
+		{Name: "netflix", URL: "https://example.com"},
+		{Name: "bad-regex", URL: "https://example.com", BodyRegex: "("},
+		{Name: "saas", URL: "https://example.com", Tag: "SaaS"},
+		{Name: "SaaS", URL: "https://example.org"},
+	})
+	if len(probes) != 1 || probes[0].Name() != "saas" || probes[0].Tag() != "SaaS" {
+		t.Fatalf("NewProbes() = %v, want [saas]", probes)
+	}
+	if c, _ := Lookup("netflix"); c.Tag() != "NF" {
+		t.Error("内置 netflix 检测器被覆盖")
+	}
+}
+
+func TestNewProbesKeepsRegistry(t *testing.T) {
+	NewProbes([]config.CustomProbe{{Name: "local-probe", URL: "https://example.com"}})
+	if _, ok := Lookup("local-probe"); ok {
+		t.Error("自定义探测写入了全局注册表")
+	}
+}
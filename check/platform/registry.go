@@ -0,0 +1,134 @@
+package platform
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Category 平台分类，用于分析报告中区分流媒体与 AI 解锁
+type Category string
+
+const (
+	CategoryMedia Category = "media"
+	CategoryAI    Category = "ai"
+	CategoryOther Category = "other"
+)
+
+// PlatformResult 单个平台的检测结果
+type PlatformResult struct {
+	OK     bool   // 是否解锁
+	Region string // 解锁地区，为空表示不区分地区
+	Tag    string // 覆盖默认标签，为空使用 Checker.Tag()
+	Label  string // 覆盖统计名称，为空使用 Checker.Label()
+}
+
+// Checker 平台解锁检测器
+//
+// 实现该接口并调用 Register 注册后，即可在配置 platforms 中按 Name() 启用，
+// 检测结果、节点标签和分析报告统计均由注册表统一处理。
+type Checker interface {
+	Name() string       // 配置 platforms 中使用的名称，如 netflix
+	Label() string      // 分析报告中的显示名称，如 Netflix
+	Tag() string        // 节点名称中的标签，如 NF
+	Category() Category // 平台分类
+	NeedsCF() bool      // 是否依赖 Cloudflare 可达
+	Check(ctx context.Context, httpClient *http.Client) (PlatformResult, error)
+}
+
+// Meta 提供 Checker 的元数据方法，自定义检测器可嵌入使用
+type Meta struct {
+	PlatformName string
+	DisplayName  string
+	TagName      string
+	Kind         Category
+	CF           bool
+	// CFBlockHide 节点 CF 受限(⁻¹/🏴‍☠️)时不添加标签
+	CFBlockHide bool
+}
+
+func (m Meta) Name() string  { return m.PlatformName }
+func (m Meta) Tag() string   { return m.TagName }
+func (m Meta) NeedsCF() bool { return m.CF }
+
+func (m Meta) HideOnCFBlock() bool { return m.CFBlockHide }
+
+func (m Meta) Label() string {
+	if m.DisplayName != "" {
+		return m.DisplayName
+	}
+	return m.PlatformName
+}
+
+func (m Meta) Category() Category {
+	if m.Kind == "" {
+		return CategoryOther
+	}
+	return m.Kind
+}
+
+// TagOf 返回检测结果对应的节点标签
+func TagOf(c Checker, r PlatformResult) string {
+	if r.Tag != "" {
+		return r.Tag
+	}
+	return c.Tag()
+}
+
+// HideOnCFBlock 节点 CF 受限时是否隐藏该平台标签，未嵌入 Meta 的检测器返回 false
+func HideOnCFBlock(c Checker) bool {
+	h, ok := c.(interface{ HideOnCFBlock() bool })
+	return ok && h.HideOnCFBlock()
+}
+
+// LabelOf 返回检测结果对应的统计名称
+func LabelOf(c Checker, r PlatformResult) string {
+	if r.Label != "" {
+		return r.Label
+	}
+	return c.Label()
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Checker)
+)
+
+// Register 注册平台检测器，同名检测器会被覆盖
+func Register(c Checker) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[strings.ToLower(c.Name())] = c
+}
+
+// Lookup 按名称查找已注册的检测器
+func Lookup(name string) (Checker, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	c, ok := registry[strings.ToLower(name)]
+	return c, ok
+}
+
+// Checkers 返回按名称排序的全部已注册检测器
+func Checkers() []Checker {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	list := make([]Checker, 0, len(registry))
+	for _, c := range registry {
+		list = append(list, c)
+	}
+	slices.SortFunc(list, func(a, b Checker) int { return strings.Compare(a.Name(), b.Name()) })
+	return list
+}
+
+// StatusError 响应状态码不符合预期
+type StatusError struct {
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("http status %d", e.Code)
+}
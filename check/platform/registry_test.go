@@ -0,0 +1,39 @@
+package platform
+
+import "testing"
+
+func TestBuiltinCheckers(t *testing.T) {
+	for _, name := range []string{"openai", "x", "gemini", "youtube", "netflix", "disney", "tiktok"} {
+		c, ok := Lookup(name)
+		if !ok {
+			t.Errorf("内置检测器 %s 未注册", name)
+			continue
+		}
+		if c.Tag() == "" {
+			t.Errorf("检测器 %s 缺少标签", name)
+		}
+	}
+
+	if c, ok := Lookup("OpenAI"); !ok || !c.NeedsCF() {
+		t.Error("openai 应忽略大小写查找，且依赖 CF")
+	}
+}
+
+func TestTagOf(t *testing.T) {
+	c, _ := Lookup("openai")
+	if got := TagOf(c, PlatformResult{OK: true}); got != "GPT" {
+		t.Errorf("TagOf 默认标签 = %s, want GPT", got)
+	}
+	if got := TagOf(c, PlatformResult{OK: true, Tag: "GPT⁺"}); got != "GPT⁺" {
+		t.Errorf("TagOf 覆盖标签 = %s, want GPT⁺", got)
+	}
+}
+
+func TestHideOnCFBlock(t *testing.T) {
+	for name, want := range map[string]bool{"x": true, "openai": false, "netflix": false} {
+		c, _ := Lookup(name)
+		if got := HideOnCFBlock(c); got != want {
+			t.Errorf("HideOnCFBlock(%s) = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -2,17 +2,19 @@ package platform
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"math/rand/v2"
 	"net/http"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/juju/ratelimit"
 	"github.com/metacubex/mihomo/common/convert"
-	"github.com/sinspired/subs-check-pro/config"
 )
 
 var testURLs []string
@@ -26,9 +28,10 @@ func init() {
 // networkLimitedReader 负责在读取 Body 时检查底层网络流量是否超限
 type networkLimitedReader struct {
 	reader      io.Reader
-	getNetBytes func() uint64 // 获取底层原子计数
-	startBytes  uint64        // 初始读数
-	limit       uint64        // 限制阈值 (0为不限制)
+	getNetBytes func() uint64  // 获取底层原子计数
+	startBytes  uint64         // 初始读数
+	limit       uint64         // 限制阈值 (0为不限制)
+	counter     *atomic.Uint64 // 累计读取的字节数，可为 nil
 }
 
 func (r *networkLimitedReader) Read(p []byte) (int, error) {
@@ -55,107 +58,366 @@ func (r *networkLimitedReader) Read(p []byte) (int, error) {
 			p = p[:remaining]
 		}
 	}
-	return r.reader.Read(p)
+	n, err := r.reader.Read(p)
+	if r.counter != nil {
+		r.counter.Add(uint64(n))
+	}
+	return n, err
+}
+
+// SpeedOptions 下载测速参数
+type SpeedOptions struct {
+	URL        string          // 测速地址，包含 random 时随机选择内置地址
+	Timeout    time.Duration   // 单节点测速总时长，多个地址平分
+	LimitBytes uint64          // 单节点下载流量上限，多个地址平分，0 为不限制
+	Streams    int             // 每个地址的并发连接数，默认 1
+	Samples    int             // 测速地址数量，结果取中位数，默认 1
+	Health     *SpeedURLHealth // 测速地址健康状况，nil 时不跟踪
+}
+
+// SpeedResult 下载测速结果
+type SpeedResult struct {
+	Speed   int   // 持续速度(KB/s)：扣除慢启动阶段后的平均速度，多个地址取中位数
+	Peak    int   // 峰值速度(KB/s)：1 秒窗口内的最高速度
+	TTFB    int   // 首字节时间(ms)，多个地址取中位数
+	Bytes   int64 // 下载流量
+	Samples int   // 成功测速的地址数量
 }
 
+const (
+	speedSampleInterval = 250 * time.Millisecond
+	speedPeakWindow     = 4 // 峰值窗口为 4 个采样间隔，即 1 秒
+	slowStartWindow     = time.Second
+)
+
 // CheckSpeed 执行下载测速
-func CheckSpeed(httpClient *http.Client, bucket *ratelimit.Bucket, getNetBytes func() uint64) (int, int64, error) {
-	// 确定测速 URL，根据配置使用随机下载测速链接
-	url := config.GlobalConfig.SpeedTestURL
-	if strings.Contains(url, "random") && len(testURLs) > 0 {
-		url = testURLs[rand.IntN(len(testURLs))]
-	}
-	slog.Debug("随机选择的测速URL", "url", url)
+//
+// 依次测试 Samples 个地址，每个地址同时建立 Streams 个连接下载，
+// 持续速度和首字节时间取各地址的中位数，峰值取最大值。
+func CheckSpeed(httpClient *http.Client, opt SpeedOptions, getNetBytes func() uint64) (SpeedResult, error) {
+	urls := opt.pickURLs()
+	n := len(urls)
+	timeout := opt.Timeout / time.Duration(n)
+	limit := opt.LimitBytes / uint64(n)
 
 	speedClient := *httpClient
 	speedClient.Timeout = 0
 
-	// 下载需要根据配置文件设置较长的超时
-	timeout := time.Duration(config.GlobalConfig.DownloadTimeout) * time.Second
+	var res SpeedResult
+	var speeds, ttfbs []int
+	var lastErr error
+	for _, url := range urls {
+		slog.Debug("测速URL", "url", url)
+		m, err := measureURL(&speedClient, url, max(opt.Streams, 1), timeout, limit, getNetBytes)
+		opt.Health.report(url, m.bytes, err)
+		res.Bytes += m.bytes
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		speeds = append(speeds, m.sustained)
+		ttfbs = append(ttfbs, m.ttfb)
+		res.Peak = max(res.Peak, m.peak)
+	}
+	if len(speeds) == 0 {
+		return res, lastErr
+	}
+
+	res.Speed = median(speeds)
+	res.TTFB = median(ttfbs)
+	res.Samples = len(speeds)
+	slog.Debug(fmt.Sprintf("测速完成: 持续 %d KB/s, 峰值 %d KB/s, 首字节 %dms, 流量: %d 字节, 地址: %d/%d",
+		res.Speed, res.Peak, res.TTFB, res.Bytes, res.Samples, n))
+	return res, nil
+}
+
+// configuredURLs 解析 speed-test-url，多个地址以逗号分隔，random 展开为内置列表
+func (opt SpeedOptions) configuredURLs() []string {
+	var urls []string
+	for u := range strings.SplitSeq(opt.URL, ",") {
+		u = strings.TrimSpace(u)
+		switch {
+		case u == "":
+		case strings.Contains(u, "random") && len(testURLs) > 0:
+			urls = append(urls, testURLs...)
+		default:
+			urls = append(urls, u)
+		}
+	}
+	seen := make(map[string]bool, len(urls))
+	return slices.DeleteFunc(urls, func(u string) bool {
+		dup := seen[u]
+		seen[u] = true
+		return dup
+	})
+}
+
+// pickURLs 从可用的测速地址中随机选择不重复的 Samples 个，地址不足时减少采样数
+func (opt SpeedOptions) pickURLs() []string {
+	configured := opt.configuredURLs()
+	if len(configured) == 0 {
+		return []string{opt.URL}
+	}
+
+	alive := func(urls []string) []string {
+		out := make([]string, 0, len(urls))
+		for _, u := range urls {
+			if opt.Health.alive(u) {
+				out = append(out, u)
+			}
+		}
+		return out
+	}
+	candidates := alive(configured)
+	// 配置的地址全部失效时使用内置列表，仍全部失效时回退到配置的地址
+	if len(candidates) == 0 {
+		candidates = alive(testURLs)
+	}
+	if len(candidates) == 0 {
+		candidates = configured
+	}
+
+	samples := min(max(opt.Samples, 1), len(candidates))
+	picked := make([]string, 0, samples)
+	for _, i := range rand.Perm(len(candidates))[:samples] {
+		picked = append(picked, candidates[i])
+	}
+	return picked
+}
+
+// urlMeasure 单个地址的测速结果
+type urlMeasure struct {
+	sustained int // KB/s
+	peak      int // KB/s
+	ttfb      int // ms
+	bytes     int64
+}
+
+// speedPoint 采样点：距开始的时间和累计流量
+type speedPoint struct {
+	t     time.Duration
+	bytes uint64
+}
+
+// measureURL 使用多个连接同时下载同一地址
+func measureURL(client *http.Client, url string, streams int, timeout time.Duration, limit uint64, getNetBytes func() uint64) (urlMeasure, error) {
+	var m urlMeasure
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
+	// 无法获取网络层流量时，使用应用层读取的字节数
+	var copied atomic.Uint64
+	readBytes := getNetBytes
+	if readBytes == nil {
+		readBytes = copied.Load
+	}
+	startBytes := readBytes()
+	start := time.Now()
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		firstByte time.Duration // 首个连接收到响应的时间
+		streamErr error
+		succeeded int
+	)
+	for range streams {
+		wg.Go(func() {
+			err := downloadStream(ctx, client, url, &networkLimitedReader{
+				getNetBytes: readBytes,
+				startBytes:  startBytes,
+				limit:       limit,
+				counter:     &copied,
+			}, func() {
+				mu.Lock()
+				if firstByte == 0 {
+					firstByte = time.Since(start)
+				}
+				mu.Unlock()
+			})
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				streamErr = err
+			} else {
+				succeeded++
+			}
+		})
+	}
+
+	// 定时采样累计流量，用于计算峰值和持续速度
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	points := []speedPoint{{0, 0}}
+	ticker := time.NewTicker(speedSampleInterval)
+	defer ticker.Stop()
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		case <-ticker.C:
+		}
+		points = append(points, speedPoint{time.Since(start), readBytes() - startBytes})
+	}
+
+	total := points[len(points)-1].bytes
+	m.bytes = int64(total)
+	if succeeded == 0 {
+		return m, streamErr
+	}
+	if total == 0 {
+		// 即使超时也应该有一点数据，如果完全没数据则报错
+		return m, fmt.Errorf("no bytes transfer")
+	}
+	m.ttfb = int(firstByte.Milliseconds())
+	m.sustained, m.peak = summarizeSpeed(points, firstByte)
+	return m, nil
+}
+
+// downloadStream 下载并丢弃响应内容，收到响应头时调用 onFirstByte
+func downloadStream(ctx context.Context, client *http.Client, url string, limited *networkLimitedReader, onFirstByte func()) error {
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		return 0, 0, err
+		return err
 	}
-
-	// 设置请求头
 	req.Header.Set("User-Agent", convert.RandUserAgent())
 	req.Header.Set("Cache-Control", "no-cache")
 
-	// 发起请求
-	resp, err := speedClient.Do(req)
+	resp, err := client.Do(req)
 	if err != nil {
-		return 0, 0, err
+		return err
 	}
 	defer resp.Body.Close()
-
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return 0, 0, fmt.Errorf("http status %d", resp.StatusCode)
+		return &StatusError{Code: resp.StatusCode}
 	}
+	onFirstByte()
 
-	// 准备读取器
-	var startNetBytes uint64
-	if getNetBytes != nil {
-		startNetBytes = getNetBytes()
+	limited.reader = resp.Body
+	_, err = io.Copy(io.Discard, limited)
+	// 超时或达到流量上限是测速的正常结束状态
+	if err != nil && !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, context.Canceled) {
+		return err
 	}
+	return nil
+}
 
-	var limit uint64
-	if mb := config.GlobalConfig.DownloadMB; mb > 0 {
-		limit = uint64(mb) * 1024 * 1024
+// summarizeSpeed 根据采样点计算持续速度和峰值速度(KB/s)
+//
+// 持续速度从首字节后 slowStartWindow 开始计算，以排除 TCP 慢启动；
+// 下载时间过短时，慢启动窗口缩短为下载时间的 1/3。
+func summarizeSpeed(points []speedPoint, firstByte time.Duration) (sustained, peak int) {
+	last := points[len(points)-1]
+	rate := func(a, b speedPoint) int {
+		d := max((b.t - a.t).Seconds(), 0.1) // 防止除零
+		return int(float64(b.bytes-a.bytes) / 1024.0 / d)
 	}
 
-	limitedReader := &networkLimitedReader{
-		reader:      resp.Body,
-		getNetBytes: getNetBytes,
-		startBytes:  startNetBytes,
-		limit:       limit,
+	window := min(slowStartWindow, (last.t-firstByte)/3)
+	from := points[0]
+	for _, p := range points {
+		if p.t > firstByte+window {
+			break
+		}
+		from = p
 	}
+	sustained = rate(from, last)
 
-	// 执行下载 (io.Copy)
-	startTime := time.Now()
-	// copiedBytes，以便在 getNetBytes 失败时兜底
-	copiedBytes, err := io.Copy(io.Discard, limitedReader)
-
-	// 如果错误是“超时”或“EOF”，这是测速的正常结束状态，不应视为 Failure
-	if err != nil && err != io.EOF && err != context.DeadlineExceeded && err != context.Canceled {
-		return 0, 0, err
+	peak = rate(points[0], last)
+	for i := speedPeakWindow; i < len(points); i++ {
+		peak = max(peak, rate(points[i-speedPeakWindow], points[i]))
 	}
+	return sustained, max(peak, sustained)
+}
 
-	// 计算耗时
-	duration := time.Since(startTime).Seconds()
-	if duration < 0.1 {
-		duration = 0.1 // 防止除零
+// median 返回中位数，偶数个时取中间两个的平均值
+func median(v []int) int {
+	s := slices.Sorted(slices.Values(v))
+	n := len(s)
+	if n%2 == 1 {
+		return s[n/2]
 	}
+	return (s[n/2-1] + s[n/2]) / 2
+}
+
+// SpeedURLHealth 记录测速地址的可用状况，失效地址在本轮检测中不再使用
+//
+// 返回 404/410 的地址立即标记为失效；连续多次没有下载到数据且从未成功的地址也标记为失效。
+type SpeedURLHealth struct {
+	mu   sync.Mutex
+	urls map[string]*speedURLState
+}
 
-	// 计算总流量
-	var totalBytes int64
-	useNetBytes := false
+type speedURLState struct {
+	ok, empty int
+	dead      bool
+}
 
-	// 尝试使用网络层流量（包含 Header、TLS握手、TCP重传等真实流量）
-	if getNetBytes != nil {
-		curr := getNetBytes()
-		if curr >= startNetBytes {
-			totalBytes = int64(curr - startNetBytes)
-			useNetBytes = true
-		}
-	}
+// speedURLMaxEmpty 未成功过的地址连续多少次没有数据后标记为失效
+const speedURLMaxEmpty = 3
 
-	// 兜底逻辑：如果无法获取网络层流量，或计算异常，回退到应用层流量
-	if !useNetBytes || totalBytes <= 0 {
-		totalBytes = copiedBytes
-	}
+// NewSpeedURLHealth 创建测速地址健康记录
+func NewSpeedURLHealth() *SpeedURLHealth {
+	return &SpeedURLHealth{urls: make(map[string]*speedURLState)}
+}
 
-	if totalBytes <= 0 {
-		// 即使超时也应该有一点数据，如果完全没数据则报错
-		return 0, 0, fmt.Errorf("no bytes transfer")
+func (h *SpeedURLHealth) alive(url string) bool {
+	if h == nil {
+		return true
 	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	st := h.urls[url]
+	return st == nil || !st.dead
+}
 
-	// 计算速度 (KB/s)
-	speed := int(float64(totalBytes) / 1024.0 / duration)
+func (h *SpeedURLHealth) report(url string, bytes int64, err error) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	st := h.urls[url]
+	if st == nil {
+		st = &speedURLState{}
+		h.urls[url] = st
+	}
+	if st.dead {
+		return
+	}
 
-	slog.Debug(fmt.Sprintf("测速完成: %d KB/s, 耗时: %.2fs, 流量: %d 字节, 方式: %v", speed, duration, totalBytes, useNetBytes))
+	var statusErr *StatusError
+	switch {
+	case errors.As(err, &statusErr) && (statusErr.Code == http.StatusNotFound || statusErr.Code == http.StatusGone):
+		st.dead = true
+	case bytes > 0:
+		st.ok++
+		st.empty = 0
+	default:
+		st.empty++
+		st.dead = st.ok == 0 && st.empty >= speedURLMaxEmpty
+	}
+	if st.dead {
+		slog.Info(fmt.Sprintf("测速地址失效，本轮检测跳过: %s", url), "err", err)
+	}
+}
 
-	return speed, totalBytes, nil
+// Dead 返回已失效的测速地址
+func (h *SpeedURLHealth) Dead() []string {
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var dead []string
+	for url, st := range h.urls {
+		if st.dead {
+			dead = append(dead, url)
+		}
+	}
+	slices.Sort(dead)
+	return dead
 }
@@ -8,12 +8,15 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -443,3 +446,74 @@ var fastSpeedTestURLs = []string{
 	}
 	t.Logf("generated curated list: %s (items=%d)", genPath, len(selected))
 }
+
+func TestSummarizeSpeed(t *testing.T) {
+	// 首字节 0.25s，前 1s 为慢启动 (100KB/s)，之后 1000KB/s
+	var points []speedPoint
+	var bytes uint64
+	for i := 0; i <= 16; i++ {
+		at := time.Duration(i) * speedSampleInterval
+		points = append(points, speedPoint{at, bytes})
+		if at < 1250*time.Millisecond {
+			bytes += 25 * 1024
+		} else {
+			bytes += 250 * 1024
+		}
+	}
+	sustained, peak := summarizeSpeed(points, speedSampleInterval)
+	if sustained < 950 || sustained > 1000 {
+		t.Errorf("sustained = %d, want ~1000", sustained)
+	}
+	if peak != 1000 {
+		t.Errorf("peak = %d, want 1000", peak)
+	}
+}
+
+func TestCheckSpeedStreams(t *testing.T) {
+	var requests atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/file", func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		_, _ = w.Write(make([]byte, 256*1024))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	health := NewSpeedURLHealth()
+	opt := SpeedOptions{URL: srv.URL + "/file", Timeout: 4 * time.Second, Streams: 2, Samples: 2, Health: health}
+	res, err := CheckSpeed(srv.Client(), opt, nil)
+	if err != nil {
+		t.Fatalf("CheckSpeed() error = %v", err)
+	}
+	// 只有一个地址时只测一次，不重复下载
+	if requests.Load() != 2 || res.Samples != 1 || res.Bytes != 2*256*1024 || res.Speed <= 0 || res.Peak < res.Speed {
+		t.Errorf("CheckSpeed() = %+v, requests = %d", res, requests.Load())
+	}
+
+	// 404 地址立即失效，随机选择时跳过
+	opt = SpeedOptions{URL: srv.URL + "/missing", Timeout: time.Second, Health: health}
+	if _, err := CheckSpeed(srv.Client(), opt, nil); err == nil {
+		t.Fatal("CheckSpeed() on 404 should fail")
+	}
+	if dead := health.Dead(); len(dead) != 1 || dead[0] != opt.URL {
+		t.Errorf("Dead() = %v", dead)
+	}
+
+	old := testURLs
+	defer func() { testURLs = old }()
+	testURLs = []string{srv.URL + "/missing", srv.URL + "/file"}
+	opt = SpeedOptions{URL: "random", Samples: 2, Health: health}
+	if urls := opt.pickURLs(); len(urls) != 1 || urls[0] != srv.URL+"/file" {
+		t.Errorf("pickURLs() = %v", urls)
+	}
+
+	// 配置多个地址时选择不重复的可用地址，配置的地址全部失效时使用内置列表
+	opt = SpeedOptions{URL: srv.URL + "/a, " + srv.URL + "/missing," + srv.URL + "/b", Samples: 3, Health: health}
+	if urls := opt.pickURLs(); len(urls) != 2 || slices.Contains(urls, srv.URL+"/missing") || urls[0] == urls[1] {
+		t.Errorf("pickURLs() = %v", urls)
+	}
+	opt = SpeedOptions{URL: srv.URL + "/missing", Samples: 2, Health: health}
+	if urls := opt.pickURLs(); len(urls) != 1 || urls[0] != srv.URL+"/file" {
+		t.Errorf("配置地址失效时 pickURLs() = %v", urls)
+	}
+}
@@ -0,0 +1,246 @@
+package platform
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// NAT 类型（粗略）：向两个不同的 STUN 服务器发送请求，比较映射地址
+const (
+	NATCone      = "cone"      // 映射与目标无关（全锥、受限锥、端口受限锥）
+	NATSymmetric = "symmetric" // 不同目标映射到不同端口
+)
+
+// UDPDNSServer UDP 检测使用的 DNS 服务器，创建 UDP 会话时作为首个目标
+var UDPDNSServer = netip.MustParseAddrPort("1.1.1.1:53")
+
+// stunServers 两个不同 IP 的 STUN 服务器，用于判断 NAT 类型
+var stunServers = []struct {
+	Host string
+	Port uint16
+}{
+	{"stun.l.google.com", 19302},
+	{"stun.cloudflare.com", 3478},
+}
+
+// UDPResult UDP 检测结果
+type UDPResult struct {
+	Supported bool           // 通过节点完成 DNS 往返
+	NATType   string         // 为空表示无法判断
+	Mapped    netip.AddrPort // STUN 返回的出口地址
+}
+
+// CheckUDP 通过节点的 UDP 会话查询 DNS 并进行 STUN 交互
+//
+// conn 应由 UDPDNSServer 为目标创建；部分协议的 UDP 会话只能访问首个目标，
+// 此时 DNS 可通过但 STUN 失败，NAT 类型为空。timeout 为单次往返的超时时间。
+func CheckUDP(conn net.PacketConn, timeout time.Duration) (UDPResult, error) {
+	var res UDPResult
+
+	// DNS 查询 STUN 服务器地址，同时验证 UDP 转发
+	addrs := make([]netip.AddrPort, 0, len(stunServers))
+	var lastErr error
+	for _, s := range stunServers {
+		ip, err := udpResolve(conn, s.Host, timeout)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		res.Supported = true
+		addrs = append(addrs, netip.AddrPortFrom(ip, s.Port))
+	}
+	if !res.Supported {
+		return res, fmt.Errorf("dns over udp: %w", lastErr)
+	}
+
+	var mapped []netip.AddrPort
+	for _, addr := range addrs {
+		m, err := stunBinding(conn, addr, timeout)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		mapped = append(mapped, m)
+	}
+	if len(mapped) == 0 {
+		return res, fmt.Errorf("stun: %w", lastErr)
+	}
+	res.Mapped = mapped[0]
+	if len(mapped) == 2 {
+		res.NATType = NATCone
+		if mapped[0] != mapped[1] {
+			res.NATType = NATSymmetric
+		}
+	}
+	return res, nil
+}
+
+// udpExchange 发送请求并等待 match 接受的响应
+func udpExchange(conn net.PacketConn, dst netip.AddrPort, req []byte, timeout time.Duration, match func([]byte) bool) ([]byte, error) {
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+	defer conn.SetDeadline(time.Time{})
+
+	if _, err := conn.WriteTo(req, net.UDPAddrFromAddrPort(dst)); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 1500)
+	for {
+		n, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			return nil, err
+		}
+		// 响应来源与目标不一致时说明会话被绑定到其他目标，丢弃
+		if ua, ok := from.(*net.UDPAddr); ok && ua.IP != nil && ua.AddrPort().Addr().Unmap() != dst.Addr() {
+			continue
+		}
+		if match(buf[:n]) {
+			return buf[:n], nil
+		}
+	}
+}
+
+// udpResolve 通过 UDP 向 UDPDNSServer 查询 A 记录
+func udpResolve(conn net.PacketConn, host string, timeout time.Duration) (netip.Addr, error) {
+	name, err := dnsmessage.NewName(host + ".")
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	var idBuf [2]byte
+	_, _ = rand.Read(idBuf[:])
+	id := binary.BigEndian.Uint16(idBuf[:])
+	msg := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET}},
+	}
+	req, err := msg.Pack()
+	if err != nil {
+		return netip.Addr{}, err
+	}
+
+	resp, err := udpExchange(conn, UDPDNSServer, req, timeout, func(b []byte) bool {
+		return len(b) >= 2 && binary.BigEndian.Uint16(b) == id
+	})
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	var p dnsmessage.Parser
+	if _, err := p.Start(resp); err != nil {
+		return netip.Addr{}, err
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return netip.Addr{}, err
+	}
+	for {
+		h, err := p.AnswerHeader()
+		if err != nil {
+			return netip.Addr{}, fmt.Errorf("no A record for %s", host)
+		}
+		if h.Type != dnsmessage.TypeA {
+			if err := p.SkipAnswer(); err != nil {
+				return netip.Addr{}, err
+			}
+			continue
+		}
+		a, err := p.AResource()
+		if err != nil {
+			return netip.Addr{}, err
+		}
+		return netip.AddrFrom4(a.A), nil
+	}
+}
+
+// STUN 协议常量 (RFC 5389)
+const (
+	stunMagicCookie    = 0x2112A442
+	stunBindingRequest = 0x0001
+	stunBindingSuccess = 0x0101
+	stunAttrMapped     = 0x0001
+	stunAttrXORMapped  = 0x0020
+	stunHeaderLen      = 20
+	stunFamilyIPv4     = 0x01
+	stunFamilyIPv6     = 0x02
+)
+
+// stunBinding 发送 Binding 请求，返回映射地址
+func stunBinding(conn net.PacketConn, server netip.AddrPort, timeout time.Duration) (netip.AddrPort, error) {
+	req := make([]byte, stunHeaderLen)
+	binary.BigEndian.PutUint16(req[0:], stunBindingRequest)
+	binary.BigEndian.PutUint32(req[4:], stunMagicCookie)
+	_, _ = rand.Read(req[8:stunHeaderLen])
+	txID := req[8:stunHeaderLen]
+
+	resp, err := udpExchange(conn, server, req, timeout, func(b []byte) bool {
+		return len(b) >= stunHeaderLen && bytes.Equal(b[8:stunHeaderLen], txID)
+	})
+	if err != nil {
+		return netip.AddrPort{}, err
+	}
+	return parseSTUNResponse(resp)
+}
+
+// parseSTUNResponse 解析 Binding 响应中的 (XOR-)MAPPED-ADDRESS
+func parseSTUNResponse(b []byte) (netip.AddrPort, error) {
+	if len(b) < stunHeaderLen || binary.BigEndian.Uint16(b) != stunBindingSuccess {
+		return netip.AddrPort{}, errors.New("invalid stun response")
+	}
+	length := int(binary.BigEndian.Uint16(b[2:]))
+	attrs := b[stunHeaderLen:min(len(b), stunHeaderLen+length)]
+
+	var mapped netip.AddrPort
+	for len(attrs) >= 4 {
+		typ := binary.BigEndian.Uint16(attrs)
+		size := int(binary.BigEndian.Uint16(attrs[2:]))
+		if len(attrs) < 4+size {
+			break
+		}
+		val := attrs[4 : 4+size]
+		switch typ {
+		case stunAttrXORMapped:
+			if addr, ok := decodeSTUNAddr(val, b[4:stunHeaderLen]); ok {
+				return addr, nil
+			}
+		case stunAttrMapped:
+			if addr, ok := decodeSTUNAddr(val, nil); ok {
+				mapped = addr
+			}
+		}
+		// 属性按 4 字节对齐
+		attrs = attrs[min(len(attrs), 4+(size+3)&^3):]
+	}
+	if mapped.IsValid() {
+		return mapped, nil
+	}
+	return netip.AddrPort{}, errors.New("stun response without mapped address")
+}
+
+// decodeSTUNAddr 解码地址属性，xor 为 magic cookie + transaction ID，为 nil 时不做异或
+func decodeSTUNAddr(val, xor []byte) (netip.AddrPort, bool) {
+	if len(val) < 8 {
+		return netip.AddrPort{}, false
+	}
+	port := binary.BigEndian.Uint16(val[2:])
+	ip := bytes.Clone(val[4:])
+	if xor != nil {
+		port ^= uint16(stunMagicCookie >> 16)
+		for i := range ip {
+			ip[i] ^= xor[i]
+		}
+	}
+	switch {
+	case val[1] == stunFamilyIPv4 && len(ip) == 4:
+		return netip.AddrPortFrom(netip.AddrFrom4([4]byte(ip)), port), true
+	case val[1] == stunFamilyIPv6 && len(ip) == 16:
+		return netip.AddrPortFrom(netip.AddrFrom16([16]byte(ip)), port), true
+	}
+	return netip.AddrPort{}, false
+}
@@ -0,0 +1,125 @@
+package platform
+
+import (
+	"encoding/binary"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// serveUDP 本地 DNS + STUN 服务器：A 记录均解析为 127.0.0.1，STUN 返回请求来源地址
+func serveUDP(t *testing.T) netip.AddrPort {
+	t.Helper()
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, from, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			req := buf[:n]
+			var resp []byte
+			if n >= stunHeaderLen && binary.BigEndian.Uint32(req[4:]) == stunMagicCookie {
+				resp = stunReply(req, from.(*net.UDPAddr).AddrPort())
+			} else {
+				resp = dnsReply(req)
+			}
+			if resp != nil {
+				_, _ = conn.WriteTo(resp, from)
+			}
+		}
+	}()
+	return conn.LocalAddr().(*net.UDPAddr).AddrPort()
+}
+
+func dnsReply(req []byte) []byte {
+	var msg dnsmessage.Message
+	if err := msg.Unpack(req); err != nil || len(msg.Questions) == 0 {
+		return nil
+	}
+	msg.Response = true
+	msg.Answers = []dnsmessage.Resource{{
+		Header: dnsmessage.ResourceHeader{Name: msg.Questions[0].Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 60},
+		Body:   &dnsmessage.AResource{A: [4]byte{127, 0, 0, 1}},
+	}}
+	b, _ := msg.Pack()
+	return b
+}
+
+func stunReply(req []byte, from netip.AddrPort) []byte {
+	resp := make([]byte, stunHeaderLen+12)
+	binary.BigEndian.PutUint16(resp, stunBindingSuccess)
+	binary.BigEndian.PutUint16(resp[2:], 12)
+	copy(resp[4:stunHeaderLen], req[4:stunHeaderLen])
+
+	attr := resp[stunHeaderLen:]
+	binary.BigEndian.PutUint16(attr, stunAttrXORMapped)
+	binary.BigEndian.PutUint16(attr[2:], 8)
+	attr[5] = stunFamilyIPv4
+	binary.BigEndian.PutUint16(attr[6:], from.Port()^uint16(stunMagicCookie>>16))
+	ip := from.Addr().As4()
+	for i := range ip {
+		attr[8+i] = ip[i] ^ req[4+i]
+	}
+	return resp
+}
+
+func TestCheckUDP(t *testing.T) {
+	dns := serveUDP(t)
+	stun1, stun2 := serveUDP(t), serveUDP(t)
+
+	oldDNS, oldSTUN := UDPDNSServer, stunServers
+	defer func() { UDPDNSServer, stunServers = oldDNS, oldSTUN }()
+	UDPDNSServer = dns
+	stunServers = []struct {
+		Host string
+		Port uint16
+	}{{"stun1.example", stun1.Port()}, {"stun2.example", stun2.Port()}}
+
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	res, err := CheckUDP(conn, time.Second)
+	if err != nil {
+		t.Fatalf("CheckUDP() error = %v", err)
+	}
+	local := conn.LocalAddr().(*net.UDPAddr).AddrPort()
+	if !res.Supported || res.NATType != NATCone || res.Mapped != local {
+		t.Errorf("CheckUDP() = %+v, want cone mapped to %v", res, local)
+	}
+}
+
+func TestCheckUDPNoResponse(t *testing.T) {
+	// 不回复的 DNS 服务器
+	silent, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+
+	old := UDPDNSServer
+	defer func() { UDPDNSServer = old }()
+	UDPDNSServer = silent.LocalAddr().(*net.UDPAddr).AddrPort()
+
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if res, err := CheckUDP(conn, 100*time.Millisecond); err == nil || res.Supported {
+		t.Errorf("CheckUDP() = %+v, %v, want error", res, err)
+	}
+}
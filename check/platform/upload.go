@@ -0,0 +1,117 @@
+package platform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/metacubex/mihomo/common/convert"
+)
+
+// UploadOptions 上传测速参数
+type UploadOptions struct {
+	URL     string        // 接收上传数据的地址
+	Method  string        // POST 或 PUT，默认 POST
+	Timeout time.Duration // 上传超时
+	Bytes   uint64        // 上传数据量
+}
+
+// randomReader 循环输出一段随机数据，避免被链路压缩影响结果
+type randomReader struct {
+	block []byte
+	off   int
+}
+
+func newRandomReader() *randomReader {
+	block := make([]byte, 64*1024)
+	for i := range block {
+		block[i] = byte(rand.Uint32())
+	}
+	return &randomReader{block: block}
+}
+
+func (r *randomReader) Read(p []byte) (int, error) {
+	n := copy(p, r.block[r.off:])
+	r.off = (r.off + n) % len(r.block)
+	return n, nil
+}
+
+// CheckUpload 执行上传测速，返回速度(KB/s)和上传流量
+//
+// getNetBytes 返回连接层已写出的字节数，用于统计包含协议开销的真实流量；
+// 超时视为测速正常结束，按已上传的数据计算速度。
+func CheckUpload(httpClient *http.Client, opt UploadOptions, getNetBytes func() uint64) (int, int64, error) {
+	method := opt.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+
+	uploadClient := *httpClient
+	uploadClient.Timeout = 0
+
+	ctx, cancel := context.WithTimeout(context.Background(), opt.Timeout)
+	defer cancel()
+
+	size := int64(opt.Bytes)
+	body := &countingReader{r: io.LimitReader(newRandomReader(), size)}
+	req, err := http.NewRequestWithContext(ctx, method, opt.URL, body)
+	if err != nil {
+		return 0, 0, err
+	}
+	req.ContentLength = size
+	req.Header.Set("User-Agent", convert.RandUserAgent())
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	var startNetBytes uint64
+	if getNetBytes != nil {
+		startNetBytes = getNetBytes()
+	}
+	startTime := time.Now()
+
+	resp, err := uploadClient.Do(req)
+	if resp != nil {
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+		resp.Body.Close()
+	}
+	duration := max(time.Since(startTime).Seconds(), 0.1)
+
+	switch {
+	case err != nil && !errors.Is(err, context.DeadlineExceeded):
+		return 0, 0, err
+	case resp != nil && resp.StatusCode >= 400:
+		return 0, 0, &StatusError{Code: resp.StatusCode}
+	}
+
+	// 优先使用连接层流量，包含 TLS 等协议开销
+	totalBytes := body.n.Load()
+	if getNetBytes != nil {
+		if curr := getNetBytes(); curr > startNetBytes {
+			totalBytes = int64(curr - startNetBytes)
+		}
+	}
+	if totalBytes <= 0 {
+		return 0, 0, fmt.Errorf("no bytes transfer")
+	}
+
+	speed := int(float64(totalBytes) / 1024.0 / duration)
+	slog.Debug(fmt.Sprintf("上传测速完成: %d KB/s, 耗时: %.2fs, 流量: %d 字节", speed, duration, totalBytes))
+	return speed, totalBytes, nil
+}
+
+// countingReader 统计已读取的字节数，请求超时返回后发送协程可能仍在读取
+type countingReader struct {
+	r io.Reader
+	n atomic.Int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n.Add(int64(n))
+	return n, err
+}
@@ -0,0 +1,37 @@
+package platform
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCheckUpload(t *testing.T) {
+	var received atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		n, _ := io.Copy(io.Discard, r.Body)
+		received.Add(n)
+	}))
+	defer srv.Close()
+
+	opt := UploadOptions{URL: srv.URL, Method: http.MethodPut, Timeout: 5 * time.Second, Bytes: 1 << 20}
+	speed, total, err := CheckUpload(srv.Client(), opt, nil)
+	if err != nil {
+		t.Fatalf("CheckUpload() error = %v", err)
+	}
+	if speed <= 0 || total != 1<<20 || received.Load() != 1<<20 {
+		t.Errorf("CheckUpload() = %d KB/s, %d bytes, server received %d", speed, total, received.Load())
+	}
+
+	opt.Method = http.MethodPost
+	if _, _, err := CheckUpload(srv.Client(), opt, nil); err == nil {
+		t.Error("CheckUpload() with rejected method should fail")
+	}
+}
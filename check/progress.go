@@ -6,13 +6,8 @@ import (
 	"strings"
 	"sync/atomic"
 	"time"
-
-	"github.com/sinspired/subs-check-pro/config"
 )
 
-// 用于 UI 显示当前阶段名称
-var currentStepName atomic.Value
-
 // ProgressWeight 不同检测阶段的进度权重
 type ProgressWeight struct {
 	alive float64
@@ -22,6 +17,8 @@ type ProgressWeight struct {
 
 // ProgressTracker 存储每个阶段的检测进度信息
 type ProgressTracker struct {
+	s *Session
+
 	totalJobs atomic.Int32 // 初始总任务数
 
 	// 已检测数量（执行完计数）
@@ -40,27 +37,23 @@ type ProgressTracker struct {
 	finalized atomic.Bool
 }
 
-// NewProgressTracker 初始化进度追踪器并重置外部原子变量。
-func NewProgressTracker(total int) *ProgressTracker {
-	pt := &ProgressTracker{}
+// newProgressTracker 初始化进度追踪器并重置会话进度。
+func (s *Session) newProgressTracker(total int) *ProgressTracker {
+	pt := &ProgressTracker{s: s}
 	if total > math.MaxInt32 {
 		total = math.MaxInt32
 	}
 	pt.totalJobs.Store(int32(total))
 	pt.currentStage.Store(0)
 
-	ProxyCount.Store(uint32(total))
-	Progress.Store(0)
-
-	// 初始化进度权重
-	progressWeight = getCheckWeight(speedON, mediaON)
+	s.stats.ProxyCount.Store(uint32(total))
+	s.stats.Progress.Store(0)
 
 	// 默认阶段名（根据配置）
-	mode := config.GlobalConfig.ProgressMode
-	if mode == "stage" {
-		currentStepName.Store("测活")
+	if s.opts.ProgressMode == "stage" {
+		s.stepName.Store("测活")
 	} else {
-		currentStepName.Store("进度")
+		s.stepName.Store("进度")
 	}
 
 	return pt
@@ -110,7 +103,7 @@ func (pt *ProgressTracker) CountMedia() {
 func (pt *ProgressTracker) FinishAliveStage() {
 	aliveSucc := int(pt.aliveSuccess.Load())
 	// 如果没有活节点，直接结束
-	if aliveSucc <= 0 && (speedON || mediaON) {
+	if aliveSucc <= 0 && (pt.s.speedON || pt.s.mediaON) {
 		pt.Finalize()
 		return
 	}
@@ -121,12 +114,12 @@ func (pt *ProgressTracker) FinishAliveStage() {
 
 // FinishSpeedStage 在所有速度测试完成后，将追踪器转换到媒体检测阶段。
 func (pt *ProgressTracker) FinishSpeedStage() {
-	if !mediaON {
+	if !pt.s.mediaON {
 		pt.refresh()
 		return
 	}
 	speedSucc := int(pt.speedSuccess.Load())
-	if speedON && speedSucc <= 0 {
+	if pt.s.speedON && speedSucc <= 0 {
 		pt.Finalize()
 		return
 	}
@@ -139,17 +132,17 @@ func (pt *ProgressTracker) FinishSpeedStage() {
 func (pt *ProgressTracker) Finalize() {
 	pt.finalized.Store(true)
 	// 强制设置为 100%
-	total := ProxyCount.Load()
+	total := pt.s.stats.ProxyCount.Load()
 	if total == 0 {
 		total = 1 // 防止除以0
 	}
-	Progress.Store(total)
+	pt.s.stats.Progress.Store(total)
 	pt.refresh()
 }
 
 // refresh 根据所选算法更新进度的统一入口。
 func (pt *ProgressTracker) refresh() {
-	switch config.GlobalConfig.ProgressMode {
+	switch pt.s.opts.ProgressMode {
 	case "stage":
 		pt.refreshStage()
 	default:
@@ -165,7 +158,7 @@ func (pt *ProgressTracker) refreshDynamic() {
 	realTotal := int(pt.totalJobs.Load())
 
 	// 关键逻辑修改：处理停止信号
-	if Successlimited.Load() || ForceClose.Load() {
+	if pt.s.stats.SuccessLimited.Load() || pt.s.stats.ForceClose.Load() {
 		aliveDone := int(pt.aliveDone.Load())
 		// 只有当至少跑了一部分时才切换，避免刚开始就除以0
 		if aliveDone > 0 {
@@ -207,7 +200,7 @@ func (pt *ProgressTracker) refreshDynamic() {
 
 	// 媒体检测的分母：如果有测速，则是测速成功数；否则是存活数
 	mediaBase := aliveSucc
-	if speedON {
+	if pt.s.speedON {
 		mediaBase = speedSucc
 	}
 	rMedia := 0.0
@@ -223,19 +216,19 @@ func (pt *ProgressTracker) refreshDynamic() {
 	// P_Total = (rAlive * wAlive) + (rSpeed * wSpeed * rAlive) + (rMedia * wMedia * rSpeed * rAlive)
 	// 这种级联乘法能保证进度条平滑，不会因为后一阶段任务量少而瞬间跳变。
 
-	pAlive := rAlive * progressWeight.alive
+	pAlive := rAlive * pt.s.weight.alive
 	pSpeed := 0.0
 	pMedia := 0.0
 
-	if speedON {
-		pSpeed = rSpeed * progressWeight.speed * rAlive
-		if mediaON {
-			pMedia = rMedia * progressWeight.media * rSpeed * rAlive
+	if pt.s.speedON {
+		pSpeed = rSpeed * pt.s.weight.speed * rAlive
+		if pt.s.mediaON {
+			pMedia = rMedia * pt.s.weight.media * rSpeed * rAlive
 		}
 	} else {
 		// 没测速，媒体检测直接受限于测活
-		if mediaON {
-			pMedia = rMedia * progressWeight.media * rAlive
+		if pt.s.mediaON {
+			pMedia = rMedia * pt.s.weight.media * rAlive
 		}
 	}
 
@@ -248,13 +241,13 @@ func (pt *ProgressTracker) refreshDynamic() {
 
 	// 为了兼容 GUI/CLI 显示，我们将百分比映射回 realTotal
 	// ProxyCount 存储当前的“有效总数”
-	ProxyCount.Store(uint32(realTotal))
+	pt.s.stats.ProxyCount.Store(uint32(realTotal))
 
 	mappedProgress := uint32(math.Ceil(finalPercent / 100.0 * float64(realTotal)))
 	if mappedProgress > uint32(realTotal) {
 		mappedProgress = uint32(realTotal)
 	}
-	Progress.Store(mappedProgress)
+	pt.s.stats.Progress.Store(mappedProgress)
 }
 
 // refreshStage 修复后的分阶段算法：分母动态切换
@@ -262,16 +255,16 @@ func (pt *ProgressTracker) refreshStage() {
 	stage := int(pt.currentStage.Load())
 
 	// 处理停止信号下的显示文字
-	if Successlimited.Load() {
-		currentStepName.Store("收尾")
+	if pt.s.stats.SuccessLimited.Load() {
+		pt.s.stepName.Store("收尾")
 	}
 
 	switch stage {
 	case 0: // 存活检测阶段
-		currentStepName.Store("测活")
+		pt.s.stepName.Store("测活")
 		total := uint32(pt.totalJobs.Load())
 		// 如果在测活阶段就停止了（比如强制停止），修正总数显示
-		if ForceClose.Load() || Successlimited.Load() {
+		if pt.s.stats.ForceClose.Load() || pt.s.stats.SuccessLimited.Load() {
 			done := uint32(pt.aliveDone.Load())
 			if done > 0 {
 				total = done
@@ -283,16 +276,16 @@ func (pt *ProgressTracker) refreshStage() {
 			done = total
 		}
 
-		ProxyCount.Store(total)
-		Progress.Store(done)
+		pt.s.stats.ProxyCount.Store(total)
+		pt.s.stats.Progress.Store(done)
 
 	case 1: // 测速阶段
-		currentStepName.Store("测速")
+		pt.s.stepName.Store("测速")
 		// 分母：上一阶段(Alive)的成功数
 		total := uint32(pt.aliveSuccess.Load())
 		if total == 0 {
-			ProxyCount.Store(0)
-			Progress.Store(0)
+			pt.s.stats.ProxyCount.Store(0)
+			pt.s.stats.Progress.Store(0)
 			return
 		}
 
@@ -301,22 +294,22 @@ func (pt *ProgressTracker) refreshStage() {
 			done = total
 		}
 
-		ProxyCount.Store(total)
-		Progress.Store(done)
+		pt.s.stats.ProxyCount.Store(total)
+		pt.s.stats.Progress.Store(done)
 
 	case 2: // 媒体检测阶段
-		currentStepName.Store("媒体")
+		pt.s.stepName.Store("媒体")
 		// 分母：上一阶段的成功数
 		var base int32
-		if speedON {
+		if pt.s.speedON {
 			base = pt.speedSuccess.Load()
 		} else {
 			base = pt.aliveSuccess.Load()
 		}
 		total := uint32(base)
 		if total == 0 {
-			ProxyCount.Store(0)
-			Progress.Store(0)
+			pt.s.stats.ProxyCount.Store(0)
+			pt.s.stats.Progress.Store(0)
 			return
 		}
 
@@ -325,8 +318,8 @@ func (pt *ProgressTracker) refreshStage() {
 			done = total
 		}
 
-		ProxyCount.Store(total)
-		Progress.Store(done)
+		pt.s.stats.ProxyCount.Store(total)
+		pt.s.stats.Progress.Store(done)
 	}
 }
 
@@ -349,20 +342,20 @@ func (pc *ProxyChecker) showProgress(done <-chan struct{}) {
 
 // renderProgressString 计算并格式化进度条字符串。
 func (pc *ProxyChecker) renderProgressString() string {
-	currentChecked := int(Progress.Load())
-	total := int(ProxyCount.Load())
+	currentChecked := int(pc.stats.Progress.Load())
+	total := int(pc.stats.ProxyCount.Load())
 	available := pc.available.Load()
-	etaSec := ETASeconds.Load()
+	etaSec := pc.stats.ETASeconds.Load()
 	step := ""
 
 	// 获取阶段名称
-	if s, ok := currentStepName.Load().(string); ok {
+	if s, ok := pc.stepName.Load().(string); ok {
 		step = s
 	}
 
 	var percent float64
 	if total == 0 {
-		if ProcessResults.Load() {
+		if pc.stats.ProcessResults.Load() {
 			percent = 100
 		}
 	} else {
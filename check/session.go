@@ -0,0 +1,415 @@
+package check
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/juju/ratelimit"
+	"github.com/sinspired/subs-check-pro/check/platform"
+	"github.com/sinspired/subs-check-pro/config"
+)
+
+// Options 检测参数，字段含义与配置文件同名字段一致
+type Options struct {
+	Concurrent      int
+	AliveConcurrent int
+	SpeedConcurrent int
+	MediaConcurrent int
+	Timeout         int // 单次请求超时(ms)
+	EnableIPv6      bool
+	Threshold       float64 // 乱序时视为相同网段的相似度阈值
+
+	AdaptiveConcurrency   bool // 运行时动态调整各阶段并发
+	AdaptiveMinConcurrent int
+	AdaptiveMaxConcurrent int // 0 为初始并发的 2 倍
+
+	AliveTargets      []string
+	AliveQuorum       int
+	AliveRetries      int
+	AliveRetryBackoff int // 毫秒
+
+	IntegrityCheck    bool
+	DropHijackedNodes bool
+
+	UDPCheck   bool
+	RequireUDP bool
+
+	SpeedTestURL    string // 为空时不测速
+	MinSpeed        int    // KB/s
+	DownloadTimeout int    // 秒
+	DownloadMB      int
+	TotalSpeedLimit int // MB/s，0 为不限速
+	SpeedStreams    int
+	SpeedSamples    int
+
+	UploadTestURL  string // 为空时不测上传
+	UploadMethod   string
+	UploadMB       int
+	MinUploadSpeed int // KB/s
+
+	TrafficBudgetPerRun   int // MB，0 为不限
+	TrafficBudgetPerMonth int // GB，0 为不限
+
+	ExitDedup     string // off、keep-best、keep-n
+	ExitDedupBy   string // ip、subnet、asn
+	ExitDedupKeep int    // keep-n 模式每个出口保留的节点数量
+	ASNDBPath     string
+
+	ExitCache            bool           // 按出口缓存媒体和地理位置检测结果
+	ExitCacheTTL         int            // 分钟
+	ExitCachePlatformTTL map[string]int // 分钟，按检测项覆盖 ExitCacheTTL
+
+	LatencySamples int
+	MaxLatency     int
+
+	MediaCheck     bool
+	Platforms      []string
+	CustomProbes   []config.CustomProbe
+	DropBadCfNodes bool
+	ISPCheck       bool
+	MaxMindDBPath  string
+
+	ISPOnlineFallback bool // 离线无法识别时在线查询
+	ASNRulesPath      string
+
+	IPRiskSource         string // offline、scamalytics
+	IPRiskLists          []config.IPRiskList
+	IPRiskUpdateInterval int // 小时
+
+	SuccessLimit int32
+	RenameNode   bool
+	NodePrefix   string
+
+	PrintProgress bool
+	ProgressMode  string
+
+	NodeHealth          bool
+	NodeHealthRetention int
+	CheckTrace          bool
+	CheckpointInterval  int // 秒，<=0 为关闭断点
+
+	Distributed config.DistributedConfig
+
+	// Report 检测结束后生成分析报告，并删除 sub_url 等节点元数据
+	Report             bool
+	SuccessRate        float64
+	KeepSuccessProxies bool
+}
+
+// OptionsFromConfig 根据配置文件生成检测参数
+func OptionsFromConfig(cfg *config.Config) Options {
+	return Options{
+		Concurrent:      cfg.Concurrent,
+		AliveConcurrent: cfg.AliveConcurrent,
+		SpeedConcurrent: cfg.SpeedConcurrent,
+		MediaConcurrent: cfg.MediaConcurrent,
+		Timeout:         cfg.Timeout,
+		EnableIPv6:      cfg.EnableIPv6,
+		Threshold:       float64(cfg.Threshold),
+
+		AdaptiveConcurrency:   cfg.AdaptiveConcurrency,
+		AdaptiveMinConcurrent: cfg.AdaptiveMinConcurrent,
+		AdaptiveMaxConcurrent: cfg.AdaptiveMaxConcurrent,
+
+		AliveTargets:      slices.Clone(cfg.AliveTargets),
+		AliveQuorum:       cfg.AliveQuorum,
+		AliveRetries:      cfg.AliveRetries,
+		AliveRetryBackoff: cfg.AliveRetryBackoff,
+
+		IntegrityCheck:    cfg.IntegrityCheck,
+		DropHijackedNodes: cfg.DropHijackedNodes,
+
+		UDPCheck:   cfg.UDPCheck,
+		RequireUDP: cfg.RequireUDP,
+
+		SpeedTestURL:    cfg.SpeedTestURL,
+		MinSpeed:        cfg.MinSpeed,
+		DownloadTimeout: cfg.DownloadTimeout,
+		DownloadMB:      cfg.DownloadMB,
+		TotalSpeedLimit: cfg.TotalSpeedLimit,
+		SpeedStreams:    cfg.SpeedStreams,
+		SpeedSamples:    cfg.SpeedSamples,
+
+		UploadTestURL:  cfg.UploadTestURL,
+		UploadMethod:   cfg.UploadMethod,
+		UploadMB:       cfg.UploadMB,
+		MinUploadSpeed: cfg.MinUploadSpeed,
+
+		TrafficBudgetPerRun:   cfg.TrafficBudgetPerRun,
+		TrafficBudgetPerMonth: cfg.TrafficBudgetPerMonth,
+
+		ExitDedup:     cfg.ExitDedup,
+		ExitDedupBy:   cfg.ExitDedupBy,
+		ExitDedupKeep: cfg.ExitDedupKeep,
+		ASNDBPath:     cfg.ASNDBPath,
+
+		ExitCache:            cfg.ExitCache,
+		ExitCacheTTL:         cfg.ExitCacheTTL,
+		ExitCachePlatformTTL: maps.Clone(cfg.ExitCachePlatformTTL),
+
+		LatencySamples: cfg.LatencySamples,
+		MaxLatency:     cfg.MaxLatency,
+
+		MediaCheck:     cfg.MediaCheck,
+		Platforms:      slices.Clone(cfg.Platforms),
+		CustomProbes:   slices.Clone(cfg.CustomProbes),
+		DropBadCfNodes: cfg.DropBadCfNodes,
+		ISPCheck:       cfg.ISPCheck,
+		MaxMindDBPath:  cfg.MaxMindDBPath,
+
+		ISPOnlineFallback: cfg.ISPOnlineFallback,
+		ASNRulesPath:      cfg.ASNRulesPath,
+
+		IPRiskSource:         cfg.IPRiskSource,
+		IPRiskLists:          slices.Clone(cfg.IPRiskLists),
+		IPRiskUpdateInterval: cfg.IPRiskUpdateInterval,
+
+		SuccessLimit: cfg.SuccessLimit,
+		RenameNode:   cfg.RenameNode,
+		NodePrefix:   cfg.NodePrefix,
+
+		PrintProgress: cfg.PrintProgress,
+		ProgressMode:  cfg.ProgressMode,
+
+		NodeHealth:          cfg.NodeHealth,
+		NodeHealthRetention: cfg.NodeHealthRetention,
+		CheckTrace:          cfg.CheckTrace,
+		CheckpointInterval:  cfg.CheckpointInterval,
+
+		Distributed: cfg.Distributed,
+
+		Report:             true,
+		SuccessRate:        cfg.SuccessRate,
+		KeepSuccessProxies: cfg.KeepSuccessProxies,
+	}
+}
+
+// Stats 检测进度和流量统计，检测过程中可并发读取
+type Stats struct {
+	Progress   atomic.Uint32 // 已检测数量（语义见进度算法）
+	Available  atomic.Uint32 // 已可用数量
+	ProxyCount atomic.Uint32 // 总数（动态=总节点；分阶段=当前阶段规模）
+	ETASeconds atomic.Int64  // -1=计算中, 0=空闲/完成, >0=剩余秒数
+
+	TotalBytes atomic.Uint64 // 本轮消耗流量
+	UP         atomic.Uint64 // 累计上行，不随检测重置
+	DOWN       atomic.Uint64 // 累计下行，不随检测重置
+
+	ForceClose     atomic.Bool // 置为 true 时结束检测并收集已有结果
+	SuccessLimited atomic.Bool // 已达到成功节点数量限制
+	ProcessResults atomic.Bool // 检测完成，正在处理结果
+
+	// 各阶段当前并发数
+	AliveConcurrent atomic.Int32
+	SpeedConcurrent atomic.Int32
+	MediaConcurrent atomic.Int32
+	Adaptive        atomic.Bool // 是否启用动态并发
+
+	// 以下字段在检测开始和结束时写入
+	StartTime time.Time
+	EndTime   time.Time
+	Duration  time.Duration
+	Traffic   string
+}
+
+// reset 重置本轮统计，保留累计流量
+func (st *Stats) reset() {
+	st.Progress.Store(0)
+	st.Available.Store(0)
+	st.ProxyCount.Store(0)
+	st.ETASeconds.Store(0)
+	st.TotalBytes.Store(0)
+	st.ForceClose.Store(false)
+	st.SuccessLimited.Store(false)
+	st.ProcessResults.Store(false)
+}
+
+// ConcurrencyInfo 各阶段当前并发数
+type ConcurrencyInfo struct {
+	Adaptive bool  `json:"adaptive"`
+	Alive    int32 `json:"alive"`
+	Speed    int32 `json:"speed"`
+	Media    int32 `json:"media"`
+}
+
+// Concurrency 返回各阶段当前并发数
+func (st *Stats) Concurrency() ConcurrencyInfo {
+	return ConcurrencyInfo{
+		Adaptive: st.Adaptive.Load(),
+		Alive:    st.AliveConcurrent.Load(),
+		Speed:    st.SpeedConcurrent.Load(),
+		Media:    st.MediaConcurrent.Load(),
+	}
+}
+
+// Session 一次检测会话，持有独立的参数、进度和流量统计
+//
+// 同一进程中可以同时运行多个会话，互不影响。
+type Session struct {
+	opts  Options
+	stats *Stats
+	eta   etaTracker
+
+	aliveTargets []platform.AliveTarget
+	aliveQuorum  int                      // 至少需要通过的测活目标数量
+	speedHealth  *platform.SpeedURLHealth // 本轮检测的测速地址健康状况
+
+	bucket    *ratelimit.Bucket
+	speedON   bool
+	mediaON   bool
+	latencyON bool
+	udpON     bool
+	uploadON  bool
+	platforms []string                    // 启用的检测平台：platforms + custom-probes
+	probes    map[string]platform.Checker // 本会话的自定义探测，按小写名称索引
+	weight    ProgressWeight
+	mediaTags *regexp.Regexp // 节点名称中的旧平台标签
+	stepName  atomic.Value   // 控制台进度条显示的阶段名称
+}
+
+// NewSession 创建检测会话
+func NewSession(opts Options) *Session {
+	return newSession(opts, &Stats{})
+}
+
+// newSession 创建检测会话，统计写入 stats
+func newSession(opts Options, stats *Stats) *Session {
+	stats.reset()
+	s := &Session{
+		opts:      opts,
+		stats:     stats,
+		speedON:   opts.SpeedTestURL != "",
+		mediaON:   opts.MediaCheck,
+		latencyON: opts.LatencySamples > 0 || opts.MaxLatency > 0,
+		udpON:     opts.UDPCheck || opts.RequireUDP,
+		uploadON:  opts.SpeedTestURL != "" && opts.UploadTestURL != "",
+		mediaTags: buildMediaTagRegex(),
+
+		speedHealth: platform.NewSpeedURLHealth(),
+	}
+
+	if s.mediaON {
+		probes := platform.NewProbes(opts.CustomProbes)
+		s.probes = make(map[string]platform.Checker, len(probes))
+		for _, c := range probes {
+			s.probes[strings.ToLower(c.Name())] = c
+		}
+		for _, plat := range opts.Platforms {
+			if _, ok := s.lookup(plat); !ok && plat != "iprisk" {
+				slog.Warn(fmt.Sprintf("未知的检测平台: %s", plat))
+			}
+		}
+		s.platforms = slices.Clone(opts.Platforms)
+		for _, c := range probes {
+			if !slices.Contains(s.platforms, c.Name()) {
+				s.platforms = append(s.platforms, c.Name())
+			}
+		}
+		// 包含自定义探测的标签，确保能清理
+		s.mediaTags = buildMediaTagRegex(probes...)
+	}
+
+	s.aliveTargets, s.aliveQuorum = parseAliveTargets(opts.AliveTargets, opts.AliveQuorum)
+
+	// 限速设置
+	if limit := opts.TotalSpeedLimit; limit > 0 {
+		rate := float64(limit * 1024 * 1024)
+		s.bucket = ratelimit.NewBucketWithRate(rate, int64(rate/10))
+	} else {
+		s.bucket = ratelimit.NewBucketWithRate(float64(math.MaxInt64), int64(math.MaxInt64))
+	}
+
+	s.weight = getCheckWeight(s.speedON, s.mediaON)
+	s.resetETA()
+	return s
+}
+
+// lookup 按名称查找检测器，优先使用本会话的自定义探测
+func (s *Session) lookup(name string) (platform.Checker, bool) {
+	if c, ok := s.probes[strings.ToLower(name)]; ok {
+		return c, true
+	}
+	return platform.Lookup(name)
+}
+
+// parseAliveTargets 解析测活目标，跳过无效项并将通过数量限制在 [1, 目标数量]
+func parseAliveTargets(list []string, quorum int) ([]platform.AliveTarget, int) {
+	var targets []platform.AliveTarget
+	for _, item := range list {
+		t, err := platform.ParseAliveTarget(item)
+		if err != nil {
+			slog.Warn(err.Error())
+			continue
+		}
+		if !slices.ContainsFunc(targets, func(o platform.AliveTarget) bool { return o.URL == t.URL }) {
+			targets = append(targets, t)
+		}
+	}
+	if len(targets) == 0 {
+		targets = []platform.AliveTarget{platform.DefaultAliveTarget}
+	}
+	if quorum > len(targets) {
+		slog.Warn(fmt.Sprintf("alive-quorum %d 超过测活目标数量 %d，按 %d 计算", quorum, len(targets), len(targets)))
+	}
+	return targets, min(max(quorum, 1), len(targets))
+}
+
+// Stats 返回会话的进度和流量统计
+func (s *Session) Stats() *Stats {
+	return s.stats
+}
+
+// Run 检测节点，返回可用结果
+//
+// ctx 取消或 Stats().ForceClose 置位时结束检测，返回已收集的结果。
+func (s *Session) Run(ctx context.Context, proxies []map[string]any) ([]Result, error) {
+	if len(proxies) == 0 {
+		return nil, nil
+	}
+	pc := s.newChecker(len(proxies))
+	pc.openHealthDB()
+	return pc.run(ctx, proxies)
+}
+
+// done 检测是否应当结束：ctx 已取消或收到强制关闭信号
+func (s *Session) done(ctx context.Context) bool {
+	if s.stats.ForceClose.Load() {
+		return true
+	}
+	select {
+	case <-ctx.Done():
+		return true
+	default:
+		return false
+	}
+}
+
+// speedOptions 测速参数
+func (s *Session) speedOptions() platform.SpeedOptions {
+	return platform.SpeedOptions{
+		URL:        s.opts.SpeedTestURL,
+		Timeout:    time.Duration(s.opts.DownloadTimeout) * time.Second,
+		LimitBytes: uint64(max(s.opts.DownloadMB, 0)) * 1024 * 1024,
+		Streams:    s.opts.SpeedStreams,
+		Samples:    s.opts.SpeedSamples,
+		Health:     s.speedHealth,
+	}
+}
+
+// uploadOptions 上传测速参数
+func (s *Session) uploadOptions() platform.UploadOptions {
+	return platform.UploadOptions{
+		URL:     s.opts.UploadTestURL,
+		Method:  s.opts.UploadMethod,
+		Timeout: time.Duration(s.opts.DownloadTimeout) * time.Second,
+		Bytes:   uint64(max(s.opts.UploadMB, 1)) * 1024 * 1024,
+	}
+}
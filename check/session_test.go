@@ -0,0 +1,69 @@
+package check
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/sinspired/subs-check-pro/config"
+)
+
+func TestSessionRunIndependent(t *testing.T) {
+	opts := Options{Concurrent: 4, Timeout: 1000}
+	proxies := func() []map[string]any {
+		// 无法解析的节点在创建客户端阶段失败，无需网络
+		return []map[string]any{
+			{"name": "a", "type": "unknown", "server": "127.0.0.1", "port": 1},
+			{"name": "b", "type": "unknown", "server": "127.0.0.1", "port": 2},
+			{"name": "c", "type": "unknown", "server": "127.0.0.1", "port": 3},
+		}
+	}
+
+	Progress.Store(42)
+	sessions := []*Session{NewSession(opts), NewSession(opts)}
+	var wg sync.WaitGroup
+	for _, s := range sessions {
+		wg.Go(func() {
+			results, err := s.Run(context.Background(), proxies())
+			if err != nil || len(results) != 0 {
+				t.Errorf("Run() = %v, %v", results, err)
+			}
+		})
+	}
+	wg.Wait()
+
+	for i, s := range sessions {
+		st := s.Stats()
+		if got := st.Progress.Load(); got != 3 {
+			t.Errorf("session %d progress = %d, want 3", i, got)
+		}
+		if !st.ProcessResults.Load() {
+			t.Errorf("session %d not finished", i)
+		}
+	}
+	if got := Progress.Load(); got != 42 {
+		t.Errorf("global progress changed to %d", got)
+	}
+}
+
+func TestSessionProbesIndependent(t *testing.T) {
+	a := NewSession(Options{MediaCheck: true, CustomProbes: []config.CustomProbe{
+		{Name: "probe-a", URL: "https://example.com", Tag: "PA"},
+	}})
+	b := NewSession(Options{MediaCheck: true, CustomProbes: []config.CustomProbe{
+		{Name: "probe-b", URL: "https://example.com", Tag: "PB"},
+	}})
+
+	if _, ok := a.lookup("probe-a"); !ok {
+		t.Error("会话 a 缺少自身的探测")
+	}
+	if _, ok := a.lookup("probe-b"); ok {
+		t.Error("会话 a 看到了会话 b 的探测")
+	}
+	if c, ok := b.lookup("probe-b"); !ok || c.Tag() != "PB" {
+		t.Errorf("会话 b 探测 = %v", c)
+	}
+	if _, ok := a.lookup("netflix"); !ok {
+		t.Error("内置检测器不可用")
+	}
+}
@@ -0,0 +1,212 @@
+package check
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/sinspired/subs-check-pro/assets"
+	"github.com/sinspired/subs-check-pro/check/platform"
+	"github.com/sinspired/subs-check-pro/config"
+	proxyutils "github.com/sinspired/subs-check-pro/proxy"
+)
+
+// NodeResult 单节点检测结果
+type NodeResult struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Server string `json:"server"`
+	Passed bool   `json:"passed"`
+	Stage  string `json:"stage,omitempty"`  // 失败阶段
+	Reason string `json:"reason,omitempty"` // 失败原因
+	Error  string `json:"error,omitempty"`
+
+	Latency      int                                `json:"latency,omitempty"`
+	LatencyMin   int                                `json:"latencyMin,omitempty"`
+	Jitter       int                                `json:"jitter,omitempty"`
+	Handshake    int                                `json:"handshake,omitempty"`
+	AliveTargets []string                           `json:"aliveTargets,omitempty"`
+	Speed        int                                `json:"speed,omitempty"` // KB/s
+	UploadSpeed  int                                `json:"uploadSpeed,omitempty"`
+	PeakSpeed    int                                `json:"peakSpeed,omitempty"`
+	TTFB         int                                `json:"ttfb,omitempty"`
+	CFAccessible bool                               `json:"cfAccessible"`
+	Integrity    string                             `json:"integrity,omitempty"`
+	UDP          bool                               `json:"udp"`
+	NATType      string                             `json:"natType,omitempty"`
+	IP           string                             `json:"ip,omitempty"`
+	IPRisk       string                             `json:"ipRisk,omitempty"`
+	Country      string                             `json:"country,omitempty"`
+	CountryTag   string                             `json:"countryTag,omitempty"`
+	Platforms    map[string]platform.PlatformResult `json:"platforms,omitempty"`
+
+	Traffic uint64         `json:"traffic"` // 消耗流量(字节)
+	Cost    int64          `json:"ms"`      // 总耗时
+	Steps   []TraceStep    `json:"steps"`
+	Proxy   map[string]any `json:"proxy"`
+}
+
+// CheckNode 使用配置文件的检测参数检测单个节点
+//
+// 使用独立的会话，不影响 Check() 的进度和统计，可与定时检测同时运行。
+func CheckNode(ctx context.Context, mapping map[string]any) *NodeResult {
+	return NewSession(OptionsFromConfig(config.GlobalConfig)).CheckNode(ctx, mapping)
+}
+
+// CheckNode 按检测流水线的各阶段检测单个节点
+func (s *Session) CheckNode(ctx context.Context, mapping map[string]any) *NodeResult {
+	begin := time.Now()
+	job := &ProxyJob{Result: Result{Proxy: mapping}}
+	res := &NodeResult{Proxy: mapping}
+	res.Name, _ = mapping["name"].(string)
+	res.Type, _ = mapping["type"].(string)
+	if v, ok := mapping["server"]; ok {
+		res.Server = fmt.Sprint(v)
+	}
+	defer func() {
+		res.Steps = job.Trail
+		res.Cost = time.Since(begin).Milliseconds()
+		if n := len(job.Trail); n > 0 && !job.Trail[n-1].OK {
+			last := job.Trail[n-1]
+			res.Stage, res.Reason, res.Error = last.Stage, last.Reason, last.Error
+		}
+	}()
+
+	start := time.Now()
+	cli, err := s.newClient(mapping)
+	if err != nil {
+		job.trace(StageParse, start, ReasonParse, err)
+		return res
+	}
+	job.Client = cli
+	defer func() {
+		res.Traffic = cli.Transport.BytesRead.Load() + cli.Transport.BytesWritten.Load()
+		cli.Close()
+	}()
+
+	// 测活
+	start = time.Now()
+	err = s.checkAlive(ctx, job)
+	res.AliveTargets = job.Result.AliveTargets
+	if err != nil {
+		job.trace(StageAlive, start, classifyError(err), err)
+		return res
+	}
+	job.trace(StageAlive, start, "", nil)
+
+	// 延迟
+	if s.latencyON {
+		start = time.Now()
+		reason, err := s.checkLatency(job)
+		res.Latency = job.Result.Latency
+		res.LatencyMin = job.Result.LatencyMin
+		res.Jitter = job.Result.Jitter
+		res.Handshake = job.Result.Handshake
+		if reason != "" {
+			job.trace(StageLatency, start, reason, err)
+			return res
+		}
+		job.trace(StageLatency, start, "", nil)
+	}
+	if ctx.Err() != nil {
+		return res
+	}
+
+	// 流量劫持，单节点检测始终执行
+	start = time.Now()
+	verdict, err := s.checkIntegrity(job)
+	res.Integrity = verdict
+	if verdict != "" && s.opts.DropHijackedNodes {
+		job.trace(StageIntegrity, start, verdict, err)
+		return res
+	}
+	job.trace(StageIntegrity, start, "", err)
+
+	// UDP，单节点检测始终执行
+	start = time.Now()
+	err = s.checkUDP(ctx, job)
+	res.UDP, res.NATType = job.Result.UDP, job.Result.NATType
+	if !res.UDP && s.opts.RequireUDP {
+		job.trace(StageUDP, start, ReasonNoUDP, err)
+		return res
+	}
+	job.trace(StageUDP, start, "", err)
+
+	// CF 检测，单节点检测始终执行，便于排查
+	job.NeedCF = true
+	start = time.Now()
+	job.IsCfAccessible, job.CfLoc, job.CfIP = platform.CheckCloudflare(job.Client.Client)
+	res.CFAccessible = job.IsCfAccessible
+	if s.opts.DropBadCfNodes && !job.IsCfAccessible {
+		job.trace(StageCF, start, ReasonCFBlocked, nil)
+		return res
+	}
+	job.trace(StageCF, start, "", nil)
+
+	// 测速
+	if s.speedON && ctx.Err() == nil {
+		start = time.Now()
+		getBytes := func() uint64 { return job.Client.Transport.BytesRead.Load() }
+		sr, err := platform.CheckSpeed(job.Client.Client, s.speedOptions(), getBytes)
+		speed := sr.Speed
+		res.Speed, res.PeakSpeed, res.TTFB = sr.Speed, sr.Peak, sr.TTFB
+		switch {
+		case err != nil:
+			job.trace(StageSpeed, start, classifyError(err), err)
+			return res
+		case speed < s.opts.MinSpeed:
+			job.trace(StageSpeed, start, ReasonTooSlow,
+				fmt.Errorf("%d KB/s < %d KB/s", speed, s.opts.MinSpeed))
+			return res
+		}
+		job.Speed = speed
+		job.trace(StageSpeed, start, "", nil)
+
+		if s.uploadON {
+			start = time.Now()
+			reason, err := s.checkUpload(job)
+			res.UploadSpeed = job.Result.UploadSpeed
+			if reason != "" {
+				job.trace(StageUpload, start, reason, err)
+				return res
+			}
+			job.trace(StageUpload, start, "", err)
+		}
+	}
+
+	geoDB, err := assets.OpenMaxMindDB(s.opts.MaxMindDBPath)
+	if err != nil {
+		slog.Debug(fmt.Sprintf("打开 MaxMind 数据库失败: %v", err))
+		geoDB = nil
+	}
+	if geoDB != nil {
+		defer geoDB.Close()
+	}
+
+	// 流媒体
+	if s.mediaON && ctx.Err() == nil {
+		if riskEngine.Load() == nil {
+			s.loadIPRisk()
+		}
+		start = time.Now()
+		for _, plat := range s.platforms {
+			s.mediaCheck(job, plat, geoDB, ctx)
+		}
+		job.trace(StageMedia, start, "", nil)
+	}
+
+	// 地理位置
+	if job.Result.Country == "" && ctx.Err() == nil {
+		job.Result.Country, job.Result.IP, job.Result.CountryCodeTag, _ =
+			proxyutils.GetProxyCountry(job.Client.Client, geoDB, ctx, job.CfLoc, job.CfIP)
+	}
+
+	res.Passed = true
+	res.IP = job.Result.IP
+	res.IPRisk = job.Result.IPRisk
+	res.Country = job.Result.Country
+	res.CountryTag = job.Result.CountryCodeTag
+	res.Platforms = job.Result.Platforms
+	return res
+}
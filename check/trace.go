@@ -0,0 +1,395 @@
+package check
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sinspired/subs-check-pro/check/platform"
+	"github.com/sinspired/subs-check-pro/save/method"
+)
+
+// TraceFileName 检测轨迹文件名，保存在 output/stats 目录
+const TraceFileName = "check-trace.jsonl"
+
+// 检测阶段
+const (
+	StageParse     = "parse"
+	StageAlive     = "alive"
+	StageLatency   = "latency"
+	StageIntegrity = "integrity"
+	StageUDP       = "udp"
+	StageCF        = "cf"
+	StageSpeed     = "speed"
+	StageUpload    = "upload"
+	StageMedia     = "media"
+)
+
+// 失败原因分类
+const (
+	ReasonParse       = "parse_error"
+	ReasonDialTimeout = "dial_timeout"
+	ReasonTimeout     = "timeout"
+	ReasonRefused     = "conn_refused"
+	ReasonReset       = "conn_reset"
+	ReasonDNS         = "dns_error"
+	ReasonTLS         = "tls_error"
+	ReasonEOF         = "eof"
+	ReasonHTTPStatus  = "http_status"
+	ReasonHighLatency = "high_latency"
+	ReasonTooSlow     = "too_slow"
+	ReasonCFBlocked   = "cf_blocked"
+	ReasonMITM        = platform.IntegrityMITM
+	ReasonInjected    = platform.IntegrityInjected
+	ReasonRedirected  = platform.IntegrityRedirected
+	ReasonNoUDP       = "no_udp"
+	ReasonCancelled   = "cancelled" // 手动结束、流量预算用完或达到成功数量限制，未完成检测
+	ReasonUnknown     = "unknown"
+)
+
+// TraceStep 单个阶段的检测结果
+type TraceStep struct {
+	Stage  string `json:"stage"`
+	OK     bool   `json:"ok"`
+	Reason string `json:"reason,omitempty"`
+	Error  string `json:"error,omitempty"`
+	Cost   int64  `json:"ms"`
+}
+
+// TraceRecord 单个节点的完整检测轨迹，对应 check-trace.jsonl 中的一行
+type TraceRecord struct {
+	Time   time.Time   `json:"time"`
+	Name   string      `json:"name"`
+	Type   string      `json:"type"`
+	Server string      `json:"server"`
+	SubURL string      `json:"sub_url,omitempty"`
+	Passed bool        `json:"passed"`
+	Stage  string      `json:"stage"`
+	Reason string      `json:"reason,omitempty"`
+	Steps  []TraceStep `json:"steps"`
+}
+
+// trace 记录一个阶段的检测结果，reason 为空表示通过
+// 同一 job 在各阶段串行处理，无需加锁
+func (j *ProxyJob) trace(stage string, start time.Time, reason string, err error) {
+	step := TraceStep{
+		Stage:  stage,
+		OK:     reason == "",
+		Reason: reason,
+		Cost:   time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		step.Error = err.Error()
+	}
+	j.Trail = append(j.Trail, step)
+}
+
+// classifyError 将检测错误归类为失败原因
+func classifyError(err error) string {
+	if err == nil {
+		return ReasonUnknown
+	}
+
+	var statusErr *platform.StatusError
+	if errors.As(err, &statusErr) {
+		return ReasonHTTPStatus
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return ReasonDNS
+	}
+
+	var (
+		recordErr    tls.RecordHeaderError
+		authorityErr x509.UnknownAuthorityError
+		hostnameErr  x509.HostnameError
+		invalidErr   x509.CertificateInvalidError
+	)
+	if errors.As(err, &recordErr) || errors.As(err, &authorityErr) ||
+		errors.As(err, &hostnameErr) || errors.As(err, &invalidErr) {
+		return ReasonTLS
+	}
+
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ReasonRefused
+	case errors.Is(err, syscall.ECONNRESET):
+		return ReasonReset
+	}
+
+	// mihomo 部分错误仅保留文本，按关键字兜底
+	msg := strings.ToLower(err.Error())
+	var netErr net.Error
+	timeout := errors.Is(err, context.DeadlineExceeded) ||
+		(errors.As(err, &netErr) && netErr.Timeout()) ||
+		strings.Contains(msg, "timeout") || strings.Contains(msg, "deadline exceeded")
+
+	switch {
+	case timeout && strings.Contains(msg, "dial"):
+		return ReasonDialTimeout
+	case timeout:
+		return ReasonTimeout
+	case strings.Contains(msg, "connection refused"):
+		return ReasonRefused
+	case strings.Contains(msg, "connection reset"):
+		return ReasonReset
+	case strings.Contains(msg, "no such host"):
+		return ReasonDNS
+	case strings.Contains(msg, "tls:") || strings.Contains(msg, "x509:"):
+		return ReasonTLS
+	case errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || strings.HasSuffix(msg, "eof"):
+		return ReasonEOF
+	}
+	return ReasonUnknown
+}
+
+// traceWriter 将检测轨迹逐行写入 check-trace.jsonl
+type traceWriter struct {
+	mu  sync.Mutex
+	f   *os.File
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+// openTraceWriter 创建本轮检测的轨迹文件，覆盖上一轮结果；断点恢复和 worker 同一轮次的分片追加
+func openTraceWriter(appendMode bool) (*traceWriter, error) {
+	saver, err := method.NewStatsSaver()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(saver.StatsPath, 0o755); err != nil {
+		return nil, fmt.Errorf("创建目录失败: %w", err)
+	}
+	flag := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if appendMode {
+		flag = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	f, err := os.OpenFile(filepath.Join(saver.StatsPath, TraceFileName), flag, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("创建检测轨迹文件失败: %w", err)
+	}
+	w := bufio.NewWriterSize(f, 64*1024)
+	return &traceWriter{f: f, w: w, enc: json.NewEncoder(w)}, nil
+}
+
+// Write 写入一条轨迹，并发安全
+func (t *traceWriter) Write(r *TraceRecord) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.enc.Encode(r); err != nil {
+		slog.Debug(fmt.Sprintf("写入检测轨迹失败: %v", err))
+	}
+}
+
+// Close 刷新缓冲并关闭文件
+func (t *traceWriter) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.w.Flush(); err != nil {
+		t.f.Close()
+		return err
+	}
+	return t.f.Close()
+}
+
+// FailureStats 按订阅和协议统计失败原因，键为 "阶段/原因"
+type FailureStats struct {
+	mu     sync.Mutex
+	Total  map[string]int
+	BySub  map[string]map[string]int
+	ByType map[string]map[string]int
+}
+
+func newFailureStats() *FailureStats {
+	return &FailureStats{
+		Total:  make(map[string]int),
+		BySub:  make(map[string]map[string]int),
+		ByType: make(map[string]map[string]int),
+	}
+}
+
+// Add 记录一次失败，并发安全
+func (s *FailureStats) Add(subURL, pType, stage, reason string) {
+	key := stage + "/" + reason
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Total[key]++
+	if subURL != "" {
+		if s.BySub[subURL] == nil {
+			s.BySub[subURL] = make(map[string]int)
+		}
+		s.BySub[subURL][key]++
+	}
+	if pType != "" {
+		if s.ByType[pType] == nil {
+			s.ByType[pType] = make(map[string]int)
+		}
+		s.ByType[pType][key]++
+	}
+}
+
+// Sub 返回指定订阅的失败原因统计副本
+func (s *FailureStats) Sub(subURL string) map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return maps.Clone(s.BySub[subURL])
+}
+
+// failureSnapshot 失败原因统计的可序列化副本，用于断点恢复
+type failureSnapshot struct {
+	Total  map[string]int            `yaml:"total"`
+	BySub  map[string]map[string]int `yaml:"by-sub"`
+	ByType map[string]map[string]int `yaml:"by-type"`
+}
+
+// snapshot 返回统计数据的深拷贝
+func (s *FailureStats) snapshot() *failureSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &failureSnapshot{
+		Total:  maps.Clone(s.Total),
+		BySub:  cloneNested(s.BySub),
+		ByType: cloneNested(s.ByType),
+	}
+}
+
+// merge 累加断点中的统计数据
+func (s *FailureStats) merge(f *failureSnapshot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, n := range f.Total {
+		s.Total[k] += n
+	}
+	mergeNested(s.BySub, f.BySub)
+	mergeNested(s.ByType, f.ByType)
+}
+
+func cloneNested(src map[string]map[string]int) map[string]map[string]int {
+	dst := make(map[string]map[string]int, len(src))
+	for k, m := range src {
+		dst[k] = maps.Clone(m)
+	}
+	return dst
+}
+
+func mergeNested(dst, src map[string]map[string]int) {
+	for k, m := range src {
+		if dst[k] == nil {
+			dst[k] = make(map[string]int, len(m))
+		}
+		for key, n := range m {
+			dst[k][key] += n
+		}
+	}
+}
+
+// fail 记录失败阶段并结束节点检测
+func (pc *ProxyChecker) fail(job *ProxyJob, stage string, start time.Time, reason string, err error) {
+	job.trace(stage, start, reason, err)
+	pc.finish(job, false)
+}
+
+// finish 节点检测结束：更新健康数据库、写入检测轨迹并统计失败原因
+func (pc *ProxyChecker) finish(job *ProxyJob, passed bool) {
+	if job.Result.Proxy == nil {
+		return
+	}
+	pc.observe(job, passed)
+
+	if pc.ckpt != nil {
+		if passed {
+			pc.ckpt.markDone(job.Index, &job.Result)
+		} else {
+			pc.ckpt.markDone(job.Index, nil)
+		}
+	}
+
+	var last TraceStep
+	if n := len(job.Trail); n > 0 {
+		last = job.Trail[n-1]
+	}
+
+	if !passed && pc.failures != nil {
+		subURL, _ := job.Result.Proxy["sub_url"].(string)
+		pType, _ := job.Result.Proxy["type"].(string)
+		pc.failures.Add(subURL, pType, last.Stage, last.Reason)
+	}
+	pc.writeTrace(job, passed, last)
+}
+
+// abandon 丢弃未完成检测的节点并释放资源
+//
+// 节点未得出结论，不写入健康数据库和断点，仅在检测轨迹中以 cancelled 记录。
+func (pc *ProxyChecker) abandon(job *ProxyJob, stage string) {
+	defer job.Close()
+	if pc.tracer == nil || job.Result.Proxy == nil {
+		return
+	}
+	job.trace(stage, time.Now(), ReasonCancelled, nil)
+	pc.writeTrace(job, false, job.Trail[len(job.Trail)-1])
+}
+
+// writeTrace 写入节点检测轨迹，last 为结束时的阶段
+func (pc *ProxyChecker) writeTrace(job *ProxyJob, passed bool, last TraceStep) {
+	if pc.tracer == nil {
+		return
+	}
+	subURL, _ := job.Result.Proxy["sub_url"].(string)
+	pType, _ := job.Result.Proxy["type"].(string)
+	rec := &TraceRecord{
+		Time:   time.Now(),
+		Type:   pType,
+		SubURL: subURL,
+		Passed: passed,
+		Stage:  last.Stage,
+		Reason: last.Reason,
+		Steps:  job.Trail,
+	}
+	rec.Name, _ = job.Result.Proxy["name"].(string)
+	if v, ok := job.Result.Proxy["server"]; ok {
+		rec.Server = fmt.Sprint(v)
+	}
+	pc.tracer.Write(rec)
+}
+
+// openTrace 创建检测轨迹文件和失败原因统计
+func (pc *ProxyChecker) openTrace() {
+	pc.failures = newFailureStats()
+	if !pc.opts.CheckTrace {
+		return
+	}
+	t, err := openTraceWriter(pc.traceAppend || (pc.ckpt != nil && pc.ckpt.restored != nil))
+	if err != nil {
+		slog.Warn(fmt.Sprintf("创建检测轨迹文件失败: %v", err))
+		return
+	}
+	pc.tracer = t
+}
+
+// closeTrace 关闭检测轨迹文件
+func (pc *ProxyChecker) closeTrace() {
+	if pc.tracer == nil {
+		return
+	}
+	if err := pc.tracer.Close(); err != nil {
+		slog.Warn(fmt.Sprintf("保存检测轨迹失败: %v", err))
+		return
+	}
+	slog.Info(fmt.Sprintf("检测轨迹已保存: %s", TraceFileName))
+}
@@ -0,0 +1,95 @@
+package check
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/sinspired/subs-check-pro/check/platform"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{&platform.StatusError{Code: 403}, ReasonHTTPStatus},
+		{fmt.Errorf("wrap: %w", &platform.StatusError{Code: 502}), ReasonHTTPStatus},
+		{&net.DNSError{Err: "no such host", Name: "a.example"}, ReasonDNS},
+		{&net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, ReasonRefused},
+		{&net.OpError{Op: "read", Err: syscall.ECONNRESET}, ReasonReset},
+		{fmt.Errorf("dial tcp 1.2.3.4:443: i/o timeout"), ReasonDialTimeout},
+		{context.DeadlineExceeded, ReasonTimeout},
+		{errors.New("tls: first record does not look like a TLS handshake"), ReasonTLS},
+		{fmt.Errorf("read: %w", io.ErrUnexpectedEOF), ReasonEOF},
+		{errors.New("something else"), ReasonUnknown},
+		{nil, ReasonUnknown},
+	}
+	for _, tt := range tests {
+		if got := classifyError(tt.err); got != tt.want {
+			t.Errorf("classifyError(%v) = %s, want %s", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestFailureStats(t *testing.T) {
+	s := newFailureStats()
+	s.Add("https://a.example/sub", "ss", StageAlive, ReasonTimeout)
+	s.Add("https://a.example/sub", "ss", StageAlive, ReasonTimeout)
+	s.Add("https://b.example/sub", "vmess", StageSpeed, ReasonTooSlow)
+
+	if got := s.Total["alive/timeout"]; got != 2 {
+		t.Errorf("Total[alive/timeout] = %d, want 2", got)
+	}
+	if got := s.Sub("https://b.example/sub")["speed/too_slow"]; got != 1 {
+		t.Errorf("BySub[b][speed/too_slow] = %d, want 1", got)
+	}
+	if got := s.ByType["ss"]["alive/timeout"]; got != 2 {
+		t.Errorf("ByType[ss][alive/timeout] = %d, want 2", got)
+	}
+}
+
+func TestAbandonTracesCancelled(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), TraceFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := bufio.NewWriter(f)
+	pc := NewSession(Options{}).newChecker(1)
+	pc.tracer = &traceWriter{f: f, w: w, enc: json.NewEncoder(w)}
+	pc.failures = newFailureStats()
+
+	job := &ProxyJob{Result: Result{Proxy: map[string]any{"name": "a", "type": "ss", "server": "1.2.3.4"}}}
+	job.trace(StageAlive, time.Now(), "", nil)
+	pc.abandon(job, StageSpeed)
+	if err := pc.tracer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rec TraceRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		t.Fatalf("解析轨迹失败: %v", err)
+	}
+	if rec.Passed || rec.Stage != StageSpeed || rec.Reason != ReasonCancelled || len(rec.Steps) != 2 {
+		t.Errorf("轨迹 = %+v", rec)
+	}
+	if len(pc.failures.Total) != 0 {
+		t.Errorf("取消的节点不应计入失败统计: %v", pc.failures.Total)
+	}
+	if job.Result.Proxy != nil {
+		t.Error("abandon 未释放节点")
+	}
+}
@@ -0,0 +1,79 @@
+package check
+
+import (
+	"context"
+	"errors"
+	"net"
+	"regexp"
+	"sync/atomic"
+	"time"
+
+	"github.com/metacubex/mihomo/constant"
+	"github.com/sinspired/subs-check-pro/check/platform"
+)
+
+// UDPTag 支持 UDP 转发的节点名称标签
+const UDPTag = "UDP"
+
+// reUDPTag 匹配节点名称中已有的 UDP 标签
+var reUDPTag = regexp.MustCompile(`\s*\|UDP\b`)
+
+var errNoUDP = errors.New("proxy does not support udp")
+
+// checkUDP 通过节点的 UDP 会话查询 DNS 并进行 STUN 交互，结果写入 job.Result
+func (s *Session) checkUDP(ctx context.Context, job *ProxyJob) error {
+	cli := job.Client
+	if !cli.mProxy.SupportUDP() {
+		return errNoUDP
+	}
+
+	timeout := time.Duration(s.opts.Timeout) * time.Millisecond
+	dialCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	dst := platform.UDPDNSServer
+	conn, err := cli.mProxy.ListenPacketContext(dialCtx, &constant.Metadata{
+		NetWork: constant.UDP,
+		DstIP:   dst.Addr(),
+		DstPort: dst.Port(),
+	})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	res, err := platform.CheckUDP(&countingPacketConn{
+		PacketConn:   conn,
+		readCounter:  &cli.Transport.BytesRead,
+		writeCounter: &cli.Transport.BytesWritten,
+	}, timeout)
+	job.Result.UDP = res.Supported
+	job.Result.NATType = res.NATType
+	if res.Supported {
+		// STUN 失败不影响 UDP 可用性判断
+		return nil
+	}
+	return err
+}
+
+// countingPacketConn 包裹 net.PacketConn，统计读/写字节数
+type countingPacketConn struct {
+	net.PacketConn
+	readCounter  *atomic.Uint64
+	writeCounter *atomic.Uint64
+}
+
+func (c *countingPacketConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, addr, err := c.PacketConn.ReadFrom(b)
+	if n > 0 {
+		c.readCounter.Add(uint64(n))
+	}
+	return n, addr, err
+}
+
+func (c *countingPacketConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	n, err := c.PacketConn.WriteTo(b, addr)
+	if n > 0 {
+		c.writeCounter.Add(uint64(n))
+	}
+	return n, err
+}
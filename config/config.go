@@ -36,81 +36,161 @@ type SubProcessConfig struct {
 	SubInfo bool `yaml:"sub-info"`
 }
 
+// IPRiskList 自定义 IP 风险数据集
+type IPRiskList struct {
+	// Name 数据集名称，用作文件名
+	Name string `yaml:"name"`
+	// URL 下载地址或本地文件路径，每行一个 IP 或 CIDR
+	URL string `yaml:"url"`
+	// Category 风险分类：abuse、tor、vpn、hosting
+	Category string `yaml:"category"`
+}
+
+// CustomProbe 自定义 HTTP 探测，在媒体检测阶段通过节点请求指定地址
+type CustomProbe struct {
+	// Name 探测名称，用于结果统计，不能与内置平台重名
+	Name string `yaml:"name"`
+	// URL 探测地址
+	URL string `yaml:"url"`
+	// Method 请求方法，默认 GET
+	Method string `yaml:"method"`
+	// Headers 自定义请求头
+	Headers map[string]string `yaml:"headers"`
+	// ExpectStatus 期望的状态码列表，为空时接受 2xx
+	ExpectStatus []int `yaml:"expect-status"`
+	// BodyRegex 响应内容必须匹配的正则
+	BodyRegex string `yaml:"body-regex"`
+	// BodyNotRegex 响应内容不能匹配的正则
+	BodyNotRegex string `yaml:"body-not-regex"`
+	// RegionRegex 从响应内容提取地区的正则，取第一个分组
+	RegionRegex string `yaml:"region-regex"`
+	// Tag 节点名称中的标签，为空时使用 Name
+	Tag string `yaml:"tag"`
+	// NeedCF 是否依赖 Cloudflare 可达
+	NeedCF bool `yaml:"need-cf"`
+}
+
+// DistributedConfig 分布式检测配置
+type DistributedConfig struct {
+	// Mode 运行模式：coordinator 协调节点，worker 工作节点，留空为单机检测
+	Mode string `yaml:"mode"`
+	// Token 协调节点与 worker 共享的认证令牌，两种模式均必填
+	Token string `yaml:"token"`
+	// Coordinator worker 连接的协调节点地址，如 http://10.0.0.1:8199
+	Coordinator string `yaml:"coordinator"`
+	// WorkerName worker 名称，默认使用主机名
+	WorkerName string `yaml:"worker-name"`
+	// ShardSize 协调节点每个分片包含的节点数
+	ShardSize int `yaml:"shard-size"`
+	// LeaseTimeout worker 超过此时间(秒)未上报视为失联，分片重新排队
+	LeaseTimeout int `yaml:"lease-timeout"`
+	// RunTimeout 协调节点一轮检测的最长时间(分钟)，超时后收集已有结果，0 为不限制
+	RunTimeout int `yaml:"run-timeout"`
+}
+
+// SubQuarantineConfig 订阅隔离策略
+type SubQuarantineConfig struct {
+	// Enabled 是否自动隔离失效订阅
+	Enabled bool `yaml:"enabled"`
+	// FetchFailures 连续获取失败达到此次数时隔离，0 为不按获取失败隔离
+	FetchFailures int `yaml:"fetch-failures"`
+	// ZeroRuns 连续无可用节点达到此次数时隔离，0 为不按可用节点隔离
+	ZeroRuns int `yaml:"zero-runs"`
+	// SkipRuns 首次隔离跳过的检测次数，再次隔离时翻倍
+	SkipRuns int `yaml:"skip-runs"`
+	// MaxSkipRuns 跳过次数上限
+	MaxSkipRuns int `yaml:"max-skip-runs"`
+}
+
+// SubUsageConfig 订阅流量和到期提醒
+type SubUsageConfig struct {
+	// SkipExpired 跳过已到期或流量已用完的订阅节点
+	SkipExpired bool `yaml:"skip-expired"`
+	// WarnDays 剩余天数不足时提醒，0 为不提醒
+	WarnDays int `yaml:"warn-days"`
+	// WarnPercent 已用流量百分比达到时提醒，0 为不提醒
+	WarnPercent int `yaml:"warn-percent"`
+	// Notify 检测完成后发送提醒通知
+	Notify bool `yaml:"notify"`
+}
+
 type Config struct {
-	PrintProgress        bool     `yaml:"print-progress"`
-	ProgressMode         string   `yaml:"progress-mode"`
-	Concurrent           int      `yaml:"concurrent"`
-	AliveConcurrent      int      `yaml:"alive-concurrent"`
-	SpeedConcurrent      int      `yaml:"speed-concurrent"`
-	MediaConcurrent      int      `yaml:"media-concurrent"`
-	EnableIPv6           bool     `yaml:"ipv6"`
-	CheckInterval        int      `yaml:"check-interval"`
-	CronExpression       string   `yaml:"cron-expression"`
-	SpeedTestURL         string   `yaml:"speed-test-url"`
-	DownloadTimeout      int      `yaml:"download-timeout"`
-	DownloadMB           int      `yaml:"download-mb"`
-	TotalSpeedLimit      int      `yaml:"total-speed-limit"`
-	Threshold            float32  `yaml:"threshold"`
-	MinSpeed             int      `yaml:"min-speed"`
-	Timeout              int      `yaml:"timeout"`
-	FilterRegex          string   `yaml:"filter-regex"`
-	SaveMethod           string   `yaml:"save-method"`
-	WebDAVURL            string   `yaml:"webdav-url"`
-	WebDAVUsername       string   `yaml:"webdav-username"`
-	WebDAVPassword       string   `yaml:"webdav-password"`
-	GithubToken          string   `yaml:"github-token"`
-	GithubGistID         string   `yaml:"github-gist-id"`
-	GithubAPIMirror      string   `yaml:"github-api-mirror"`
-	WorkerURL            string   `yaml:"worker-url"`
-	WorkerToken          string   `yaml:"worker-token"`
-	S3Endpoint           string   `yaml:"s3-endpoint"`
-	S3AccessID           string   `yaml:"s3-access-id"`
-	S3SecretKey          string   `yaml:"s3-secret-key"`
-	S3Bucket             string   `yaml:"s3-bucket"`
-	S3UseSSL             bool     `yaml:"s3-use-ssl"`
-	S3BucketLookup       string   `yaml:"s3-bucket-lookup"`
-	SubUrlsReTry         int      `yaml:"sub-urls-retry"`
-	SubUrlsRetryInterval int      `yaml:"sub-urls-retry-interval"`
-	SubUrlsTimeout       int      `yaml:"sub-urls-timeout"`
-	SubUrlsRemote        []string `yaml:"sub-urls-remote"`
-	SubUrls              []string `yaml:"sub-urls"`
-	SuccessRate          float64  `yaml:"success-rate"`
-	MihomoAPIURL         string   `yaml:"mihomo-api-url"`
-	MihomoAPISecret      string   `yaml:"mihomo-api-secret"`
-	ListenPort           string   `yaml:"listen-port"`
-	RenameNode           bool     `yaml:"rename-node"`
-	KeepSuccessProxies   bool     `yaml:"keep-success-proxies"`
-	OutputDir            string   `yaml:"output-dir"`
-	AppriseAPIServer     string   `yaml:"apprise-api-server"`
-	RecipientURL         []string `yaml:"recipient-url"`
-	NotifyTitle          string   `yaml:"notify-title"`
-	SubStorePort         string   `yaml:"sub-store-port"`
-	SubStorePath         string   `yaml:"sub-store-path"`
-	SubStoreSyncCron     string   `yaml:"sub-store-sync-cron"`
-	SubStorePushService  string   `yaml:"sub-store-push-service"`
-	SubStoreProduceCron  string   `yaml:"sub-store-produce-cron"`
-	MihomoOverwriteURL   string   `yaml:"mihomo-overwrite-url"`
-	ISPCheck             bool     `yaml:"isp-check"`
-	MediaCheck           bool     `yaml:"media-check"`
-	Platforms            []string `yaml:"platforms"`
-	MaxMindDBPath        string   `yaml:"maxmind-db-path"`
-	DropBadCfNodes       bool     `yaml:"drop-bad-cf-nodes"`
-	EnhancedTag          bool     `yaml:"enhanced-tag"`
-	SuccessLimit         int32    `yaml:"success-limit"`
-	NodePrefix           string   `yaml:"node-prefix"`
-	NodeType             []string `yaml:"node-type"`
-	EnableWebUI          bool     `yaml:"enable-web-ui"`
-	APIKey               string   `yaml:"api-key"`
-	SharePassword        string   `yaml:"share-password"`
-	CallbackScript       string   `yaml:"callback-script"`
-	SystemProxy          string   `yaml:"system-proxy"`
-	GithubProxy          string   `yaml:"github-proxy"`
-	GithubProxyGroup     []string `yaml:"ghproxy-group"`
-	EnableSelfUpdate     bool     `yaml:"update"`
-	UpdateOnStartup      bool     `yaml:"update-on-startup"`
-	CronCheckUpdate      string   `yaml:"cron-check-update"`
-	Prerelease           bool     `yaml:"prerelease"`
-	UpdateTimeout        int      `yaml:"update-timeout"`
+	PrintProgress        bool        `yaml:"print-progress"`
+	ProgressMode         string      `yaml:"progress-mode"`
+	Concurrent           int         `yaml:"concurrent"`
+	AliveConcurrent      int         `yaml:"alive-concurrent"`
+	SpeedConcurrent      int         `yaml:"speed-concurrent"`
+	MediaConcurrent      int         `yaml:"media-concurrent"`
+	EnableIPv6           bool        `yaml:"ipv6"`
+	CheckInterval        int         `yaml:"check-interval"`
+	CronExpression       string      `yaml:"cron-expression"`
+	SpeedTestURL         string      `yaml:"speed-test-url"`
+	DownloadTimeout      int         `yaml:"download-timeout"`
+	DownloadMB           int         `yaml:"download-mb"`
+	TotalSpeedLimit      int         `yaml:"total-speed-limit"`
+	Threshold            float32     `yaml:"threshold"`
+	MinSpeed             int         `yaml:"min-speed"`
+	Timeout              int         `yaml:"timeout"`
+	LatencySamples       int         `yaml:"latency-samples"`
+	MaxLatency           int         `yaml:"max-latency"`
+	FilterRegex          string      `yaml:"filter-regex"`
+	SaveMethod           string      `yaml:"save-method"`
+	WebDAVURL            string      `yaml:"webdav-url"`
+	WebDAVUsername       string      `yaml:"webdav-username"`
+	WebDAVPassword       string      `yaml:"webdav-password"`
+	GithubToken          string      `yaml:"github-token"`
+	GithubGistID         string      `yaml:"github-gist-id"`
+	GithubAPIMirror      string      `yaml:"github-api-mirror"`
+	WorkerURL            string      `yaml:"worker-url"`
+	WorkerToken          string      `yaml:"worker-token"`
+	S3Endpoint           string      `yaml:"s3-endpoint"`
+	S3AccessID           string      `yaml:"s3-access-id"`
+	S3SecretKey          string      `yaml:"s3-secret-key"`
+	S3Bucket             string      `yaml:"s3-bucket"`
+	S3UseSSL             bool        `yaml:"s3-use-ssl"`
+	S3BucketLookup       string      `yaml:"s3-bucket-lookup"`
+	SubUrlsReTry         int         `yaml:"sub-urls-retry"`
+	SubUrlsRetryInterval int         `yaml:"sub-urls-retry-interval"`
+	SubUrlsTimeout       int         `yaml:"sub-urls-timeout"`
+	SubUrlsRemote        []string    `yaml:"sub-urls-remote"`
+	SubUrls              []SubSource `yaml:"sub-urls"`
+	SuccessRate          float64     `yaml:"success-rate"`
+	MihomoAPIURL         string      `yaml:"mihomo-api-url"`
+	MihomoAPISecret      string      `yaml:"mihomo-api-secret"`
+	ListenPort           string      `yaml:"listen-port"`
+	RenameNode           bool        `yaml:"rename-node"`
+	KeepSuccessProxies   bool        `yaml:"keep-success-proxies"`
+	OutputDir            string      `yaml:"output-dir"`
+	AppriseAPIServer     string      `yaml:"apprise-api-server"`
+	RecipientURL         []string    `yaml:"recipient-url"`
+	NotifyTitle          string      `yaml:"notify-title"`
+	SubStorePort         string      `yaml:"sub-store-port"`
+	SubStorePath         string      `yaml:"sub-store-path"`
+	SubStoreSyncCron     string      `yaml:"sub-store-sync-cron"`
+	SubStorePushService  string      `yaml:"sub-store-push-service"`
+	SubStoreProduceCron  string      `yaml:"sub-store-produce-cron"`
+	MihomoOverwriteURL   string      `yaml:"mihomo-overwrite-url"`
+	ISPCheck             bool        `yaml:"isp-check"`
+	MediaCheck           bool        `yaml:"media-check"`
+	Platforms            []string    `yaml:"platforms"`
+	MaxMindDBPath        string      `yaml:"maxmind-db-path"`
+	DropBadCfNodes       bool        `yaml:"drop-bad-cf-nodes"`
+	EnhancedTag          bool        `yaml:"enhanced-tag"`
+	SuccessLimit         int32       `yaml:"success-limit"`
+	NodePrefix           string      `yaml:"node-prefix"`
+	NodeType             []string    `yaml:"node-type"`
+	EnableWebUI          bool        `yaml:"enable-web-ui"`
+	APIKey               string      `yaml:"api-key"`
+	SharePassword        string      `yaml:"share-password"`
+	CallbackScript       string      `yaml:"callback-script"`
+	SystemProxy          string      `yaml:"system-proxy"`
+	GithubProxy          string      `yaml:"github-proxy"`
+	GithubProxyGroup     []string    `yaml:"ghproxy-group"`
+	EnableSelfUpdate     bool        `yaml:"update"`
+	UpdateOnStartup      bool        `yaml:"update-on-startup"`
+	CronCheckUpdate      string      `yaml:"cron-check-update"`
+	Prerelease           bool        `yaml:"prerelease"`
+	UpdateTimeout        int         `yaml:"update-timeout"`
 
 	// SingboxLatest / SingboxOld iOS 仍停留在 1.11，兼容两个版本
 	SingboxLatest SingBoxConfig `yaml:"singbox-latest"`
@@ -118,6 +198,108 @@ type Config struct {
 
 	// SubProcess sub 订阅操作配置
 	SubProcess SubProcessConfig `yaml:"sub-process"`
+
+	// CustomProbes 自定义 HTTP 探测
+	CustomProbes []CustomProbe `yaml:"custom-probes"`
+
+	// NodeHealth 持久化记录节点历次检测的健康状况
+	NodeHealth bool `yaml:"node-health"`
+	// NodeHealthRetention 节点连续多少天未出现后从健康数据库删除
+	NodeHealthRetention int `yaml:"node-health-retention"`
+
+	// CheckTrace 输出每个节点各阶段的检测轨迹
+	CheckTrace bool `yaml:"check-trace"`
+
+	// CheckpointInterval 检测断点保存间隔(秒)，0 为关闭
+	CheckpointInterval int `yaml:"checkpoint-interval"`
+	// AutoResume 启动时自动恢复中断的检测
+	AutoResume bool `yaml:"auto-resume"`
+
+	// Distributed 分布式检测
+	Distributed DistributedConfig `yaml:"distributed"`
+
+	// AdaptiveConcurrency 检测过程中动态调整各阶段并发
+	AdaptiveConcurrency bool `yaml:"adaptive-concurrency"`
+	// AdaptiveMinConcurrent / AdaptiveMaxConcurrent 动态并发的下限和上限，上限为 0 时取初始并发的 2 倍
+	AdaptiveMinConcurrent int `yaml:"adaptive-min-concurrent"`
+	AdaptiveMaxConcurrent int `yaml:"adaptive-max-concurrent"`
+
+	// AliveTargets 测活目标，内置名称(gstatic, google, cf-trace)或 http(s) 地址，为空时使用 gstatic
+	AliveTargets []string `yaml:"alive-targets"`
+	// AliveQuorum 至少需要通过的测活目标数量
+	AliveQuorum int `yaml:"alive-quorum"`
+	// AliveRetries 测活失败后的重试次数
+	AliveRetries int `yaml:"alive-retries"`
+	// AliveRetryBackoff 首次重试前的等待时间(毫秒)，之后每次翻倍
+	AliveRetryBackoff int `yaml:"alive-retry-backoff"`
+
+	// IntegrityCheck 测活后检测节点是否劫持流量：明文内容篡改、重定向、TLS 中间人
+	IntegrityCheck bool `yaml:"integrity-check"`
+	// DropHijackedNodes 丢弃劫持流量的节点，否则仅在名称中添加标签
+	DropHijackedNodes bool `yaml:"drop-hijacked-nodes"`
+
+	// UDPCheck 通过节点发送 DNS 查询和 STUN 请求，检测 UDP 转发和 NAT 类型
+	UDPCheck bool `yaml:"udp-check"`
+	// RequireUDP 丢弃不支持 UDP 的节点，开启时自动进行 UDP 检测
+	RequireUDP bool `yaml:"require-udp"`
+
+	// UploadTestURL 上传测速地址，接受 POST/PUT 的任意地址，为空时不测上传，需同时开启下载测速
+	UploadTestURL string `yaml:"upload-test-url"`
+	// UploadMethod 上传请求方法，POST 或 PUT
+	UploadMethod string `yaml:"upload-method"`
+	// UploadMB 单节点上传数据大小(MB)
+	UploadMB int `yaml:"upload-mb"`
+	// MinUploadSpeed 最低上传速度(KB/s)，0 为不限制
+	MinUploadSpeed int `yaml:"min-upload-speed"`
+
+	// SpeedStreams 每个测速地址的并发连接数
+	SpeedStreams int `yaml:"speed-streams"`
+	// SpeedSamples 每个节点测试的地址数量，取中位数；download-timeout 和 download-mb 由各地址平分
+	SpeedSamples int `yaml:"speed-samples"`
+
+	// TrafficBudgetPerRun 单轮检测流量预算(MB)，接近时停止测速，耗尽时结束检测，0 为不限
+	TrafficBudgetPerRun int `yaml:"traffic-budget-per-run"`
+	// TrafficBudgetPerMonth 每自然月检测流量预算(GB)，用量保存在 output/stats，重启后继续累计，0 为不限
+	TrafficBudgetPerMonth int `yaml:"traffic-budget-per-month"`
+
+	// ExitDedup 出口去重：off 只统计，keep-best 每个出口保留最优节点，keep-n 保留最优的 exit-dedup-keep 个
+	ExitDedup string `yaml:"exit-dedup"`
+	// ExitDedupBy 出口分组方式：ip、subnet(IPv4 /24，IPv6 /48)、asn
+	ExitDedupBy string `yaml:"exit-dedup-by"`
+	// ExitDedupKeep keep-n 模式下每个出口保留的节点数量
+	ExitDedupKeep int `yaml:"exit-dedup-keep"`
+	// ASNDBPath ASN 数据库路径(GeoLite2-ASN 或兼容格式的 mmdb)，为空时自动下载
+	ASNDBPath string `yaml:"asn-db-path"`
+
+	// ExitCache 按出口 IP 缓存媒体解锁、地理位置和 IP 风险结果，跨检测保存
+	ExitCache bool `yaml:"exit-cache"`
+	// ExitCacheTTL 缓存有效期(分钟)
+	ExitCacheTTL int `yaml:"exit-cache-ttl"`
+	// ExitCachePlatformTTL 按检测项设置缓存有效期(分钟)，键为平台名称、geo 或 iprisk
+	ExitCachePlatformTTL map[string]int `yaml:"exit-cache-platform-ttl"`
+
+	// ISPOnlineFallback 离线无法识别 isp 类型时使用 ipapi.is 在线查询
+	ISPOnlineFallback bool `yaml:"isp-online-fallback"`
+	// ASNRulesPath 自定义 ASN 分类规则文件，与内置规则合并
+	ASNRulesPath string `yaml:"asn-rules-path"`
+
+	// IPRiskSource IP 风险来源：offline 本地数据集评估，scamalytics 在线查询
+	IPRiskSource string `yaml:"ip-risk-source"`
+	// IPRiskLists 自定义风险数据集，与内置数据集同名时替换
+	IPRiskLists []IPRiskList `yaml:"ip-risk-lists"`
+	// IPRiskUpdateInterval 数据集更新间隔(小时)
+	IPRiskUpdateInterval int `yaml:"ip-risk-update-interval"`
+
+	// SubCache 保存订阅内容，用于条件请求和获取失败时回退
+	SubCache bool `yaml:"sub-cache"`
+	// SubCacheMaxStale 获取失败时允许使用的缓存最长时效(小时)
+	SubCacheMaxStale int `yaml:"sub-cache-max-stale"`
+
+	// SubQuarantine 订阅健康统计和自动隔离
+	SubQuarantine SubQuarantineConfig `yaml:"sub-quarantine"`
+
+	// SubUsage 订阅响应头 subscription-userinfo 的流量和到期提醒
+	SubUsage SubUsageConfig `yaml:"sub-usage"`
 }
 
 var OriginDefaultConfig = &Config{
@@ -131,9 +313,56 @@ var OriginDefaultConfig = &Config{
 		"youtube",
 	},
 	DownloadMB:       20,
+	UploadMethod:     "POST",
+	UploadMB:         5,
+	SpeedStreams:     1,
+	SpeedSamples:     1,
 	EnableSelfUpdate: true,
 	CronCheckUpdate:  "0 0,9,21 * * *",
 
+	NodeHealth:          true,
+	NodeHealthRetention: 30,
+	CheckTrace:          true,
+	CheckpointInterval:  60,
+	AutoResume:          false,
+
+	Distributed: DistributedConfig{
+		ShardSize:    2000,
+		LeaseTimeout: 120,
+	},
+
+	AdaptiveMinConcurrent: 4,
+
+	AliveQuorum:       1,
+	AliveRetryBackoff: 500,
+
+	ExitDedup:     "off",
+	ExitDedupBy:   "ip",
+	ExitDedupKeep: 2,
+
+	ExitCacheTTL: 360,
+
+	ISPOnlineFallback: true,
+
+	IPRiskSource:         "offline",
+	IPRiskUpdateInterval: 24,
+
+	SubCache:         true,
+	SubCacheMaxStale: 48,
+
+	SubQuarantine: SubQuarantineConfig{
+		FetchFailures: 3,
+		ZeroRuns:      5,
+		SkipRuns:      2,
+		MaxSkipRuns:   32,
+	},
+
+	SubUsage: SubUsageConfig{
+		WarnDays:    3,
+		WarnPercent: 90,
+		Notify:      true,
+	},
+
 	SubProcess: SubProcessConfig{
 		ResolveDomain:   false,
 		NodeSplit:       false,
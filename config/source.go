@@ -0,0 +1,100 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/goccy/go-yaml"
+)
+
+// 订阅获取方式，为空时依次尝试系统代理、GitHub 代理和直连
+const (
+	FetchViaDirect      = "direct"
+	FetchViaSystemProxy = "system-proxy"
+	FetchViaGhProxy     = "gh-proxy"
+)
+
+// SubSource 订阅源，配置中可以写成字符串(仅 URL)或对象
+type SubSource struct {
+	// Name 订阅名称，用于日志和统计，为空时使用 URL
+	Name string `yaml:"name,omitempty"`
+	// URL 订阅地址，支持 #备注 和日期占位符
+	URL string `yaml:"url"`
+	// Headers 自定义请求头，如认证 token
+	Headers map[string]string `yaml:"headers,omitempty"`
+	// UserAgent 为空时轮换内置 UA
+	UserAgent string `yaml:"user-agent,omitempty"`
+	// FetchVia 获取方式：direct、system-proxy、gh-proxy
+	FetchVia string `yaml:"fetch-via,omitempty"`
+	// Timeout 超时(秒)，为空时使用 sub-urls-timeout
+	Timeout int `yaml:"timeout,omitempty"`
+	// Enabled 为 false 时跳过该订阅
+	Enabled *bool `yaml:"enabled,omitempty"`
+	// NodeType 只保留指定协议的节点，为空时使用全局 node-type
+	NodeType []string `yaml:"node-type,omitempty"`
+	// MaxNodes 最多读取的节点数量，0 为不限
+	MaxNodes int `yaml:"max-nodes,omitempty"`
+}
+
+// subSourceFields 用于按对象解析，避免递归调用 UnmarshalYAML
+type subSourceFields SubSource
+
+// UnmarshalYAML 兼容字符串和对象两种写法
+func (s *SubSource) UnmarshalYAML(data []byte) error {
+	var v any
+	if err := yaml.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case nil:
+		*s = SubSource{}
+		return nil
+	case string:
+		*s = SubSource{URL: v}
+		return nil
+	case map[string]any:
+		var f subSourceFields
+		if err := yaml.Unmarshal(data, &f); err != nil {
+			return err
+		}
+		*s = SubSource(f)
+		return nil
+	default:
+		return fmt.Errorf("订阅地址格式错误: %v", v)
+	}
+}
+
+// MarshalYAML 只有 URL 时输出为字符串，保持原有写法
+func (s SubSource) MarshalYAML() (any, error) {
+	if s.IsPlain() {
+		return s.URL, nil
+	}
+	return subSourceFields(s), nil
+}
+
+// IsPlain 是否只设置了 URL
+func (s SubSource) IsPlain() bool {
+	return s.Name == "" && len(s.Headers) == 0 && s.UserAgent == "" && s.FetchVia == "" &&
+		s.Timeout == 0 && s.Enabled == nil && len(s.NodeType) == 0 && s.MaxNodes == 0
+}
+
+// IsEnabled 是否启用，未设置时启用
+func (s SubSource) IsEnabled() bool {
+	return s.Enabled == nil || *s.Enabled
+}
+
+// Label 返回用于日志的名称
+func (s SubSource) Label() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return s.URL
+}
+
+// SubURLs 返回订阅源的 URL 列表
+func SubURLs(sources []SubSource) []string {
+	out := make([]string, 0, len(sources))
+	for _, s := range sources {
+		out = append(out, s.URL)
+	}
+	return out
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/goccy/go-yaml"
+)
+
+func TestSubSourceYAML(t *testing.T) {
+	data := []byte(`sub-urls:
+  - "https://example.com/a.txt#备注"
+  - name: b
+    url: https://example.com/b.txt
+    headers:
+      Authorization: Bearer xxx
+    user-agent: clash.meta
+    fetch-via: direct
+    timeout: 30
+    enabled: false
+    node-type: [vless, trojan]
+    max-nodes: 100
+`)
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if len(cfg.SubUrls) != 2 {
+		t.Fatalf("SubUrls = %+v", cfg.SubUrls)
+	}
+
+	a, b := cfg.SubUrls[0], cfg.SubUrls[1]
+	if a.URL != "https://example.com/a.txt#备注" || !a.IsPlain() || !a.IsEnabled() {
+		t.Errorf("字符串写法解析错误: %+v", a)
+	}
+	if b.Name != "b" || b.Headers["Authorization"] != "Bearer xxx" || b.UserAgent != "clash.meta" ||
+		b.FetchVia != FetchViaDirect || b.Timeout != 30 || b.IsEnabled() ||
+		len(b.NodeType) != 2 || b.MaxNodes != 100 || b.Label() != "b" {
+		t.Errorf("对象写法解析错误: %+v", b)
+	}
+
+	// 只有 URL 时输出为字符串
+	out, err := yaml.Marshal(cfg.SubUrls)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if !strings.HasPrefix(string(out), `- "https://example.com/a.txt#`) || !strings.Contains(string(out), "name: b") {
+		t.Errorf("Marshal() = %s", out)
+	}
+}
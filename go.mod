@@ -20,6 +20,7 @@ require (
 	github.com/shirou/gopsutil/v4 v4.26.2
 	github.com/sinspired/checkip v0.2.17
 	github.com/sinspired/go-selfupdate v0.0.0-20260302091346-9011365a8031
+	golang.org/x/net v0.52.0
 	gopkg.in/natefinch/lumberjack.v2 v2.2.1
 )
 
@@ -152,7 +153,6 @@ require (
 	golang.org/x/crypto v0.49.0 // indirect
 	golang.org/x/exp v0.0.0-20260312153236-7ab1446f8b90 // indirect
 	golang.org/x/mod v0.34.0 // indirect
-	golang.org/x/net v0.52.0 // indirect
 	golang.org/x/oauth2 v0.36.0 // indirect
 	golang.org/x/sync v0.20.0 // indirect
 	golang.org/x/sys v0.42.0 // indirect
@@ -0,0 +1,214 @@
+package proxies
+
+import (
+	_ "embed"
+	"fmt"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/goccy/go-yaml"
+	"github.com/oschwald/maxminddb-golang/v2"
+)
+
+//go:embed asn_rules.yaml
+var builtinASNRules []byte
+
+// ASNRules ASN 分类规则，键为 ISPType
+type ASNRules struct {
+	ASN      map[ISPType][]uint   `yaml:"asn"`      // 按 AS 号精确匹配
+	Keywords map[ISPType][]string `yaml:"keywords"` // 按 AS 组织名称关键字匹配
+}
+
+// keywordOrder 关键字匹配顺序，组织名称同时命中多类时取靠前的
+var keywordOrder = []ISPType{ISPMobile, ISPEducation, ISPGovernment, ISPBanking, ISPHosting, ISPResidential}
+
+// ispDetails 分类的中文描述
+var ispDetails = map[ISPType]string{
+	ISPHosting:     "机房",
+	ISPResidential: "住宅",
+	ISPMobile:      "移动",
+	ISPBusiness:    "商宽",
+	ISPEducation:   "教育",
+	ISPGovernment:  "政府",
+	ISPBanking:     "银行",
+}
+
+// asnClassifier 编译后的分类规则
+type asnClassifier struct {
+	byASN    map[uint]ISPType
+	keywords map[ISPType][]string
+}
+
+var (
+	asnRulesMu sync.RWMutex
+	asnRules   *asnClassifier
+)
+
+// asnRecord GeoLite2-ASN 数据库记录
+type asnRecord struct {
+	Number uint   `maxminddb:"autonomous_system_number"`
+	Org    string `maxminddb:"autonomous_system_organization"`
+}
+
+// countryRecord GeoLite2-Country 数据库中判断原生 IP 需要的字段
+type countryRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	RegisteredCountry struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"registered_country"`
+}
+
+// LoadASNRules 加载内置分类规则，path 不为空时合并自定义规则
+//
+// 同一 AS 号以自定义规则为准，关键字追加到内置规则之后。
+func LoadASNRules(path string) error {
+	var rules ASNRules
+	if err := yaml.Unmarshal(builtinASNRules, &rules); err != nil {
+		return fmt.Errorf("解析内置 ASN 规则失败: %w", err)
+	}
+
+	var custom ASNRules
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("读取 ASN 规则失败: %w", err)
+		}
+		if err := yaml.Unmarshal(data, &custom); err != nil {
+			return fmt.Errorf("解析 ASN 规则失败: %w", err)
+		}
+	}
+
+	c := compileASNRules(rules, custom)
+	asnRulesMu.Lock()
+	asnRules = c
+	asnRulesMu.Unlock()
+	return nil
+}
+
+// compileASNRules 合并规则并建立 AS 号索引
+func compileASNRules(rules ...ASNRules) *asnClassifier {
+	c := &asnClassifier{
+		byASN:    make(map[uint]ISPType),
+		keywords: make(map[ISPType][]string),
+	}
+	for _, r := range rules {
+		for typ, list := range r.ASN {
+			for _, n := range list {
+				c.byASN[n] = typ
+			}
+		}
+		for typ, list := range r.Keywords {
+			for _, kw := range list {
+				kw = strings.ToLower(strings.TrimSpace(kw))
+				if kw != "" && !slices.Contains(c.keywords[typ], kw) {
+					c.keywords[typ] = append(c.keywords[typ], kw)
+				}
+			}
+		}
+	}
+	return c
+}
+
+// classifier 返回当前规则，未加载时使用内置规则
+func classifier() *asnClassifier {
+	asnRulesMu.RLock()
+	c := asnRules
+	asnRulesMu.RUnlock()
+	if c != nil {
+		return c
+	}
+	if err := LoadASNRules(""); err != nil {
+		return compileASNRules()
+	}
+	asnRulesMu.RLock()
+	defer asnRulesMu.RUnlock()
+	return asnRules
+}
+
+// ClassifyASN 按 AS 号和组织名称分类，无法识别时返回 ISPOther
+func ClassifyASN(asn uint, org string) ISPType {
+	c := classifier()
+	if typ, ok := c.byASN[asn]; ok {
+		return typ
+	}
+
+	org = strings.ToLower(org)
+	if org == "" {
+		return ISPOther
+	}
+	for _, typ := range keywordOrder {
+		for _, kw := range c.keywords[typ] {
+			if strings.Contains(org, kw) {
+				return typ
+			}
+		}
+	}
+	// 自定义规则中的其他分类
+	for typ, list := range c.keywords {
+		if slices.Contains(keywordOrder, typ) {
+			continue
+		}
+		for _, kw := range list {
+			if strings.Contains(org, kw) {
+				return typ
+			}
+		}
+	}
+	return ISPOther
+}
+
+// LookupISPInfo 使用本地数据库查询 IP 的运营商类型，返回分类结果和 AS 号
+//
+// 无法识别时 Type 为 ISPOther、Details 为空；geoDB 用于判断原生 IP，可为 nil。
+func LookupISPInfo(ip string, asnDB, geoDB *maxminddb.Reader) (*IPInfo, uint, error) {
+	if asnDB == nil {
+		return nil, 0, fmt.Errorf("ASN 数据库未加载")
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return nil, 0, fmt.Errorf("无效的 IP: %s", ip)
+	}
+	addr = addr.Unmap()
+
+	var rec asnRecord
+	if err := asnDB.Lookup(addr).Decode(&rec); err != nil {
+		return nil, 0, fmt.Errorf("查询 ASN 失败: %w", err)
+	}
+
+	info := &IPInfo{Type: ClassifyASN(rec.Number, rec.Org)}
+	info.Details = ispDetails[info.Type]
+
+	// 是否原生IP：IP 所在地与注册地一致
+	if geoDB != nil {
+		var c countryRecord
+		if err := geoDB.Lookup(addr).Decode(&c); err == nil && c.Country.ISOCode != "" {
+			info.IsNative = strings.EqualFold(c.Country.ISOCode, c.RegisteredCountry.ISOCode)
+		}
+	}
+	return info, rec.Number, nil
+}
+
+// FormatISPInfo 生成运营商标签，如 [原生|住宅]，无法识别时返回空
+func FormatISPInfo(info *IPInfo) string {
+	if info == nil || info.Type == ISPOther && info.Details == "" {
+		return ""
+	}
+
+	parts := []string{}
+	if info.IsNative {
+		parts = append(parts, "原生")
+	} else {
+		parts = append(parts, "广播")
+	}
+
+	if info.Details != "" {
+		parts = append(parts, info.Details)
+	}
+
+	return "[" + strings.Join(parts, "|") + "]"
+}
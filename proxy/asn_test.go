@@ -0,0 +1,77 @@
+package proxies
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestClassifyASN(t *testing.T) {
+	if err := LoadASNRules(""); err != nil {
+		t.Fatalf("LoadASNRules() error = %v", err)
+	}
+
+	tests := []struct {
+		asn  uint
+		org  string
+		want ISPType
+	}{
+		{16509, "AMAZON-02", ISPHosting},
+		{4134, "Chinanet", ISPResidential},
+		{9808, "China Mobile Communications Group Co., Ltd.", ISPMobile},
+		{1, "Example Hosting LLC", ISPHosting},
+		{1, "Example Mobile Cable Corp", ISPMobile}, // mobile 优先于 residential
+		{1, "State University", ISPEducation},
+		{1, "Example Broadband", ISPResidential},
+		{1, "", ISPOther},
+		{1, "ACME Corp", ISPOther},
+	}
+	for _, tt := range tests {
+		if got := ClassifyASN(tt.asn, tt.org); got != tt.want {
+			t.Errorf("ClassifyASN(%d, %q) = %s, want %s", tt.asn, tt.org, got, tt.want)
+		}
+	}
+}
+
+func TestLoadASNRulesMerge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "asn.yaml")
+	custom := "asn:\n  residential: [16509]\nkeywords:\n  business: [\"acme\"]\n"
+	if err := os.WriteFile(path, []byte(custom), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadASNRules(path); err != nil {
+		t.Fatalf("LoadASNRules() error = %v", err)
+	}
+	t.Cleanup(func() { _ = LoadASNRules("") })
+
+	if got := ClassifyASN(16509, ""); got != ISPResidential {
+		t.Errorf("自定义 AS 号应覆盖内置规则, got %s", got)
+	}
+	if got := ClassifyASN(1, "ACME Corp"); got != ISPBusiness {
+		t.Errorf("自定义关键字未生效, got %s", got)
+	}
+	if got := ClassifyASN(13335, ""); got != ISPHosting {
+		t.Errorf("内置规则应保留, got %s", got)
+	}
+
+	if err := LoadASNRules(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("规则文件不存在时应返回错误")
+	}
+}
+
+func TestFormatISPInfo(t *testing.T) {
+	tests := []struct {
+		info *IPInfo
+		want string
+	}{
+		{nil, ""},
+		{&IPInfo{Type: ISPOther}, ""},
+		{&IPInfo{Type: ISPResidential, Details: "住宅", IsNative: true}, "[原生|住宅]"},
+		{&IPInfo{Type: ISPHosting, Details: "机房"}, "[广播|机房]"},
+	}
+	for _, tt := range tests {
+		if got := FormatISPInfo(tt.info); got != tt.want {
+			t.Errorf("FormatISPInfo(%+v) = %q, want %q", tt.info, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package proxies
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"time"
+
+	"github.com/sinspired/subs-check-pro/config"
+	"github.com/sinspired/subs-check-pro/proxy/subcache"
+)
+
+// defaultSubCacheMaxStale sub-cache-max-stale 未设置时的缓存最长时效
+const defaultSubCacheMaxStale = 48 * time.Hour
+
+// subCache 本次获取使用的订阅缓存，未启用时为 nil
+var subCache *subcache.Store
+
+// subCacheMaxStale 返回获取失败时允许使用的缓存最长时效
+func subCacheMaxStale() time.Duration {
+	if h := config.GlobalConfig.SubCacheMaxStale; h > 0 {
+		return time.Duration(h) * time.Hour
+	}
+	return defaultSubCacheMaxStale
+}
+
+// openSubCache 加载订阅缓存
+func openSubCache() {
+	subCache = nil
+	if !config.GlobalConfig.SubCache {
+		return
+	}
+	dir, err := subcache.DefaultDir()
+	if err != nil {
+		slog.Warn(fmt.Sprintf("获取订阅缓存目录失败: %v", err))
+		return
+	}
+	c, err := subcache.Open(dir)
+	if err != nil {
+		// 索引损坏时重新开始缓存
+		slog.Warn(fmt.Sprintf("加载订阅缓存失败，将重新缓存: %v", err))
+		c = subcache.New(dir)
+	}
+	subCache = c
+}
+
+// saveSubCache 清理过期缓存并保存订阅缓存
+func saveSubCache() {
+	if subCache == nil {
+		return
+	}
+	if n := subCache.Prune(time.Now(), subCacheMaxStale()); n > 0 {
+		slog.Debug(fmt.Sprintf("清理过期订阅缓存: %d", n))
+	}
+	if err := subCache.Save(); err != nil {
+		slog.Warn(fmt.Sprintf("保存订阅缓存失败: %v", err))
+	}
+}
+
+// conditionalHeaders 在订阅请求头的基础上添加条件请求头
+func conditionalHeaders(headers map[string]string, e subcache.Entry) map[string]string {
+	if e.ETag == "" && e.LastModified == "" {
+		return headers
+	}
+	out := make(map[string]string, len(headers)+2)
+	maps.Copy(out, headers)
+	if e.ETag != "" {
+		out["If-None-Match"] = e.ETag
+	}
+	if e.LastModified != "" {
+		out["If-Modified-Since"] = e.LastModified
+	}
+	return out
+}
+
+// subCacheFallback 获取失败时读取未超过最长时效的缓存内容
+func subCacheFallback(c *subcache.Store, src config.SubSource, e subcache.Entry, cached bool, err error) ([]byte, bool) {
+	if c == nil || !cached {
+		return nil, false
+	}
+	age := e.Age(time.Now())
+	if age > subCacheMaxStale() {
+		slog.Debug("订阅缓存已过期，不再回退", "订阅", src.Label(), "缓存时长", age.Round(time.Minute))
+		return nil, false
+	}
+	data, e2 := c.Body(e)
+	if e2 != nil {
+		return nil, false
+	}
+
+	attrs := []any{"订阅", src.Label(), "缓存时长", age.Round(time.Minute)}
+	if !errors.Is(err, ErrIgnore) {
+		attrs = append(attrs, "error", err)
+	}
+	slog.Warn("订阅获取失败，使用缓存内容", attrs...)
+	return data, true
+}
@@ -15,6 +15,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"runtime/debug"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -25,6 +26,7 @@ import (
 	"github.com/metacubex/mihomo/common/convert"
 	"github.com/samber/lo"
 	"github.com/sinspired/subs-check-pro/config"
+	"github.com/sinspired/subs-check-pro/proxy/subcache"
 	"github.com/sinspired/subs-check-pro/save/method"
 	"github.com/sinspired/subs-check-pro/utils"
 )
@@ -35,8 +37,18 @@ var ErrIgnore = errors.New("error-ignore")
 type SubStat struct {
 	Total   int
 	Success int
+	Cache   string // 使用缓存的方式，为空时未使用缓存
 }
 
+// 订阅使用缓存的方式
+const (
+	CacheNotModified = "not-modified" // 条件请求返回 304，订阅未更新
+	CacheFallback    = "fallback"     // 获取失败，回退到缓存内容
+)
+
+// errNotModified 条件请求返回 304
+var errNotModified = errors.New("not modified")
+
 // 去重后的订阅数量
 var uniqueSubsCount int = 0
 
@@ -47,7 +59,7 @@ var SubStats = make(map[string]SubStat)
 type ProxyNode map[string]any
 
 type SubUrls struct {
-	SubUrls []string `yaml:"sub-urls" json:"sub-urls"`
+	SubUrls []config.SubSource `yaml:"sub-urls" json:"sub-urls"`
 }
 
 // initEnvironment 初始化代理环境变量
@@ -120,8 +132,16 @@ func GetProxies() ([]map[string]any, int, int, int, error) {
 	// 初始化代理环境变量
 	initEnvironment()
 
+	// 加载订阅缓存
+	openSubCache()
+	defer saveSubCache()
+
 	// 获取远程订阅列表
 	subUrls, localNum, remoteNum, historyNum := resolveSubUrls()
+
+	// 跳过隔离中的订阅
+	health := startSubHealth()
+	subUrls = health.skipQuarantined(subUrls)
 	logSubscriptionStats(len(subUrls), localNum, remoteNum, historyNum)
 
 	// 增大缓冲，减少消费者阻塞
@@ -176,6 +196,9 @@ func GetProxies() ([]map[string]any, int, int, int, error) {
 			if su, ok := proxy["sub_url"].(string); ok && su != "" {
 				stats := SubStats[su]
 				stats.Total++
+				if c, ok := proxy["sub_cache"].(string); ok {
+					stats.Cache = c
+				}
 				SubStats[su] = stats
 			}
 
@@ -229,15 +252,15 @@ func GetProxies() ([]map[string]any, int, int, int, error) {
 	listenPort := strings.TrimPrefix(config.GlobalConfig.ListenPort, ":")
 	subStorePort := strings.TrimPrefix(config.GlobalConfig.SubStorePort, ":")
 
-	for _, subURL := range subUrls {
+	for _, src := range subUrls {
 		wg.Add(1)
 		sem <- struct{}{}
-		isSucced, isHistory, tag := identifyLocalSubType(subURL, listenPort, subStorePort)
-		go func(u, t string, succ, hist bool) {
+		isSucced, isHistory, tag := identifyLocalSubType(src.URL, listenPort, subStorePort)
+		go func(src config.SubSource, t string, succ, hist bool) {
 			defer wg.Done()
 			defer func() { <-sem }()
-			processSubscription(u, t, succ, hist, proxyChan)
-		}(subURL, tag, isSucced, isHistory)
+			processSubscription(src, t, succ, hist, health, proxyChan)
+		}(src, tag, isSucced, isHistory)
 	}
 
 	wg.Wait()
@@ -248,28 +271,31 @@ func GetProxies() ([]map[string]any, int, int, int, error) {
 	debug.FreeOSMemory()
 
 	// 将 Map 转为 Slice，并统计最终的分类数量
-	finalProxies := make([]map[string]any, 0, len(uniqueNodes))
-	finalSuccCount := 0
-	finalHistCount := 0
+	// 上次成功的节点在前，其次是历史节点，最后是普通订阅节点
+	var succNodes, histNodes []map[string]any
+	otherNodes := make([]map[string]any, 0, len(uniqueNodes))
 
 	for key, node := range uniqueNodes {
 		keepLevel := nodeKeepLevels[key]
 
-		// 统计逻辑：根据最终留下的那个节点的优先级计数
-		switch keepLevel {
-		case KeepLevelSuccess:
-			finalSuccCount++
-		case KeepLevelHistory:
-			finalHistCount++
-		}
-
 		// 清理元数据
 		cleanMetadata(node)
 
 		// 这里的显式转换是为了满足返回值类型 []map[string]any
-		finalProxies = append(finalProxies, map[string]any(node))
+		switch keepLevel {
+		case KeepLevelSuccess:
+			succNodes = append(succNodes, map[string]any(node))
+		case KeepLevelHistory:
+			histNodes = append(histNodes, map[string]any(node))
+		default:
+			otherNodes = append(otherNodes, map[string]any(node))
+		}
 	}
 
+	finalSuccCount := len(succNodes)
+	finalHistCount := len(histNodes)
+	finalProxies := slices.Concat(succNodes, histNodes, otherNodes)
+
 	// 释放 Map 内存（虽然函数返回后也会释放）
 	uniqueNodes = nil
 	nodeKeepLevels = nil
@@ -281,21 +307,33 @@ func GetProxies() ([]map[string]any, int, int, int, error) {
 		"丢弃", rawCount-len(finalProxies),
 	)
 	saveStats(SubStats)
+	health.finish(SubStats)
 	return finalProxies, rawCount, finalSuccCount, finalHistCount, nil
 }
 
-// processSubscription 单个订阅的处理流程
-func processSubscription(urlStr, tag string, wasSucced, wasHistory bool, out chan<- ProxyNode) {
+// processSubscription 单个订阅的处理流程，获取结果记录到订阅健康统计(回退到缓存视为失败)
+func processSubscription(src config.SubSource, tag string, wasSucced, wasHistory bool, health *subHealthRun, out chan<- ProxyNode) {
+	urlStr := src.URL
+
 	// 1. 下载
-	data, err := FetchSubsData(urlStr)
+	data, header, cache, err := fetchSubscription(src)
+	// 占位符订阅未找到可用日期时主动跳过，不计入获取失败
+	if !errors.Is(err, ErrIgnore) {
+		health.observeFetch(urlStr, err == nil && cache != CacheFallback)
+	}
 	if err != nil {
 		if !errors.Is(err, ErrIgnore) {
 			// 根据错误类型打印错误消息
-			logFatal(err, urlStr)
+			logFatal(err, src.Label())
 		}
 		return
 	}
 
+	// 流量和到期信息，已到期或流量用完时按配置跳过
+	if health.observeUsage(src, header) {
+		return
+	}
+
 	// 2. 解析
 	nodes, err := parseSubscriptionData(data, urlStr)
 	if err != nil {
@@ -307,11 +345,18 @@ func processSubscription(urlStr, tag string, wasSucced, wasHistory bool, out cha
 		}
 	}
 
-	// 3. 过滤与发送
+	// 3. 过滤与发送，订阅单独设置的协议筛选优先
 	count := 0
 	filterTypes := config.GlobalConfig.NodeType
+	if len(src.NodeType) > 0 {
+		filterTypes = src.NodeType
+	}
 
 	for _, node := range nodes {
+		if src.MaxNodes > 0 && count >= src.MaxNodes {
+			slog.Debug("达到订阅节点数量上限", "订阅", src.Label(), "max-nodes", src.MaxNodes)
+			break
+		}
 		slog.Debug("解析代理节点成功", "node", node)
 		// 类型过滤
 		if len(filterTypes) > 0 {
@@ -327,12 +372,15 @@ func processSubscription(urlStr, tag string, wasSucced, wasHistory bool, out cha
 		node["sub_tag"] = tag
 		node["sub_was_succeed"] = wasSucced
 		node["sub_from_history"] = wasHistory
+		if cache != "" {
+			node["sub_cache"] = cache
+		}
 
 		out <- node
 		count++
 	}
 
-	slog.Debug("订阅解析完成", "URL", urlStr, "有效节点", count)
+	slog.Debug("订阅解析完成", "订阅", src.Label(), "有效节点", count)
 }
 
 // parseSubscriptionData 智能分发解析器
@@ -431,6 +479,45 @@ func parseSubscriptionData(data []byte, subURL string) ([]ProxyNode, error) {
 	return nil, fmt.Errorf("未知格式")
 }
 
+// ParseNodes 解析用户直接提供的节点：分享链接、Clash YAML 片段或 JSON，并统一清洗字段
+func ParseNodes(data []byte) ([]ProxyNode, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, fmt.Errorf("内容为空")
+	}
+
+	var nodes []ProxyNode
+	// 单个节点或不带 proxies 键的节点列表
+	var generic any
+	if err := yaml.Unmarshal(data, &generic); err == nil {
+		switch val := generic.(type) {
+		case map[string]any:
+			if _, ok := val["type"]; ok {
+				nodes = []ProxyNode{ProxyNode(val)}
+			}
+		case []any:
+			if len(val) > 0 {
+				if m, ok := val[0].(map[string]any); ok && m["type"] != nil {
+					nodes = convertListToNodes(val)
+				}
+			}
+		}
+	}
+
+	if len(nodes) == 0 {
+		var err error
+		if nodes, err = parseSubscriptionData(data, ""); err != nil {
+			if nodes = fallbackExtractV2Ray(data, ""); len(nodes) == 0 {
+				return nil, err
+			}
+		}
+	}
+	for _, node := range nodes {
+		NormalizeNode(node)
+	}
+	return nodes, nil
+}
+
 // parseRawLines 读取纯文本行并交给统一解析器
 func parseRawLines(data []byte, subURL string) []ProxyNode {
 	scanner := bufio.NewScanner(bytes.NewReader(data))
@@ -472,12 +559,22 @@ func convertListToNodes(list []any) []ProxyNode {
 }
 
 // FetchSubsData 获取数据 (包含重试、占位符处理、代理策略)
-func FetchSubsData(rawURL string) ([]byte, error) {
+//
+// 按订阅源的请求头、UA、获取方式和超时设置请求，未设置的使用全局配置。
+func FetchSubsData(src config.SubSource) ([]byte, error) {
+	data, _, _, err := fetchSubscription(src)
+	return data, err
+}
+
+// fetchSubscription 获取订阅内容，启用订阅缓存时发起条件请求并在失败时回退到缓存
+//
+// 返回响应头和使用缓存的方式，回退到缓存时响应头为 nil，未使用缓存时方式为空。
+func fetchSubscription(src config.SubSource) ([]byte, http.Header, string, error) {
 	// 清洗 URL
-	rawURL = CleanURL(rawURL)
+	rawURL := CleanURL(src.URL)
 
 	if _, err := url.Parse(rawURL); err != nil {
-		return nil, err
+		return nil, nil, "", err
 	}
 
 	slog.Debug("正在下载订阅", "URL", rawURL)
@@ -485,6 +582,9 @@ func FetchSubsData(rawURL string) ([]byte, error) {
 	conf := config.GlobalConfig
 	maxRetries := max(1, conf.SubUrlsReTry)
 	timeout := max(10, conf.SubUrlsTimeout)
+	if src.Timeout > 0 {
+		timeout = src.Timeout
+	}
 
 	// 处理为标准的GitHub raw地址
 	rawURL = NormalizeGitHubRawURL(rawURL)
@@ -492,6 +592,25 @@ func FetchSubsData(rawURL string) ([]byte, error) {
 	candidates, hasPlaceholder := buildCandidateURLs(rawURL)
 	var lastErr error
 
+	// 本地订阅不缓存
+	cache := subCache
+	if utils.IsLocalURL(rawURL) {
+		cache = nil
+	}
+	var (
+		entry  subcache.Entry
+		cached bool
+	)
+	if cache != nil {
+		entry, cached = cache.Get(src.URL)
+	}
+	fail := func(err error) ([]byte, http.Header, string, error) {
+		if data, ok := subCacheFallback(cache, src, entry, cached, err); ok {
+			return data, nil, CacheFallback, nil
+		}
+		return nil, nil, "", err
+	}
+
 	// 定义请求策略
 	type strategy struct {
 		useProxy bool
@@ -503,28 +622,49 @@ func FetchSubsData(rawURL string) ([]byte, error) {
 	warpFunc := func(s string) string { return utils.WarpURL(EnsureScheme(s), true) }
 	originFunc := func(s string) string { return EnsureScheme(s) }
 
-	if utils.IsLocalURL(rawURL) {
+	switch {
+	case utils.IsLocalURL(rawURL):
 		strategies = append(strategies, strategy{false, warpFunc})
-	} else {
+	case src.FetchVia == config.FetchViaDirect:
+		strategies = append(strategies, strategy{false, originFunc})
+	case src.FetchVia == config.FetchViaSystemProxy:
+		if !utils.IsSysProxyAvailable {
+			slog.Warn("系统代理不可用，改为直连获取", "订阅", src.Label())
+		}
+		strategies = append(strategies, strategy{utils.IsSysProxyAvailable, originFunc})
+	case src.FetchVia == config.FetchViaGhProxy:
+		if !utils.IsGhProxyAvailable {
+			slog.Warn("GitHub 代理不可用，改为直连获取", "订阅", src.Label())
+			strategies = append(strategies, strategy{false, originFunc})
+		} else {
+			strategies = append(strategies, strategy{false, warpFunc})
+		}
+	default:
+		if src.FetchVia != "" {
+			slog.Warn("未知的 fetch-via，按默认方式获取", "订阅", src.Label(), "fetch-via", src.FetchVia)
+		}
 		// 1. 系统代理 (External utils)
 		if utils.IsSysProxyAvailable {
 			strategies = append(strategies, strategy{true, originFunc})
 		}
-		// 2. Github 代理 (External utils)
-		if utils.IsGhProxyAvailable {
+		// 2. Github 代理 (External utils)，带自定义请求头时不经过第三方代理，避免泄露认证信息
+		if utils.IsGhProxyAvailable && len(src.Headers) == 0 {
 			strategies = append(strategies, strategy{false, warpFunc})
 		}
 		// 3. 直连兜底
 		strategies = append(strategies, strategy{false, originFunc})
 	}
 
-	// UA 列表池
+	// UA 列表池，订阅指定 UA 时只使用指定的
 	uaList := []string{
 		convert.RandUserAgent(),
 		"mihomo/1.18.3",
 		"clash.meta",
 		"curl/8.16.0",
 	}
+	if src.UserAgent != "" {
+		uaList = []string{src.UserAgent}
+	}
 
 	for i := range maxRetries {
 		ua := uaList[i%len(uaList)]
@@ -547,23 +687,42 @@ func FetchSubsData(rawURL string) ([]byte, error) {
 				// 保持 Debug，过于频繁的尝试详情不需要 Info
 				slog.Debug("尝试下载", "Target", targetURL, "Proxy", strat.useProxy)
 
-				body, err, fatal := fetchOnce(targetURL, strat.useProxy, timeout, ua)
+				headers := src.Headers
+				if cached && entry.URL == candidate {
+					headers = conditionalHeaders(src.Headers, entry)
+				}
+				body, header, err, fatal := fetchOnce(targetURL, strat.useProxy, timeout, ua, headers)
+				if errors.Is(err, errNotModified) {
+					if data, e := cache.Body(entry); e == nil {
+						cache.Touch(src.URL, header, time.Now())
+						slog.Debug("订阅未更新，使用缓存内容", "订阅", src.Label())
+						return data, header, CacheNotModified, nil
+					}
+					// 缓存内容丢失，重新获取完整内容
+					cached = false
+					body, header, err, fatal = fetchOnce(targetURL, strat.useProxy, timeout, ua, src.Headers)
+				}
 				if err == nil {
-					return body, nil
+					if cache != nil {
+						if e := cache.Put(src.URL, candidate, body, header, time.Now()); e != nil {
+							slog.Debug("保存订阅缓存失败", "订阅", src.Label(), "error", e)
+						}
+					}
+					return body, header, "", nil
 				}
 				lastErr = err
 
 				if fatal && !hasPlaceholder {
-					return nil, err
+					return fail(err)
 				}
 			}
 		}
 		if hasPlaceholder {
-			return nil, ErrIgnore
+			return fail(ErrIgnore)
 		}
 	}
 
-	return nil, fmt.Errorf("%d次重试后失败: %v", maxRetries, lastErr)
+	return fail(fmt.Errorf("%d次重试后失败: %v", maxRetries, lastErr))
 }
 
 // clientMap 用于缓存不同代理策略的 HTTP Client
@@ -611,7 +770,9 @@ func getClient(proxyAddr string) *http.Client {
 }
 
 // fetchOnce 执行单次 HTTP 请求 (使用连接池)
-func fetchOnce(target string, useProxy bool, timeoutSec int, ua string) ([]byte, error, bool) {
+//
+// 返回响应头，条件请求返回 304 时错误为 errNotModified。
+func fetchOnce(target string, useProxy bool, timeoutSec int, ua string, headers map[string]string) ([]byte, http.Header, error, bool) {
 	// 1. 确定 Client Key
 	proxyKey := "direct"
 	if useProxy {
@@ -631,12 +792,15 @@ func fetchOnce(target string, useProxy bool, timeoutSec int, ua string) ([]byte,
 	// 4. 创建请求
 	req, err := http.NewRequestWithContext(ctx, "GET", target, nil)
 	if err != nil {
-		return nil, err, false
+		return nil, nil, err, false
 	}
 	if len(ua) <= 1 {
 		ua = convert.RandUserAgent()
 	}
 	req.Header.Set("User-Agent", ua)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
 
 	// 4. 处理本地请求特殊 Header
 	if isLocalRequest(req.URL) {
@@ -650,16 +814,20 @@ func fetchOnce(target string, useProxy bool, timeoutSec int, ua string) ([]byte,
 	// 5. 执行请求
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, err, false
+		return nil, nil, err, false
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, resp.Header, errNotModified, false
+	}
+
 	if resp.StatusCode >= 400 {
 		// 读取并丢弃 Body，有助于复用 TCP 连接（Keep-Alive）
 		_, _ = io.Copy(io.Discard, resp.Body)
 		slog.Debug("错误", "url", req.URL, "代理", useProxy, "状态码", resp.StatusCode, "UA", req.UserAgent())
 		fatal := resp.StatusCode == 401 || resp.StatusCode == 403 || resp.StatusCode == 404 || resp.StatusCode == 410
-		return nil, fmt.Errorf("%d", resp.StatusCode), fatal
+		return nil, resp.Header, fmt.Errorf("%d", resp.StatusCode), fatal
 	}
 
 	// 限制最大读取 100MB
@@ -667,28 +835,34 @@ func fetchOnce(target string, useProxy bool, timeoutSec int, ua string) ([]byte,
 
 	// 如果 Content-Length 存在且超过限制，直接报错，避免无谓的读取
 	if resp.ContentLength > MaxLimit {
-		return nil, fmt.Errorf("订阅文件过大: %d MB", resp.ContentLength/1024/1024), true
+		return nil, resp.Header, fmt.Errorf("订阅文件过大: %d MB", resp.ContentLength/1024/1024), true
 	}
 
 	body, err := io.ReadAll(io.LimitReader(resp.Body, MaxLimit))
 	if err != nil {
-		return nil, err, false
+		return nil, nil, err, false
 	}
 
 	if len(body) >= MaxLimit {
-		return nil, fmt.Errorf("订阅文件超过 50MB 限制"), true
+		return nil, resp.Header, fmt.Errorf("订阅文件超过 50MB 限制"), true
 	}
 
-	return body, nil, false
+	return body, resp.Header, nil, false
 }
 
-// resolveSubUrls 合并本地与远程订阅清单并去重
-func resolveSubUrls() ([]string, int, int, int) {
+// resolveSubUrls 合并本地与远程订阅清单并去重，跳过未启用的订阅
+func resolveSubUrls() ([]config.SubSource, int, int, int) {
 	var localNum, remoteNum, historyNum int
-	localNum = len(config.GlobalConfig.SubUrls)
 
-	urls := make([]string, 0, len(config.GlobalConfig.SubUrls))
-	urls = append(urls, config.GlobalConfig.SubUrls...)
+	urls := make([]config.SubSource, 0, len(config.GlobalConfig.SubUrls))
+	for _, src := range config.GlobalConfig.SubUrls {
+		if !src.IsEnabled() {
+			slog.Debug("跳过未启用的订阅", "订阅", src.Label())
+			continue
+		}
+		urls = append(urls, src)
+	}
+	localNum = len(urls)
 
 	if len(config.GlobalConfig.SubUrlsRemote) != 0 {
 		slog.Info("获取远程订阅列表")
@@ -726,23 +900,24 @@ func resolveSubUrls() ([]string, int, int, int) {
 
 			if _, err := os.Stat(localLastSuccedFile); err == nil {
 				historyNum++
-				urls = append([]string{localLastSucced + "#Succeed"}, urls...)
+				urls = append([]config.SubSource{{URL: localLastSucced + "#Succeed"}}, urls...)
 			}
 			if _, err := os.Stat(localHistoryFile); err == nil {
 				historyNum++
-				urls = append([]string{localHistory + "#History"}, urls...)
+				urls = append([]config.SubSource{{URL: localHistory + "#History"}}, urls...)
 			}
 		}
 	}
 
 	// 去重并过滤本地 URL（忽略 fragment）
 	seen := make(map[string]struct{}, len(urls))
-	out := make([]string, 0, len(urls))
-	for _, s := range urls {
-		s = strings.TrimSpace(s)
-		if s == "" || strings.HasPrefix(s, "#") {
+	out := make([]config.SubSource, 0, len(urls))
+	for _, src := range urls {
+		s := strings.TrimSpace(src.URL)
+		if s == "" || strings.HasPrefix(s, "#") || !src.IsEnabled() {
 			continue
 		}
+		src.URL = s
 
 		key := s
 		if d, err := url.Parse(s); err == nil {
@@ -760,17 +935,17 @@ func resolveSubUrls() ([]string, int, int, int) {
 			continue
 		}
 		seen[key] = struct{}{}
-		out = append(out, s)
+		out = append(out, src)
 	}
 	return out, localNum, remoteNum, historyNum
 }
 
-// fetchRemoteSubUrls 从远程地址读取订阅URL清单
-func fetchRemoteSubUrls(listURL string) ([]string, error) {
+// fetchRemoteSubUrls 从远程地址读取订阅URL清单，清单中的订阅同样支持对象写法
+func fetchRemoteSubUrls(listURL string) ([]config.SubSource, error) {
 	if listURL == "" {
 		return nil, errors.New("远程列表为空")
 	}
-	data, err := FetchSubsData(listURL)
+	data, err := FetchSubsData(config.SubSource{URL: listURL})
 	if err != nil {
 		return nil, err
 	}
@@ -782,7 +957,7 @@ func fetchRemoteSubUrls(listURL string) ([]string, error) {
 	}
 
 	// 2) 尝试解析为数组形式 ([...])
-	var arr []string
+	var arr []config.SubSource
 	if err := yaml.Unmarshal(data, &arr); err == nil && len(arr) > 0 {
 		return arr, nil
 	}
@@ -791,12 +966,12 @@ func fetchRemoteSubUrls(listURL string) ([]string, error) {
 	var generic map[string]any
 	if err := yaml.Unmarshal(data, &generic); err == nil && len(generic) > 0 {
 		if urls := extractClashProviderURLs(generic); len(urls) > 0 {
-			return urls, nil
+			return toSubSources(urls), nil
 		}
 	}
 
 	// 3) 回退为按行解析 (纯文本) + 快速 URL 校验
-	res := make([]string, 0, 16)
+	res := make([]config.SubSource, 0, 16)
 	scanner := bufio.NewScanner(bytes.NewReader(data))
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
@@ -812,7 +987,7 @@ func fetchRemoteSubUrls(listURL string) ([]string, error) {
 		if parsed, perr := url.Parse(line); perr == nil {
 			scheme := strings.ToLower(parsed.Scheme)
 			if (scheme == "http" || scheme == "https") && parsed.Host != "" {
-				res = append(res, line)
+				res = append(res, config.SubSource{URL: line})
 			}
 		}
 	}
@@ -822,6 +997,15 @@ func fetchRemoteSubUrls(listURL string) ([]string, error) {
 	return res, nil
 }
 
+// toSubSources 将 URL 列表转换为订阅源
+func toSubSources(urls []string) []config.SubSource {
+	out := make([]config.SubSource, 0, len(urls))
+	for _, u := range urls {
+		out = append(out, config.SubSource{URL: u})
+	}
+	return out
+}
+
 // identifyLocalSubType 识别本地订阅源类型
 func identifyLocalSubType(subURL, listenPort, storePort string) (isLatest, isHistory bool, tag string) {
 	u, err := url.Parse(subURL)
@@ -853,6 +1037,7 @@ func identifyLocalSubType(subURL, listenPort, storePort string) (isLatest, isHis
 func cleanMetadata(p ProxyNode) {
 	delete(p, "sub_was_succeed")
 	delete(p, "sub_from_history")
+	delete(p, "sub_cache")
 }
 
 // saveStats 保存统计信息
@@ -885,7 +1070,7 @@ func saveStats(subStats map[string]SubStat) {
 
 	if len(subStats) < uniqueSubsCount {
 		validSB.WriteString("\n# 已剔除以下失效订阅链接：\n")
-		for _, u := range config.GlobalConfig.SubUrls {
+		for _, u := range config.SubURLs(config.GlobalConfig.SubUrls) {
 			if _, ok := subStats[u]; !ok {
 				fmt.Fprintf(&validSB, "# - %q\n", u)
 			}
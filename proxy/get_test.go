@@ -0,0 +1,114 @@
+package proxies
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sinspired/subs-check-pro/config"
+	"github.com/sinspired/subs-check-pro/proxy/subcache"
+)
+
+func TestParseNodes(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		typ   string
+	}{
+		{"share link", "trojan://pass@example.com:443?sni=example.com#test", "trojan"},
+		{"clash map", "name: a\ntype: ss\nserver: 1.2.3.4\nport: '8388'\ncipher: aes-128-gcm\npassword: p", "ss"},
+		{"clash list", "- {name: a, type: socks5, server: 1.2.3.4, port: 1080}", "socks5"},
+		{"clash proxies", "proxies:\n  - {name: a, type: http, server: 1.2.3.4, port: 443}", "http"},
+		{"json", `{"name":"a","type":"trojan","server":"1.2.3.4","port":443,"password":"p"}`, "trojan"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes, err := ParseNodes([]byte(tt.input))
+			if err != nil || len(nodes) != 1 {
+				t.Fatalf("ParseNodes() = %v, %v", nodes, err)
+			}
+			if got := nodes[0]["type"]; got != tt.typ {
+				t.Errorf("type = %v, want %s", got, tt.typ)
+			}
+			if _, ok := nodes[0]["port"].(int); !ok {
+				t.Errorf("port not normalized: %T", nodes[0]["port"])
+			}
+		})
+	}
+
+	if _, err := ParseNodes([]byte("  ")); err == nil {
+		t.Error("expected error for empty input")
+	}
+}
+
+func TestFetchSubsDataSourceOptions(t *testing.T) {
+	var gotUA, gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUA, gotAuth = r.UserAgent(), r.Header.Get("Authorization")
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	src := config.SubSource{
+		URL:       srv.URL + "/sub",
+		Headers:   map[string]string{"Authorization": "Bearer token"},
+		UserAgent: "clash.meta",
+		FetchVia:  config.FetchViaDirect,
+		Timeout:   5,
+	}
+	data, err := FetchSubsData(src)
+	if err != nil || string(data) != "ok" {
+		t.Fatalf("FetchSubsData() = %q, %v", data, err)
+	}
+	if gotUA != "clash.meta" || gotAuth != "Bearer token" {
+		t.Errorf("请求头未生效: UA=%q Authorization=%q", gotUA, gotAuth)
+	}
+}
+
+func TestFetchOnceNotModified(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	body, header, err, _ := fetchOnce(srv.URL, false, 5, "clash.meta", nil)
+	if err != nil || string(body) != "ok" || header.Get("ETag") != `"v1"` {
+		t.Fatalf("fetchOnce() = %q, %v, %v", body, header, err)
+	}
+
+	headers := conditionalHeaders(nil, subcache.Entry{ETag: header.Get("ETag")})
+	if _, _, err, _ := fetchOnce(srv.URL, false, 5, "clash.meta", headers); !errors.Is(err, errNotModified) {
+		t.Errorf("条件请求 error = %v, want errNotModified", err)
+	}
+}
+
+func TestSubCacheFallback(t *testing.T) {
+	old := config.GlobalConfig.SubCacheMaxStale
+	config.GlobalConfig.SubCacheMaxStale = 1
+	defer func() { config.GlobalConfig.SubCacheMaxStale = old }()
+
+	c := subcache.New(t.TempDir())
+	src := config.SubSource{URL: "https://example.com/sub"}
+	now := time.Now()
+	_ = c.Put(src.URL, src.URL, []byte("cached"), http.Header{}, now.Add(-30*time.Minute))
+	e, ok := c.Get(src.URL)
+
+	data, used := subCacheFallback(c, src, e, ok, errors.New("503"))
+	if !used || string(data) != "cached" {
+		t.Fatalf("subCacheFallback() = %q, %v", data, used)
+	}
+
+	// 超过最长时效不再回退
+	_ = c.Put(src.URL, src.URL, []byte("cached"), http.Header{}, now.Add(-2*time.Hour))
+	e, ok = c.Get(src.URL)
+	if _, used := subCacheFallback(c, src, e, ok, errors.New("503")); used {
+		t.Error("过期缓存不应回退")
+	}
+}
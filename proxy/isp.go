@@ -49,22 +49,10 @@ type IPInfo struct {
 func GetISPInfo(client *http.Client) string {
 	ctx := context.Background()
 	info, err := CheckISPInfoWithIPAPI(ctx, client, "me", "")
-	if err != nil || info == nil || info.Type == ISPOther && info.Details == "" {
+	if err != nil {
 		return ""
 	}
-
-	parts := []string{}
-	if info.IsNative {
-		parts = append(parts, "原生")
-	} else {
-		parts = append(parts, "广播")
-	}
-
-	if info.Details != "" {
-		parts = append(parts, info.Details)
-	}
-
-	return "[" + strings.Join(parts, "|") + "]"
+	return FormatISPInfo(info)
 }
 
 func CheckISPInfoWithIPAPI(ctx context.Context, client *http.Client, ip, apiKey string) (*IPInfo, error) {
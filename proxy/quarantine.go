@@ -0,0 +1,183 @@
+package proxies
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/sinspired/subs-check-pro/config"
+	"github.com/sinspired/subs-check-pro/proxy/subhealth"
+	"github.com/sinspired/subs-check-pro/utils"
+)
+
+// subHealthRetention 超过此时间未获取的订阅记录会被清理
+const subHealthRetention = 30 * 24 * time.Hour
+
+var (
+	subHealthMu sync.Mutex
+	subHealth   *subhealth.Store
+)
+
+// loadSubHealth 返回订阅健康数据库，首次调用时从文件加载
+func loadSubHealth() (*subhealth.Store, error) {
+	subHealthMu.Lock()
+	defer subHealthMu.Unlock()
+	if subHealth != nil {
+		return subHealth, nil
+	}
+	path, err := subhealth.DefaultPath()
+	if err != nil {
+		return nil, err
+	}
+	s, err := subhealth.Open(path)
+	if err != nil {
+		// 文件损坏时重新统计
+		slog.Warn(fmt.Sprintf("加载订阅健康数据库失败，将重新统计: %v", err))
+		s = subhealth.New(path)
+	}
+	subHealth = s
+	return s, nil
+}
+
+// subHealthPolicy 返回配置中的隔离策略
+func subHealthPolicy() subhealth.Policy {
+	q := config.GlobalConfig.SubQuarantine
+	return subhealth.Policy{
+		FetchFailures: q.FetchFailures,
+		ZeroRuns:      q.ZeroRuns,
+		SkipRuns:      q.SkipRuns,
+		MaxSkipRuns:   q.MaxSkipRuns,
+	}
+}
+
+// tracksSubHealth 是否统计该订阅，本地订阅不统计
+func tracksSubHealth(u string) bool {
+	return !utils.IsLocalURL(CleanURL(u))
+}
+
+// subHealthRun 单次获取订阅时的健康统计
+type subHealthRun struct {
+	store *subhealth.Store
+	at    time.Time
+
+	mu      sync.Mutex
+	fetched []string // 获取成功的订阅
+}
+
+// startSubHealth 开始本次获取的健康统计，数据库不可用时返回 nil
+func startSubHealth() *subHealthRun {
+	s, err := loadSubHealth()
+	if err != nil {
+		slog.Warn(fmt.Sprintf("获取订阅健康数据库路径失败: %v", err))
+		return nil
+	}
+	return &subHealthRun{store: s, at: time.Now()}
+}
+
+// skipQuarantined 开启隔离时跳过隔离中的订阅
+func (r *subHealthRun) skipQuarantined(sources []config.SubSource) []config.SubSource {
+	if r == nil || !config.GlobalConfig.SubQuarantine.Enabled {
+		return sources
+	}
+	out := sources[:0]
+	skipped := 0
+	for _, src := range sources {
+		if tracksSubHealth(src.URL) && r.store.Skip(src.URL) {
+			rec, _ := r.store.Get(src.URL)
+			slog.Debug("跳过隔离中的订阅", "订阅", src.Label(), "原因", rec.Reason, "剩余次数", rec.SkipRuns)
+			skipped++
+			continue
+		}
+		out = append(out, src)
+	}
+	if skipped > 0 {
+		slog.Info(fmt.Sprintf("跳过隔离中的订阅: %d", skipped))
+	}
+	return out
+}
+
+// observeFetch 记录订阅获取结果
+func (r *subHealthRun) observeFetch(u string, ok bool) {
+	if r == nil || !tracksSubHealth(u) {
+		return
+	}
+	r.store.ObserveFetch(u, ok, r.at)
+	if ok {
+		r.mu.Lock()
+		r.fetched = append(r.fetched, u)
+		r.mu.Unlock()
+	}
+}
+
+// finish 记录获取成功但没有节点的订阅，按获取结果隔离并保存
+func (r *subHealthRun) finish(stats map[string]SubStat) {
+	if r == nil {
+		return
+	}
+	for _, u := range r.fetched {
+		if stats[u].Total == 0 {
+			r.store.ObserveResult(u, 0, 0, r.at)
+		}
+	}
+	applySubQuarantine(r.store, r.at)
+	r.store.Prune(r.at.Add(-subHealthRetention))
+	if err := r.store.Save(); err != nil {
+		slog.Warn(fmt.Sprintf("保存订阅健康数据库失败: %v", err))
+	}
+}
+
+// applySubQuarantine 开启隔离时按策略隔离订阅
+func applySubQuarantine(s *subhealth.Store, now time.Time) {
+	if !config.GlobalConfig.SubQuarantine.Enabled {
+		return
+	}
+	for _, rec := range s.Apply(subHealthPolicy(), now) {
+		reason := "连续获取失败"
+		if rec.Reason == subhealth.ReasonZero {
+			reason = "连续无可用节点"
+		}
+		slog.Warn(fmt.Sprintf("订阅已隔离: %s", rec.URL), "原因", reason, "跳过次数", rec.SkipRuns, "隔离次数", rec.Quarantines)
+	}
+}
+
+// UpdateSubHealth 记录本次检测各订阅的可用节点数，按策略隔离并保存
+func UpdateSubHealth(stats map[string]SubStat) {
+	s, err := loadSubHealth()
+	if err != nil {
+		slog.Warn(fmt.Sprintf("获取订阅健康数据库路径失败: %v", err))
+		return
+	}
+	now := time.Now()
+	for u, st := range stats {
+		if st.Total > 0 && tracksSubHealth(u) {
+			s.ObserveResult(u, st.Total, st.Success, now)
+		}
+	}
+	applySubQuarantine(s, now)
+	if err := s.Save(); err != nil {
+		slog.Warn(fmt.Sprintf("保存订阅健康数据库失败: %v", err))
+	}
+}
+
+// SubHealthRecords 返回订阅健康记录，隔离中的在前
+func SubHealthRecords() ([]subhealth.Record, error) {
+	s, err := loadSubHealth()
+	if err != nil {
+		return nil, err
+	}
+	return s.Records(), nil
+}
+
+// ResetSubQuarantine 解除订阅隔离，urls 为空时解除全部，返回解除数量
+func ResetSubQuarantine(urls []string) (int, error) {
+	s, err := loadSubHealth()
+	if err != nil {
+		return 0, err
+	}
+	n := s.Reset(urls)
+	if err := s.Save(); err != nil {
+		return n, err
+	}
+	return n, nil
+}
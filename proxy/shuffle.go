@@ -12,6 +12,7 @@ type ShuffleConfig struct {
 	MinSpacing int        // 同一 IPv4 /24 的最小间距；<=0 关闭
 	ScanLimit  int        // 冲突向前扫描的最大距离
 	Rand       *rand.Rand // 随机数，为空则使用 time.Now().UnixNano()
+	KeepOrder  bool       // 保留原有顺序，跳过初次打乱，仅做局部交换满足间距
 }
 
 type serverMeta struct {
@@ -49,9 +50,12 @@ func SmartShuffleByServer(items []map[string]any, cfg ShuffleConfig) {
 	}
 
 	// 初次完全打乱 (同时打乱 items 和 metas)
-	rand.Shuffle(n, func(i, j int) {
-		swap(items, metas, i, j)
-	})
+	// 保留顺序时跳过，后续仅在 ScanLimit 范围内交换，整体顺序基本不变
+	if !cfg.KeepOrder {
+		rand.Shuffle(n, func(i, j int) {
+			swap(items, metas, i, j)
+		})
+	}
 
 	// 检查最小间距的闭包函数
 	checkSpacing := func(lp map[uint32]int, idx int, m serverMeta) bool {
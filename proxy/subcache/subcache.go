@@ -0,0 +1,186 @@
+// Package subcache 保存订阅最近一次成功获取的内容
+//
+// 再次获取时携带 ETag/Last-Modified 发起条件请求，内容未修改时直接使用缓存；
+// 获取失败时在允许的时效内回退到缓存内容。
+package subcache
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sinspired/subs-check-pro/save/method"
+	"github.com/sinspired/subs-check-pro/utils"
+)
+
+// DirName 缓存目录，位于 output/stats 目录下
+//
+// 缓存包含订阅地址(含 token)和原始内容，不能放在对外分享的 output/sub 目录。
+const DirName = "sub-cache"
+
+// indexName 缓存索引文件名
+const indexName = "index.json"
+
+// Entry 单个订阅的缓存信息
+type Entry struct {
+	URL          string `json:"url"` // 实际请求的地址(日期占位符已替换)
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	At           int64  `json:"t"` // 最后确认内容有效的时间，Unix 时间戳(秒)
+	File         string `json:"file"`
+}
+
+// Age 返回缓存内容距 now 的时长
+func (e Entry) Age(now time.Time) time.Duration {
+	return now.Sub(time.Unix(e.At, 0))
+}
+
+// Store 订阅缓存，并发安全，键为配置中的订阅地址
+type Store struct {
+	mu      sync.Mutex
+	dir     string
+	entries map[string]Entry
+}
+
+// New 创建空缓存
+func New(dir string) *Store {
+	return &Store{dir: dir, entries: make(map[string]Entry)}
+}
+
+// Open 打开缓存目录，索引不存在时返回空缓存
+func Open(dir string) (*Store, error) {
+	s := New(dir)
+	data, err := os.ReadFile(filepath.Join(dir, indexName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("读取订阅缓存失败: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.entries); err != nil {
+		return nil, fmt.Errorf("解析订阅缓存失败: %w", err)
+	}
+	if s.entries == nil {
+		s.entries = make(map[string]Entry)
+	}
+	return s, nil
+}
+
+// Get 返回订阅的缓存信息
+func (s *Store) Get(key string) (Entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key]
+	return e, ok
+}
+
+// Body 读取缓存内容
+func (s *Store) Body(e Entry) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, e.File))
+	if err != nil {
+		return nil, fmt.Errorf("读取订阅缓存内容失败: %w", err)
+	}
+	return data, nil
+}
+
+// Put 写入订阅内容和响应中的校验信息
+func (s *Store) Put(key, url string, body []byte, header http.Header, now time.Time) error {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return fmt.Errorf("创建目录失败: %w", err)
+	}
+	e := Entry{
+		URL:          url,
+		ETag:         header.Get("ETag"),
+		LastModified: header.Get("Last-Modified"),
+		At:           now.Unix(),
+		File:         fileName(key),
+	}
+	if err := utils.WriteFileAtomic(filepath.Join(s.dir, e.File), body, 0o644); err != nil {
+		return fmt.Errorf("保存订阅缓存失败: %w", err)
+	}
+
+	s.mu.Lock()
+	s.entries[key] = e
+	s.mu.Unlock()
+	return nil
+}
+
+// Touch 内容未修改时刷新缓存时间，响应中带有新的校验信息时一并更新
+func (s *Store) Touch(key string, header http.Header, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key]
+	if !ok {
+		return
+	}
+	if v := header.Get("ETag"); v != "" {
+		e.ETag = v
+	}
+	if v := header.Get("Last-Modified"); v != "" {
+		e.LastModified = v
+	}
+	e.At = now.Unix()
+	s.entries[key] = e
+}
+
+// Len 返回缓存的订阅数量
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+// Prune 删除超过 maxAge 的缓存及其内容文件，返回删除数量
+func (s *Store) Prune(now time.Time, maxAge time.Duration) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for key, e := range s.entries {
+		if e.Age(now) > maxAge {
+			os.Remove(filepath.Join(s.dir, e.File))
+			delete(s.entries, key)
+			n++
+		}
+	}
+	return n
+}
+
+// Save 原子写入缓存索引
+func (s *Store) Save() error {
+	s.mu.Lock()
+	data, err := json.Marshal(s.entries)
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("序列化订阅缓存失败: %w", err)
+	}
+
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return fmt.Errorf("创建目录失败: %w", err)
+	}
+	path := filepath.Join(s.dir, indexName)
+	if err := utils.WriteFileAtomic(path, data, 0o644); err != nil {
+		return fmt.Errorf("保存订阅缓存失败: %w", err)
+	}
+	return nil
+}
+
+// fileName 按订阅地址生成内容文件名
+func fileName(key string) string {
+	sum := sha1.Sum([]byte(key))
+	return hex.EncodeToString(sum[:]) + ".txt"
+}
+
+// DefaultDir 返回缓存的默认目录
+func DefaultDir() (string, error) {
+	saver, err := method.NewStatsSaver()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(saver.StatsPath, DirName), nil
+}
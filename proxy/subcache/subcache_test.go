@@ -0,0 +1,74 @@
+package subcache
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStorePutTouchAndReload(t *testing.T) {
+	dir := t.TempDir()
+	s := New(dir)
+	now := time.Now()
+	key := "https://example.com/sub#备注"
+
+	if _, ok := s.Get(key); ok {
+		t.Fatal("空缓存不应命中")
+	}
+
+	h := http.Header{}
+	h.Set("ETag", `"v1"`)
+	h.Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+	if err := s.Put(key, "https://example.com/sub", []byte("body-v1"), h, now.Add(-time.Hour)); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	e, ok := s.Get(key)
+	if !ok || e.ETag != `"v1"` || e.URL != "https://example.com/sub" {
+		t.Fatalf("Get() = %+v, %v", e, ok)
+	}
+	if body, err := s.Body(e); err != nil || string(body) != "body-v1" {
+		t.Fatalf("Body() = %q, %v", body, err)
+	}
+
+	// 304 响应刷新时间，缺少的校验信息保留原值
+	h2 := http.Header{}
+	h2.Set("ETag", `"v2"`)
+	s.Touch(key, h2, now)
+	e, _ = s.Get(key)
+	if e.ETag != `"v2"` || e.LastModified == "" || e.At != now.Unix() {
+		t.Errorf("Touch() = %+v", e)
+	}
+
+	if err := s.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	loaded, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if got, ok := loaded.Get(key); !ok || got != e {
+		t.Errorf("重新加载后缓存不一致: %+v", got)
+	}
+}
+
+func TestStorePrune(t *testing.T) {
+	dir := t.TempDir()
+	s := New(dir)
+	now := time.Now()
+
+	_ = s.Put("old", "old", []byte("old"), http.Header{}, now.Add(-72*time.Hour))
+	_ = s.Put("new", "new", []byte("new"), http.Header{}, now)
+
+	if n := s.Prune(now, 48*time.Hour); n != 1 || s.Len() != 1 {
+		t.Fatalf("Prune() = %d, len = %d", n, s.Len())
+	}
+	if _, err := os.Stat(filepath.Join(dir, fileName("old"))); !os.IsNotExist(err) {
+		t.Errorf("过期缓存内容未删除: %v", err)
+	}
+	if _, ok := s.Get("new"); !ok {
+		t.Error("未过期缓存被删除")
+	}
+}
@@ -0,0 +1,341 @@
+// Package subhealth 持久化记录订阅历次获取和检测的健康状况
+//
+// 连续获取失败或连续无可用节点的订阅会被隔离，跳过若干次检测后再重试，
+// 重试仍失败时隔离次数按指数增长。
+package subhealth
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/sinspired/subs-check-pro/save/method"
+	"github.com/sinspired/subs-check-pro/utils"
+)
+
+// FileName 订阅健康数据库文件名，保存在 output/stats 目录
+const FileName = "sub-health.json"
+
+// 隔离原因
+const (
+	ReasonFetch = "fetch" // 连续获取失败
+	ReasonZero  = "zero"  // 连续无可用节点
+)
+
+// Policy 隔离策略
+type Policy struct {
+	FetchFailures int // 连续获取失败达到此次数时隔离，0 为不按获取失败隔离
+	ZeroRuns      int // 连续无可用节点达到此次数时隔离，0 为不按可用节点隔离
+	SkipRuns      int // 首次隔离跳过的检测次数
+	MaxSkipRuns   int // 跳过次数上限
+}
+
+// skipRuns 返回第 n 次隔离跳过的检测次数
+func (p Policy) skipRuns(n int) int {
+	skip := max(1, p.SkipRuns)
+	// 限制倍增次数，避免未设置上限时溢出
+	for i := 1; i < min(n, 16); i++ {
+		if p.MaxSkipRuns > 0 && skip >= p.MaxSkipRuns {
+			break
+		}
+		skip *= 2
+	}
+	if p.MaxSkipRuns > 0 {
+		skip = min(skip, p.MaxSkipRuns)
+	}
+	return skip
+}
+
+// Record 单个订阅的健康记录
+type Record struct {
+	URL              string    `json:"url"`
+	Name             string    `json:"name,omitempty"`
+	Fetches          int       `json:"fetches"`
+	FetchFailures    int       `json:"fetch_failures"`
+	ConsecFetchFails int       `json:"consecutive_fetch_failures"`
+	ConsecZeroRuns   int       `json:"consecutive_zero_runs"`
+	RatedRuns        int       `json:"rated_runs"`       // 有检测结果的次数
+	AvgSuccessRate   float64   `json:"avg_success_rate"` // 历次检测成功率的平均值
+	LastFetch        time.Time `json:"last_fetch,omitzero"`
+	LastFetchOK      time.Time `json:"last_fetch_ok,omitzero"`
+	LastSuccess      time.Time `json:"last_success,omitzero"` // 最后一次有可用节点
+
+	Quarantines   int       `json:"quarantines"`      // 连续隔离次数
+	SkipRuns      int       `json:"skip_runs"`        // 剩余跳过次数
+	Probation     bool      `json:"probation"`        // 隔离结束后的试用期，失败时立即再次隔离
+	Reason        string    `json:"reason,omitempty"` // 最近一次隔离原因
+	QuarantinedAt time.Time `json:"quarantined_at,omitzero"`
+
+	Usage *Usage `json:"usage,omitempty"` // 最近一次获取到的流量和到期信息
+}
+
+// Quarantined 是否处于隔离中
+func (r *Record) Quarantined() bool {
+	return r.SkipRuns > 0
+}
+
+// Store 订阅健康数据库，并发安全
+type Store struct {
+	mu      sync.Mutex
+	path    string
+	records map[string]*Record
+}
+
+// New 创建空数据库
+func New(path string) *Store {
+	return &Store{path: path, records: make(map[string]*Record)}
+}
+
+// Open 打开数据库文件，文件不存在时返回空数据库
+func Open(path string) (*Store, error) {
+	s := New(path)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("读取订阅健康数据库失败: %w", err)
+	}
+	var list []*Record
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("解析订阅健康数据库失败: %w", err)
+	}
+	for _, r := range list {
+		if r != nil && r.URL != "" {
+			s.records[r.URL] = r
+		}
+	}
+	return s, nil
+}
+
+// record 返回订阅记录，不存在时创建，调用方需持有锁
+func (s *Store) record(url string) *Record {
+	r := s.records[url]
+	if r == nil {
+		r = &Record{URL: url}
+		s.records[url] = r
+	}
+	return r
+}
+
+// Get 返回订阅记录的副本
+func (s *Store) Get(url string) (Record, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.records[url]
+	if !ok {
+		return Record{}, false
+	}
+	return *r, true
+}
+
+// Skip 订阅处于隔离中时消耗一次跳过次数并返回 true
+//
+// 最后一次跳过后进入试用期，下次检测重新获取。
+func (s *Store) Skip(url string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.records[url]
+	if r == nil || r.SkipRuns <= 0 {
+		return false
+	}
+	r.SkipRuns--
+	if r.SkipRuns == 0 {
+		r.Probation = true
+	}
+	return true
+}
+
+// ObserveFetch 记录一次获取结果
+func (s *Store) ObserveFetch(url string, ok bool, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.record(url)
+	r.Fetches++
+	r.LastFetch = now
+	if ok {
+		r.ConsecFetchFails = 0
+		r.LastFetchOK = now
+		return
+	}
+	r.FetchFailures++
+	r.ConsecFetchFails++
+}
+
+// ObserveUsage 记录订阅的流量和到期信息
+func (s *Store) ObserveUsage(url, name string, u Usage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.record(url)
+	r.Name = name
+	r.Usage = &u
+}
+
+// ObserveResult 记录一次检测结果，total 为获取到的节点数，success 为可用节点数
+//
+// total 为 0 时视为无可用节点，但不计入平均成功率。
+func (s *Store) ObserveResult(url string, total, success int, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.record(url)
+	if total <= 0 {
+		r.ConsecZeroRuns++
+		return
+	}
+	rate := float64(success) / float64(total)
+	r.AvgSuccessRate = (r.AvgSuccessRate*float64(r.RatedRuns) + rate) / float64(r.RatedRuns+1)
+	r.RatedRuns++
+	if success > 0 {
+		r.ConsecZeroRuns = 0
+		r.LastSuccess = now
+		// 试用期内恢复，清除隔离记录
+		if r.Probation {
+			r.Probation = false
+			r.Quarantines = 0
+		}
+		return
+	}
+	r.ConsecZeroRuns++
+}
+
+// Apply 按策略隔离订阅，返回本次新隔离的记录
+//
+// 试用期内获取失败或无可用节点时立即再次隔离，跳过次数翻倍。
+func (s *Store) Apply(p Policy, now time.Time) []Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []Record
+	for _, r := range s.records {
+		if r.Quarantined() {
+			continue
+		}
+		reason := ""
+		switch {
+		case r.ConsecFetchFails > 0 && (r.Probation || p.FetchFailures > 0 && r.ConsecFetchFails >= p.FetchFailures):
+			reason = ReasonFetch
+		case r.ConsecZeroRuns > 0 && (r.Probation || p.ZeroRuns > 0 && r.ConsecZeroRuns >= p.ZeroRuns):
+			reason = ReasonZero
+		default:
+			continue
+		}
+		r.Quarantines++
+		r.SkipRuns = p.skipRuns(r.Quarantines)
+		r.Probation = false
+		r.Reason = reason
+		r.QuarantinedAt = now
+		r.ConsecFetchFails = 0
+		r.ConsecZeroRuns = 0
+		out = append(out, *r)
+	}
+	slices.SortFunc(out, func(a, b Record) int { return cmp.Compare(a.URL, b.URL) })
+	return out
+}
+
+// Reset 解除隔离并清除连续失败计数，urls 为空时处理全部订阅，返回解除数量
+func (s *Store) Reset(urls []string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(urls) == 0 {
+		for u := range s.records {
+			urls = append(urls, u)
+		}
+	}
+	n := 0
+	for _, u := range urls {
+		r := s.records[u]
+		if r == nil {
+			continue
+		}
+		if r.Quarantined() || r.Probation {
+			n++
+		}
+		r.SkipRuns = 0
+		r.Quarantines = 0
+		r.Probation = false
+		r.Reason = ""
+		r.QuarantinedAt = time.Time{}
+		r.ConsecFetchFails = 0
+		r.ConsecZeroRuns = 0
+	}
+	return n
+}
+
+// Records 返回全部记录的副本，隔离中的在前，其余按平均成功率降序
+func (s *Store) Records() []Record {
+	s.mu.Lock()
+	out := make([]Record, 0, len(s.records))
+	for _, r := range s.records {
+		out = append(out, *r)
+	}
+	s.mu.Unlock()
+	slices.SortFunc(out, func(a, b Record) int {
+		if qa, qb := a.Quarantined(), b.Quarantined(); qa != qb {
+			if qa {
+				return -1
+			}
+			return 1
+		}
+		if c := cmp.Compare(b.AvgSuccessRate, a.AvgSuccessRate); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.URL, b.URL)
+	})
+	return out
+}
+
+// Len 返回记录数量
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.records)
+}
+
+// Prune 删除 before 之后未获取过且未隔离的记录，返回删除数量
+func (s *Store) Prune(before time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for u, r := range s.records {
+		if !r.Quarantined() && r.LastFetch.Before(before) {
+			delete(s.records, u)
+			n++
+		}
+	}
+	return n
+}
+
+// Save 原子写入数据库文件
+func (s *Store) Save() error {
+	s.mu.Lock()
+	list := make([]*Record, 0, len(s.records))
+	for _, r := range s.records {
+		list = append(list, r)
+	}
+	data, err := json.Marshal(list)
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("序列化订阅健康数据库失败: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("创建目录失败: %w", err)
+	}
+	if err := utils.WriteFileAtomic(s.path, data, 0o644); err != nil {
+		return fmt.Errorf("保存订阅健康数据库失败: %w", err)
+	}
+	return nil
+}
+
+// DefaultPath 返回数据库的默认路径
+func DefaultPath() (string, error) {
+	saver, err := method.NewStatsSaver()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(saver.StatsPath, FileName), nil
+}
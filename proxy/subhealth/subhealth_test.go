@@ -0,0 +1,96 @@
+package subhealth
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPolicySkipRuns(t *testing.T) {
+	p := Policy{SkipRuns: 2, MaxSkipRuns: 10}
+	for n, want := range map[int]int{1: 2, 2: 4, 3: 8, 4: 10, 10: 10} {
+		if got := p.skipRuns(n); got != want {
+			t.Errorf("skipRuns(%d) = %d, want %d", n, got, want)
+		}
+	}
+	if got := (Policy{}).skipRuns(3); got != 4 {
+		t.Errorf("未设置上限时 skipRuns(3) = %d, want 4", got)
+	}
+}
+
+func TestStoreQuarantineBackoff(t *testing.T) {
+	s := New(filepath.Join(t.TempDir(), FileName))
+	p := Policy{FetchFailures: 2, ZeroRuns: 3, SkipRuns: 1, MaxSkipRuns: 8}
+	now := time.Now()
+	const u = "https://example.com/sub"
+
+	s.ObserveFetch(u, false, now)
+	if got := s.Apply(p, now); len(got) != 0 {
+		t.Fatalf("未达到阈值不应隔离: %+v", got)
+	}
+	s.ObserveFetch(u, false, now)
+	got := s.Apply(p, now)
+	if len(got) != 1 || got[0].Reason != ReasonFetch || got[0].SkipRuns != 1 {
+		t.Fatalf("Apply() = %+v", got)
+	}
+
+	// 跳过一次后进入试用期，试用期内失败立即再次隔离且跳过次数翻倍
+	if !s.Skip(u) || s.Skip(u) {
+		t.Fatal("Skip() 次数不正确")
+	}
+	s.ObserveFetch(u, true, now)
+	s.ObserveResult(u, 10, 0, now)
+	got = s.Apply(p, now)
+	if len(got) != 1 || got[0].Reason != ReasonZero || got[0].SkipRuns != 2 || got[0].Quarantines != 2 {
+		t.Fatalf("试用期失败 Apply() = %+v", got)
+	}
+
+	// 试用期内恢复后清除隔离次数
+	s.Skip(u)
+	s.Skip(u)
+	s.ObserveFetch(u, true, now)
+	s.ObserveResult(u, 10, 5, now)
+	if got := s.Apply(p, now); len(got) != 0 {
+		t.Fatalf("恢复后不应隔离: %+v", got)
+	}
+	r, _ := s.Get(u)
+	if r.Quarantines != 0 || r.Probation || r.RatedRuns != 2 || r.AvgSuccessRate != 0.25 {
+		t.Errorf("恢复后记录 = %+v", r)
+	}
+}
+
+func TestStoreResetAndReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	s := New(path)
+	now := time.Now()
+	p := Policy{FetchFailures: 1, SkipRuns: 3}
+
+	s.ObserveFetch("a", false, now)
+	s.ObserveFetch("b", false, now)
+	s.ObserveFetch("c", true, now)
+	if got := s.Apply(p, now); len(got) != 2 {
+		t.Fatalf("Apply() = %+v", got)
+	}
+	if recs := s.Records(); len(recs) != 3 || !recs[0].Quarantined() || recs[2].Quarantined() {
+		t.Errorf("Records() 排序错误: %+v", recs)
+	}
+
+	if n := s.Reset([]string{"a"}); n != 1 {
+		t.Errorf("Reset(a) = %d, want 1", n)
+	}
+	if err := s.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	loaded, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	a, _ := loaded.Get("a")
+	b, _ := loaded.Get("b")
+	if a.Quarantined() || !b.Quarantined() || loaded.Len() != 3 {
+		t.Errorf("重新加载后 a=%+v b=%+v", a, b)
+	}
+	if n := loaded.Reset(nil); n != 1 {
+		t.Errorf("Reset(nil) = %d, want 1", n)
+	}
+}
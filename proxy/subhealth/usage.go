@@ -0,0 +1,83 @@
+package subhealth
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Usage 订阅响应头 subscription-userinfo 中的流量和到期信息
+type Usage struct {
+	Upload   int64     `json:"upload"`
+	Download int64     `json:"download"`
+	Total    int64     `json:"total"`            // 0 为不限流量
+	Expire   int64     `json:"expire,omitempty"` // Unix 时间戳(秒)，0 为长期有效
+	At       time.Time `json:"t"`                // 获取时间
+}
+
+// ParseUsage 解析 subscription-userinfo，如 upload=1; download=2; total=3; expire=4
+//
+// 未包含任何流量或到期字段时返回 false。
+func ParseUsage(s string) (Usage, bool) {
+	var u Usage
+	found := false
+	for part := range strings.SplitSeq(s, ";") {
+		k, v, ok := strings.Cut(part, "=")
+		if !ok {
+			continue
+		}
+		v = strings.Trim(strings.TrimSpace(v), `"'`)
+		var dst *int64
+		switch strings.ToLower(strings.TrimSpace(k)) {
+		case "upload":
+			dst = &u.Upload
+		case "download":
+			dst = &u.Download
+		case "total":
+			dst = &u.Total
+		case "expire":
+			dst = &u.Expire
+		default:
+			continue
+		}
+		// 部分机场返回科学计数法或小数
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil || n < 0 {
+			continue
+		}
+		*dst = int64(n)
+		found = true
+	}
+	return u, found
+}
+
+// Used 返回已用流量(字节)
+func (u Usage) Used() int64 {
+	return u.Upload + u.Download
+}
+
+// UsedPercent 返回已用流量百分比，不限流量时为 0
+func (u Usage) UsedPercent() float64 {
+	if u.Total <= 0 {
+		return 0
+	}
+	return float64(u.Used()) / float64(u.Total) * 100
+}
+
+// Exhausted 流量是否已用完
+func (u Usage) Exhausted() bool {
+	return u.Total > 0 && u.Used() >= u.Total
+}
+
+// Expired 是否已到期
+func (u Usage) Expired(now time.Time) bool {
+	return u.Expire > 0 && now.Unix() >= u.Expire
+}
+
+// Remaining 返回距到期的时长，长期有效时返回 false
+func (u Usage) Remaining(now time.Time) (time.Duration, bool) {
+	if u.Expire <= 0 {
+		return 0, false
+	}
+	return time.Unix(u.Expire, 0).Sub(now), true
+}
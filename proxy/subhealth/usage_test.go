@@ -0,0 +1,43 @@
+package subhealth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseUsage(t *testing.T) {
+	u, ok := ParseUsage("upload=1073741824; download=2147483648; total=10737418240; expire=1798761600")
+	if !ok || u.Upload != 1<<30 || u.Download != 2<<30 || u.Total != 10<<30 || u.Expire != 1798761600 {
+		t.Fatalf("ParseUsage() = %+v, %v", u, ok)
+	}
+	if got := u.UsedPercent(); got != 30 {
+		t.Errorf("UsedPercent() = %v, want 30", got)
+	}
+
+	// 兼容空格、科学计数法和空的 expire
+	u, ok = ParseUsage(" upload = 0 ;download=1.5E9; total=1.5e9; expire=")
+	if !ok || u.Download != 1500000000 || u.Expire != 0 || !u.Exhausted() {
+		t.Errorf("ParseUsage() = %+v, %v", u, ok)
+	}
+
+	if _, ok := ParseUsage("plan_name=test"); ok {
+		t.Error("无流量字段时应返回 false")
+	}
+}
+
+func TestUsageExpire(t *testing.T) {
+	now := time.Unix(1_000_000, 0)
+	u := Usage{Expire: now.Add(48 * time.Hour).Unix()}
+	if u.Expired(now) {
+		t.Error("未到期")
+	}
+	if d, ok := u.Remaining(now); !ok || d != 48*time.Hour {
+		t.Errorf("Remaining() = %v, %v", d, ok)
+	}
+	if !u.Expired(now.Add(49 * time.Hour)) {
+		t.Error("应已到期")
+	}
+	if _, ok := (Usage{}).Remaining(now); ok || (Usage{}).Exhausted() {
+		t.Error("长期有效、不限流量")
+	}
+}
@@ -0,0 +1,169 @@
+package proxies
+
+import (
+	"cmp"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sinspired/subs-check-pro/config"
+	"github.com/sinspired/subs-check-pro/proxy/subhealth"
+)
+
+// 订阅流量和到期状态
+const (
+	UsageOK        = "ok"
+	UsageWarn      = "warn"      // 即将到期或流量不足
+	UsageExpired   = "expired"   // 已到期
+	UsageExhausted = "exhausted" // 流量已用完
+)
+
+// SubUsage 订阅的流量和到期信息
+type SubUsage struct {
+	URL    string
+	Label  string // 订阅名称，未设置时为备注或域名，不包含订阅 token
+	Status string
+	subhealth.Usage
+}
+
+// usageStatus 按配置的提醒阈值判断状态
+func usageStatus(u subhealth.Usage, now time.Time) string {
+	conf := config.GlobalConfig.SubUsage
+	switch {
+	case u.Expired(now):
+		return UsageExpired
+	case u.Exhausted():
+		return UsageExhausted
+	}
+	if d, ok := u.Remaining(now); ok && conf.WarnDays > 0 && d < time.Duration(conf.WarnDays)*24*time.Hour {
+		return UsageWarn
+	}
+	if u.Total > 0 && conf.WarnPercent > 0 && u.UsedPercent() >= float64(conf.WarnPercent) {
+		return UsageWarn
+	}
+	return UsageOK
+}
+
+// usageLabel 返回用于报告和通知的订阅名称，避免泄露订阅地址中的 token
+func usageLabel(rawURL, name string) string {
+	if name != "" {
+		return name
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "未知订阅"
+	}
+	if u.Fragment != "" {
+		return u.Fragment
+	}
+	return u.Hostname()
+}
+
+// Summary 返回提醒文案，如 机场A 2 天后到期，流量已用 95%
+func (u SubUsage) Summary(now time.Time) string {
+	parts := []string{}
+	if d, ok := u.Remaining(now); ok {
+		switch {
+		case d <= 0:
+			parts = append(parts, "已到期")
+		case d < 24*time.Hour:
+			parts = append(parts, "不足 1 天到期")
+		default:
+			parts = append(parts, fmt.Sprintf("%d 天后到期", int(d.Hours()/24)))
+		}
+	}
+	if u.Total > 0 {
+		if u.Exhausted() {
+			parts = append(parts, "流量已用完")
+		} else {
+			parts = append(parts, fmt.Sprintf("流量已用 %.0f%%", u.UsedPercent()))
+		}
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "长期有效，不限流量")
+	}
+	return u.Label + " " + strings.Join(parts, "，")
+}
+
+// observeUsage 记录响应头中的流量和到期信息，返回是否跳过该订阅的节点
+func (r *subHealthRun) observeUsage(src config.SubSource, header http.Header) bool {
+	if r == nil || header == nil || !tracksSubHealth(src.URL) {
+		return false
+	}
+	u, ok := subhealth.ParseUsage(header.Get("subscription-userinfo"))
+	if !ok {
+		return false
+	}
+	u.At = r.at
+	r.store.ObserveUsage(src.URL, src.Name, u)
+
+	su := SubUsage{URL: src.URL, Label: usageLabel(src.URL, src.Name), Usage: u}
+	switch usageStatus(u, r.at) {
+	case UsageExpired, UsageExhausted:
+		if config.GlobalConfig.SubUsage.SkipExpired {
+			slog.Warn("订阅已到期或流量已用完，跳过", "订阅", su.Summary(r.at))
+			return true
+		}
+		slog.Warn("订阅已到期或流量已用完", "订阅", su.Summary(r.at))
+	case UsageWarn:
+		slog.Warn("订阅即将到期或流量不足", "订阅", su.Summary(r.at))
+	}
+	return false
+}
+
+// SubUsages 返回 since 之后获取到流量信息的订阅，已到期和即将到期的在前
+func SubUsages(since time.Time) []SubUsage {
+	records, err := SubHealthRecords()
+	if err != nil {
+		return nil
+	}
+	now := time.Now()
+	var out []SubUsage
+	for _, r := range records {
+		if r.Usage == nil || r.Usage.At.Before(since) {
+			continue
+		}
+		out = append(out, SubUsage{
+			URL:    r.URL,
+			Label:  usageLabel(r.URL, r.Name),
+			Status: usageStatus(*r.Usage, now),
+			Usage:  *r.Usage,
+		})
+	}
+
+	rank := map[string]int{UsageExpired: 0, UsageExhausted: 0, UsageWarn: 1, UsageOK: 2}
+	slices.SortFunc(out, func(a, b SubUsage) int {
+		if c := cmp.Compare(rank[a.Status], rank[b.Status]); c != 0 {
+			return c
+		}
+		// 长期有效的排在最后
+		ea, eb := a.Expire, b.Expire
+		if ea == 0 {
+			ea = 1<<63 - 1
+		}
+		if eb == 0 {
+			eb = 1<<63 - 1
+		}
+		if c := cmp.Compare(ea, eb); c != 0 {
+			return c
+		}
+		return cmp.Compare(b.UsedPercent(), a.UsedPercent())
+	})
+	return out
+}
+
+// SubUsageWarnings 返回 since 之后获取到的需要提醒的订阅文案
+func SubUsageWarnings(since time.Time) []string {
+	now := time.Now()
+	var out []string
+	for _, u := range SubUsages(since) {
+		if u.Status != UsageOK {
+			out = append(out, u.Summary(now))
+		}
+	}
+	return out
+}
@@ -0,0 +1,55 @@
+package proxies
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sinspired/subs-check-pro/config"
+	"github.com/sinspired/subs-check-pro/proxy/subhealth"
+)
+
+func TestUsageStatusAndSummary(t *testing.T) {
+	old := config.GlobalConfig.SubUsage
+	config.GlobalConfig.SubUsage = config.SubUsageConfig{WarnDays: 3, WarnPercent: 90}
+	defer func() { config.GlobalConfig.SubUsage = old }()
+
+	now := time.Unix(time.Now().Unix(), 0)
+	tests := []struct {
+		name    string
+		usage   subhealth.Usage
+		status  string
+		summary string
+	}{
+		{"ok", subhealth.Usage{Download: 10, Total: 100, Expire: now.Add(240 * time.Hour).Unix()}, UsageOK, "机场A 10 天后到期，流量已用 10%"},
+		{"expire soon", subhealth.Usage{Download: 95, Total: 100, Expire: now.Add(49 * time.Hour).Unix()}, UsageWarn, "机场A 2 天后到期，流量已用 95%"},
+		{"traffic", subhealth.Usage{Upload: 5, Download: 85, Total: 100}, UsageWarn, "机场A 流量已用 90%"},
+		{"exhausted", subhealth.Usage{Download: 100, Total: 100}, UsageExhausted, "机场A 流量已用完"},
+		{"expired", subhealth.Usage{Expire: now.Add(-time.Hour).Unix()}, UsageExpired, "机场A 已到期"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := usageStatus(tt.usage, now); got != tt.status {
+				t.Errorf("usageStatus() = %s, want %s", got, tt.status)
+			}
+			su := SubUsage{Label: "机场A", Usage: tt.usage}
+			if got := su.Summary(now); got != tt.summary {
+				t.Errorf("Summary() = %q, want %q", got, tt.summary)
+			}
+		})
+	}
+}
+
+func TestUsageLabel(t *testing.T) {
+	tests := map[string]string{
+		"https://example.com/api/v1/client/subscribe?token=secret#机场B": "机场B",
+		"https://example.com/api/v1/client/subscribe?token=secret":     "example.com",
+	}
+	for raw, want := range tests {
+		if got := usageLabel(raw, ""); got != want {
+			t.Errorf("usageLabel(%q) = %q, want %q", raw, got, want)
+		}
+	}
+	if got := usageLabel("https://example.com", "机场A"); got != "机场A" {
+		t.Errorf("usageLabel() = %q, want 机场A", got)
+	}
+}
@@ -23,6 +23,7 @@ const (
 	NotifyGeoDBUpdate                   // GeoDB 更新
 	NotifySelfUpdate                    // 程序自更新
 	NotifyNewRelease                    // 新版本通知
+	NotifySubUsage                      // 订阅到期和流量提醒
 )
 
 const (
@@ -183,6 +184,9 @@ func decorateURL(raw string, kind NotifyKind, downloadURL string) string {
 		case NotifySelfUpdate:
 			q.Set("group", "selfupdate")
 			q.Set("category", "程序更新")
+		case NotifySubUsage:
+			q.Set("group", "subusage")
+			q.Set("category", "订阅到期提醒")
 		}
 	case "ntfy":
 		q.Set("avatar_url", WarpURL(IconURL, IsGhProxyAvailable))
@@ -200,6 +204,8 @@ func decorateURL(raw string, kind NotifyKind, downloadURL string) string {
 			q.Set("tags", "subs-check-pro,geodb-update")
 		case NotifySelfUpdate:
 			q.Set("tags", "subs-check-pro,self-update")
+		case NotifySubUsage:
+			q.Set("tags", "subs-check-pro,sub-usage")
 		}
 	case "discord":
 		if IconURL != "" {
@@ -272,6 +278,13 @@ func SendNotifyCheckResult(length int) {
 	broadcastNotify(NotifyNodeStatus, title, body, "")
 }
 
+// SendNotifySubUsage 发送订阅到期和流量提醒，每行一个订阅
+func SendNotifySubUsage(lines []string) {
+	title := "⏰ 订阅到期和流量提醒"
+	body := fmt.Sprintf("⚠️ %s\n🕒 %s", strings.Join(lines, "\n⚠️ "), GetCurrentTime())
+	broadcastNotify(NotifySubUsage, title, body, "")
+}
+
 // SendNotifyGeoDBUpdate 发送 GeoDB 更新通知
 func SendNotifyGeoDBUpdate(version string) {
 	title := "🔔 MaxMind GeoDB 更新"
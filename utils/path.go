@@ -15,3 +15,28 @@ func GetExecutablePath() string {
 	}
 	return filepath.Dir(ex)
 }
+
+// WriteFileAtomic 先写入同目录的临时文件再替换，避免中途崩溃或并发写入留下损坏的文件
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	tmp := f.Name()
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp, perm)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("写入 %s 失败: %w", filepath.Base(path), err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("替换 %s 失败: %w", filepath.Base(path), err)
+	}
+	return nil
+}